@@ -0,0 +1,121 @@
+package toolchaincluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// CredentialsValidConditionType is the type of the condition reporting the expiration of the credentials in the kubeconfig
+	// of the ToolchainCluster. The condition is set only when the expiration time of at least one of the credentials is known.
+	CredentialsValidConditionType toolchainv1alpha1.ConditionType = "CredentialsValid"
+
+	CredentialsValidReason        = "CredentialsValid"
+	CredentialsExpiringSoonReason = "CredentialsExpiringSoon"
+	CredentialsExpiredReason      = "CredentialsExpired"
+
+	// UnsupportedAuthenticationReason is the reason of the not ready ToolchainCluster whose secret uses an authentication
+	// the operator cannot resolve (ie. an exec plugin or an auth provider)
+	UnsupportedAuthenticationReason = "UnsupportedAuthentication"
+
+	// DefaultCredentialsExpiryWarning is the default period before the expiration of the credentials when the expiration is reported
+	DefaultCredentialsExpiryWarning = 7 * 24 * time.Hour
+)
+
+// CredentialsExpiry is the expiration time of the credentials used to access the remote clusters
+var CredentialsExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "sandbox_toolchaincluster_credentials_expiry_timestamp_seconds",
+	Help: "Expiration time of the credentials used to access the cluster, in seconds since the epoch",
+}, []string{"cluster_name", "credential"})
+
+func unsupportedAuthenticationCondition(errMsg string) toolchainv1alpha1.Condition {
+	return toolchainv1alpha1.Condition{
+		Type:    toolchainv1alpha1.ConditionReady,
+		Status:  corev1.ConditionFalse,
+		Reason:  UnsupportedAuthenticationReason,
+		Message: errMsg,
+	}
+}
+
+// RegisterMetrics registers the metrics of the ToolchainCluster controller in the given registry (eg. the controller-runtime metrics.Registry)
+func RegisterMetrics(registry prometheus.Registerer) error {
+	return registry.Register(CredentialsExpiry)
+}
+
+// getCredentialsCondition inspects the credentials in the given config, updates the CredentialsExpiry gauges and returns
+// the CredentialsValid condition. It returns nil if the expiration time of none of the credentials is known.
+func (r *Reconciler) getCredentialsCondition(ctx context.Context, clusterName string, restConfig *rest.Config) *toolchainv1alpha1.Condition {
+	deleteCredentialsMetrics(clusterName)
+	info, err := cluster.InspectCredentials(restConfig)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to inspect the credentials of the cluster")
+		return nil
+	}
+	expiries := info.Expiries()
+	if len(expiries) == 0 {
+		return nil
+	}
+	for _, expiry := range expiries {
+		CredentialsExpiry.WithLabelValues(clusterName, expiry.Credential).Set(float64(expiry.Expiry.Unix()))
+	}
+
+	warningPeriod := r.CredentialsExpiryWarning
+	if warningPeriod <= 0 {
+		warningPeriod = DefaultCredentialsExpiryWarning
+	}
+	now := r.currentTime()
+	var expired, expiring []string
+	for _, expiry := range expiries {
+		switch {
+		case !expiry.Expiry.After(now):
+			expired = append(expired, fmt.Sprintf("the %s expired at %s", expiry.Credential, expiry.Expiry.UTC().Format(time.RFC3339)))
+		case expiry.Expiry.Before(now.Add(warningPeriod)):
+			expiring = append(expiring, fmt.Sprintf("the %s expires at %s", expiry.Credential, expiry.Expiry.UTC().Format(time.RFC3339)))
+		}
+	}
+	switch {
+	case len(expired) > 0:
+		return &toolchainv1alpha1.Condition{
+			Type:    CredentialsValidConditionType,
+			Status:  corev1.ConditionFalse,
+			Reason:  CredentialsExpiredReason,
+			Message: strings.Join(append(expired, expiring...), "; "),
+		}
+	case len(expiring) > 0:
+		return &toolchainv1alpha1.Condition{
+			Type:    CredentialsValidConditionType,
+			Status:  corev1.ConditionTrue,
+			Reason:  CredentialsExpiringSoonReason,
+			Message: strings.Join(expiring, "; "),
+		}
+	}
+	return &toolchainv1alpha1.Condition{
+		Type:    CredentialsValidConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  CredentialsValidReason,
+		Message: fmt.Sprintf("the %s expires at %s", expiries[0].Credential, expiries[0].Expiry.UTC().Format(time.RFC3339)),
+	}
+}
+
+func deleteCredentialsMetrics(clusterName string) {
+	CredentialsExpiry.DeletePartialMatch(prometheus.Labels{"cluster_name": clusterName})
+}
+
+func removeCondition(conditions []toolchainv1alpha1.Condition, conditionType toolchainv1alpha1.ConditionType) []toolchainv1alpha1.Condition {
+	var result []toolchainv1alpha1.Condition
+	for _, c := range conditions {
+		if c.Type != conditionType {
+			result = append(result, c)
+		}
+	}
+	return result
+}
@@ -0,0 +1,161 @@
+package toolchaincluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestCredentialsCondition(t *testing.T) {
+	// given
+	defer gock.Off()
+	now := time.Now().Truncate(time.Second)
+
+	reconcileWithToken := func(t *testing.T, token string, configure ...func(*toolchainv1alpha1.ToolchainCluster)) (*test.FakeClient, *Reconciler, reconcile.Request) {
+		stable, sec := newToolchainCluster(t, "stable", "test-namespace", "https://cluster.com")
+		for _, apply := range configure {
+			apply(stable)
+		}
+		test.SetKubeConfigToken(t, sec, token)
+		cl := test.NewFakeClient(t, stable, sec)
+		reset := setupCachedClusters(t, cl, stable)
+		t.Cleanup(reset)
+		controller, req := prepareReconcile(stable, cl, requeAfter)
+		controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (bool, error) {
+			return true, nil
+		}
+		_, err := controller.Reconcile(context.TODO(), req)
+		require.NoError(t, err)
+		return cl, controller, req
+	}
+
+	t.Run("valid token", func(t *testing.T) {
+		// when
+		cl, _, _ := reconcileWithToken(t, test.NewServiceAccountToken(t, now.Add(30*24*time.Hour)))
+
+		// then
+		assertClusterStatus(t, cl, "stable", clusterReadyCondition(), toolchainv1alpha1.Condition{
+			Type:    CredentialsValidConditionType,
+			Status:  corev1.ConditionTrue,
+			Reason:  CredentialsValidReason,
+			Message: "the token expires at " + now.Add(30*24*time.Hour).UTC().Format(time.RFC3339),
+		})
+		assert.InDelta(t, float64(now.Add(30*24*time.Hour).Unix()), promtestutil.ToFloat64(CredentialsExpiry.WithLabelValues("stable", "token")), 0.1)
+	})
+
+	t.Run("token expiring soon", func(t *testing.T) {
+		// when
+		cl, _, _ := reconcileWithToken(t, test.NewServiceAccountToken(t, now.Add(24*time.Hour)))
+
+		// then
+		assertClusterStatus(t, cl, "stable", clusterReadyCondition(), toolchainv1alpha1.Condition{
+			Type:    CredentialsValidConditionType,
+			Status:  corev1.ConditionTrue,
+			Reason:  CredentialsExpiringSoonReason,
+			Message: "the token expires at " + now.Add(24*time.Hour).UTC().Format(time.RFC3339),
+		})
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		// when
+		cl, _, _ := reconcileWithToken(t, test.NewServiceAccountToken(t, now.Add(-time.Hour)))
+
+		// then
+		assertClusterStatus(t, cl, "stable", clusterReadyCondition(), toolchainv1alpha1.Condition{
+			Type:    CredentialsValidConditionType,
+			Status:  corev1.ConditionFalse,
+			Reason:  CredentialsExpiredReason,
+			Message: "the token expired at " + now.Add(-time.Hour).UTC().Format(time.RFC3339),
+		})
+	})
+
+	t.Run("unknown expiry removes the condition", func(t *testing.T) {
+		// when
+		cl, _, _ := reconcileWithToken(t, "mycooltoken", func(tc *toolchainv1alpha1.ToolchainCluster) {
+			tc.Status.Conditions = []toolchainv1alpha1.Condition{{
+				Type:   CredentialsValidConditionType,
+				Status: corev1.ConditionFalse,
+				Reason: CredentialsExpiredReason,
+			}}
+		})
+
+		// then
+		assertClusterStatus(t, cl, "stable", clusterReadyCondition())
+		assert.Zero(t, promtestutil.CollectAndCount(CredentialsExpiry))
+	})
+
+	t.Run("metrics are removed with the ToolchainCluster", func(t *testing.T) {
+		// given
+		cl, controller, req := reconcileWithToken(t, test.NewServiceAccountToken(t, now.Add(30*24*time.Hour)))
+		require.Equal(t, 1, promtestutil.CollectAndCount(CredentialsExpiry))
+		tc := &toolchainv1alpha1.ToolchainCluster{}
+		require.NoError(t, cl.Get(context.TODO(), test.NamespacedName("test-namespace", "stable"), tc))
+		require.NoError(t, cl.Delete(context.TODO(), tc))
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Zero(t, promtestutil.CollectAndCount(CredentialsExpiry))
+	})
+}
+
+func TestTokenRotation(t *testing.T) {
+	// given
+	defer gock.Off()
+	stable, sec := newToolchainCluster(t, "stable", "test-namespace", "https://cluster.com")
+	test.SetKubeConfigToken(t, sec, test.NewServiceAccountToken(t, time.Now().Add(time.Hour)))
+	test.SetupGockForServiceAccounts(t, "https://cluster.com", types.NamespacedName{Namespace: test.MemberOperatorNs, Name: "toolchaincluster-member"})
+	cl := test.NewFakeClient(t, stable, sec)
+	reset := setupCachedClusters(t, cl, stable)
+	defer reset()
+	service := cluster.NewToolchainClusterServiceWithClient(cl, logf.Log, test.MemberOperatorNs, 0, func(config *rest.Config, options runtimeclient.Options) (runtimeclient.Client, error) {
+		return test.NewFakeClient(t), nil
+	})
+	controller, req := prepareReconcile(stable, cl, requeAfter)
+	controller.TokenRotator = cluster.NewTokenRotator(&service)
+	controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (bool, error) {
+		return true, nil
+	}
+
+	// when
+	_, err := controller.Reconcile(context.TODO(), req)
+
+	// then
+	require.NoError(t, err)
+	cachedCluster, found := cluster.GetCachedToolchainCluster("stable")
+	require.True(t, found)
+	assert.Equal(t, "token-secret-for-toolchaincluster-member", cachedCluster.RestConfig.BearerToken)
+	secret := &corev1.Secret{}
+	require.NoError(t, cl.Get(context.TODO(), test.NamespacedName("test-namespace", "secret"), secret))
+	assert.NotEmpty(t, secret.Annotations[cluster.TokenExpiryAnnotationKey])
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	// given
+	registry := prometheus.NewRegistry()
+
+	// when
+	err := RegisterMetrics(registry)
+
+	// then
+	require.NoError(t, err)
+	require.Error(t, RegisterMetrics(registry))
+}
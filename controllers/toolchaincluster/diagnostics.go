@@ -0,0 +1,367 @@
+package toolchaincluster
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// ConnectivityConditionType is the type of the condition with the result of the connectivity diagnostics. The condition is set
+	// only when the cluster is not reachable and the diagnostics are enabled, it's removed as soon as the cluster is reachable again.
+	ConnectivityConditionType toolchainv1alpha1.ConditionType = "Connectivity"
+
+	ConnectivityVerifiedReason           = "ConnectivityVerified"
+	DNSResolutionFailedReason            = "DNSResolutionFailed"
+	TCPConnectFailedReason               = "TCPConnectFailed"
+	TLSHandshakeFailedReason             = "TLSHandshakeFailed"
+	CertificateUnknownAuthorityReason    = "CertificateUnknownAuthority"
+	CertificateNameMismatchReason        = "CertificateNameMismatch"
+	CertificateInvalidReason             = "CertificateInvalid"
+	UnauthorizedReason                   = "Unauthorized"
+	ForbiddenReason                      = "Forbidden"
+	AuthCheckFailedReason                = "AuthCheckFailed"
+	HealthzCheckFailedReason             = "HealthzCheckFailed"
+	InvalidConnectionConfigurationReason = "InvalidConnectionConfiguration"
+
+	// DefaultDiagnosticsStageTimeout is the default timeout of every stage of the connectivity diagnostics
+	DefaultDiagnosticsStageTimeout = 5 * time.Second
+	// DefaultDiagnosticsTimeout is the default timeout of all the stages of the connectivity diagnostics together
+	DefaultDiagnosticsTimeout = 10 * time.Second
+	// DefaultDiagnosticsInterval is the default minimal duration between two diagnostics of a cluster that remains not reachable
+	DefaultDiagnosticsInterval = 5 * time.Minute
+)
+
+// connectivityCondition returns the Connectivity condition reporting the failed stage of the report, if any
+func connectivityCondition(r cluster.ConnectivityReport) toolchainv1alpha1.Condition {
+	if failed, found := r.Failed(); found {
+		return toolchainv1alpha1.Condition{
+			Type:    ConnectivityConditionType,
+			Status:  corev1.ConditionFalse,
+			Reason:  failed.Reason,
+			Message: failed.Summary(),
+		}
+	}
+	stages := make([]string, 0, len(r.Stages))
+	for _, stage := range r.Stages {
+		stages = append(stages, string(stage.Stage))
+	}
+	return toolchainv1alpha1.Condition{
+		Type:    ConnectivityConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  ConnectivityVerifiedReason,
+		Message: fmt.Sprintf("the stages %s passed", strings.Join(stages, ", ")),
+	}
+}
+
+// ConnectivityDiagnostics breaks down the connection to a remote cluster into the stages - the DNS resolution, the TCP connection,
+// the TLS handshake, the authentication and the `/healthz` check - to find out at which one the connection fails.
+// When the cluster is reached through a proxy, the DNS and TCP stages check the proxy and the TLS stage is skipped, as the handshake
+// is done through the proxy by the following stages.
+type ConnectivityDiagnostics struct {
+	// StageTimeout is the timeout of every stage. Defaults to DefaultDiagnosticsStageTimeout.
+	StageTimeout time.Duration
+	// Timeout is the timeout of all the stages together, so the diagnostics don't block the reconcile for too long.
+	// Defaults to DefaultDiagnosticsTimeout.
+	Timeout time.Duration
+	// Interval is the minimal duration between two diagnostics of a cluster that remains not reachable - the last report
+	// is reused until then. The cluster is always diagnosed when it becomes not reachable. Defaults to DefaultDiagnosticsInterval.
+	Interval   time.Duration
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+}
+
+// connectivityCheck carries the state between the stages of the diagnostics
+type connectivityCheck struct {
+	restConfig *rest.Config
+	endpoint   *url.URL
+	proxy      *url.URL
+	// address is the host and port the TCP connection is opened to
+	address    string
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+	conn       net.Conn
+	client     *http.Client
+}
+
+// Diagnose checks the stages of the connection to the cluster with the given config, until the first one that fails
+func (d *ConnectivityDiagnostics) Diagnose(ctx context.Context, restConfig *rest.Config) cluster.ConnectivityReport {
+	return d.diagnose(ctx, restConfig, time.Now())
+}
+
+// diagnose does the same as Diagnose, the report has the given time
+func (d *ConnectivityDiagnostics) diagnose(ctx context.Context, restConfig *rest.Config, now time.Time) cluster.ConnectivityReport {
+	report := cluster.ConnectivityReport{Time: now}
+	ctx, cancel := context.WithTimeout(ctx, d.timeout())
+	defer cancel()
+	check := &connectivityCheck{restConfig: restConfig, lookupHost: net.DefaultResolver.LookupHost}
+	if d.lookupHost != nil {
+		check.lookupHost = d.lookupHost
+	}
+	defer check.close()
+
+	if err := check.resolveEndpoint(); err != nil {
+		report.Stages = append(report.Stages, cluster.StageResult{
+			Stage:   cluster.DNSStage,
+			Reason:  InvalidConnectionConfigurationReason,
+			Details: err.Error(),
+		})
+		return report
+	}
+	stages := []struct {
+		stage cluster.DiagnosticsStage
+		run   func(context.Context, *cluster.ConnectivityReport) cluster.StageResult
+	}{
+		{cluster.DNSStage, check.checkDNS},
+		{cluster.TCPStage, check.checkTCP},
+		{cluster.TLSStage, check.checkTLS},
+		{cluster.AuthStage, check.checkAuth},
+		{cluster.HealthzStage, check.checkHealthz},
+	}
+	for _, stage := range stages {
+		if stage.stage == cluster.TLSStage && (check.endpoint.Scheme != "https" || check.proxy != nil) {
+			continue
+		}
+		start := time.Now()
+		stageCtx, cancel := context.WithTimeout(ctx, d.stageTimeout())
+		result := stage.run(stageCtx, &report)
+		cancel()
+		result.Stage = stage.stage
+		result.Duration = time.Since(start)
+		report.Stages = append(report.Stages, result)
+		if !result.Passed {
+			break
+		}
+	}
+	return report
+}
+
+func (d *ConnectivityDiagnostics) stageTimeout() time.Duration {
+	if d.StageTimeout > 0 {
+		return d.StageTimeout
+	}
+	return DefaultDiagnosticsStageTimeout
+}
+
+func (d *ConnectivityDiagnostics) timeout() time.Duration {
+	if d.Timeout > 0 {
+		return d.Timeout
+	}
+	return DefaultDiagnosticsTimeout
+}
+
+func (d *ConnectivityDiagnostics) interval() time.Duration {
+	if d.Interval > 0 {
+		return d.Interval
+	}
+	return DefaultDiagnosticsInterval
+}
+
+func (c *connectivityCheck) checkDNS(ctx context.Context, _ *cluster.ConnectivityReport) cluster.StageResult {
+	host, _, err := net.SplitHostPort(c.address)
+	if err != nil {
+		return failedStage(InvalidConnectionConfigurationReason, "invalid address %s: %v", c.address, err)
+	}
+	subject := "the API server"
+	if c.proxy != nil {
+		subject = "the proxy"
+	}
+	if net.ParseIP(host) != nil {
+		return passedStage("the address of %s is the IP address %s", subject, host)
+	}
+	addresses, err := c.lookupHost(ctx, host)
+	if err != nil {
+		return failedStage(DNSResolutionFailedReason, "unable to resolve the host %s of %s: %v", host, subject, err)
+	}
+	return passedStage("the host %s of %s resolved to %s", host, subject, strings.Join(addresses, ", "))
+}
+
+// resolveEndpoint parses the URL of the API server and finds out the address the TCP connection should be opened to
+func (c *connectivityCheck) resolveEndpoint() error {
+	endpoint, _, err := rest.DefaultServerUrlFor(c.restConfig)
+	if err != nil {
+		return fmt.Errorf("invalid API endpoint: %w", err)
+	}
+	c.endpoint = endpoint
+	c.address = hostPort(endpoint)
+	if c.restConfig.Proxy != nil {
+		proxy, err := c.restConfig.Proxy(&http.Request{URL: endpoint})
+		if err != nil {
+			return fmt.Errorf("invalid proxy: %w", err)
+		}
+		if proxy != nil {
+			c.proxy = proxy
+			c.address = hostPort(proxy)
+		}
+	}
+	return nil
+}
+
+func (c *connectivityCheck) checkTCP(ctx context.Context, _ *cluster.ConnectivityReport) cluster.StageResult {
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", c.address)
+	if err != nil {
+		return failedStage(TCPConnectFailedReason, "unable to connect to %s: %v", c.address, err)
+	}
+	c.conn = conn
+	return passedStage("connected to %s", conn.RemoteAddr())
+}
+
+func (c *connectivityCheck) checkTLS(ctx context.Context, report *cluster.ConnectivityReport) cluster.StageResult {
+	tlsConfig, err := rest.TLSConfigFor(c.restConfig)
+	if err != nil {
+		return failedStage(InvalidConnectionConfigurationReason, "invalid TLS configuration: %v", err)
+	}
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{} // nolint:gosec // the minimal version is the one of the rest client
+	}
+	tlsConfig = tlsConfig.Clone()
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = c.endpoint.Hostname()
+	}
+	tlsConn := tls.Client(c.conn, tlsConfig)
+	c.conn = tlsConn
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		var verificationErr *tls.CertificateVerificationError
+		if !errors.As(err, &verificationErr) {
+			return failedStage(TLSHandshakeFailedReason, "the TLS handshake with %s failed: %v", c.address, err)
+		}
+		report.Certificates = certificateInfos(verificationErr.UnverifiedCertificates)
+		reason := TLSHandshakeFailedReason
+		var hostnameErr x509.HostnameError
+		var unknownAuthorityErr x509.UnknownAuthorityError
+		var invalidErr x509.CertificateInvalidError
+		switch {
+		case errors.As(verificationErr.Err, &hostnameErr):
+			reason = CertificateNameMismatchReason
+		case errors.As(verificationErr.Err, &unknownAuthorityErr):
+			reason = CertificateUnknownAuthorityReason
+		case errors.As(verificationErr.Err, &invalidErr):
+			reason = CertificateInvalidReason
+		}
+		return failedStage(reason, "the certificate of %s was rejected: %v (certificate chain: %s)",
+			tlsConfig.ServerName, verificationErr.Err, certificateChain(verificationErr.UnverifiedCertificates))
+	}
+	certificates := tlsConn.ConnectionState().PeerCertificates
+	report.Certificates = certificateInfos(certificates)
+	if tlsConfig.InsecureSkipVerify {
+		return passedStage("the TLS handshake with %s succeeded, the certificate was not verified (certificate chain: %s)",
+			tlsConfig.ServerName, certificateChain(certificates))
+	}
+	return passedStage("the certificate of %s was verified (certificate chain: %s)", tlsConfig.ServerName, certificateChain(certificates))
+}
+
+func (c *connectivityCheck) checkAuth(ctx context.Context, _ *cluster.ConnectivityReport) cluster.StageResult {
+	client, err := rest.HTTPClientFor(c.restConfig)
+	if err != nil {
+		return failedStage(InvalidConnectionConfigurationReason, "unable to create the HTTP client: %v", err)
+	}
+	c.client = client
+	statusCode, status, _, err := c.get(ctx, "api")
+	if err != nil {
+		return failedStage(AuthCheckFailedReason, "the request to %s failed: %v", c.endpoint.JoinPath("api"), err)
+	}
+	switch statusCode {
+	case http.StatusUnauthorized:
+		return failedStage(UnauthorizedReason, "the API server rejected the credentials (%s)", status)
+	case http.StatusForbidden:
+		return failedStage(ForbiddenReason, "the credentials were accepted, but the user is not allowed to discover the APIs (%s)", status)
+	}
+	return passedStage("the API server responded to the authenticated request with %s", status)
+}
+
+func (c *connectivityCheck) checkHealthz(ctx context.Context, _ *cluster.ConnectivityReport) cluster.StageResult {
+	statusCode, status, body, err := c.get(ctx, "healthz")
+	if err != nil {
+		return failedStage(HealthzCheckFailedReason, "the request to %s failed: %v", c.endpoint.JoinPath("healthz"), err)
+	}
+	if statusCode != http.StatusOK || !strings.EqualFold(body, "ok") {
+		return failedStage(HealthzCheckFailedReason, "/healthz responded with %s: %s", status, body)
+	}
+	return passedStage(healthzOk)
+}
+
+// get sends a GET request to the given path of the API server and returns the status and the (truncated) body of the response
+func (c *connectivityCheck) get(ctx context.Context, path string) (int, string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint.JoinPath(path).String(), nil)
+	if err != nil {
+		return 0, "", "", err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, "", "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return 0, "", "", err
+	}
+	return resp.StatusCode, resp.Status, strings.TrimSpace(string(body)), nil
+}
+
+func (c *connectivityCheck) close() {
+	if c.conn != nil {
+		_ = c.conn.Close()
+	}
+	if c.client != nil {
+		c.client.CloseIdleConnections()
+	}
+}
+
+func passedStage(format string, args ...any) cluster.StageResult {
+	return cluster.StageResult{Passed: true, Details: fmt.Sprintf(format, args...)}
+}
+
+func failedStage(reason, format string, args ...any) cluster.StageResult {
+	return cluster.StageResult{Reason: reason, Details: fmt.Sprintf(format, args...)}
+}
+
+func hostPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return net.JoinHostPort(u.Hostname(), port)
+	}
+	switch u.Scheme {
+	case "http":
+		return net.JoinHostPort(u.Hostname(), "80")
+	case "socks5":
+		return net.JoinHostPort(u.Hostname(), "1080")
+	}
+	return net.JoinHostPort(u.Hostname(), "443")
+}
+
+func certificateInfos(certificates []*x509.Certificate) []cluster.CertificateInfo {
+	infos := make([]cluster.CertificateInfo, 0, len(certificates))
+	for _, cert := range certificates {
+		info := cluster.CertificateInfo{
+			Subject:   cert.Subject.String(),
+			Issuer:    cert.Issuer.String(),
+			DNSNames:  cert.DNSNames,
+			NotBefore: cert.NotBefore,
+			NotAfter:  cert.NotAfter,
+		}
+		for _, ip := range cert.IPAddresses {
+			info.IPAddresses = append(info.IPAddresses, ip.String())
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func certificateChain(certificates []*x509.Certificate) string {
+	chain := make([]string, 0, len(certificates))
+	for _, cert := range certificates {
+		chain = append(chain, fmt.Sprintf("'%s' issued by '%s' valid until %s", cert.Subject, cert.Issuer, cert.NotAfter.UTC().Format(time.RFC3339)))
+	}
+	return strings.Join(chain, " <- ")
+}
@@ -0,0 +1,457 @@
+package toolchaincluster
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+	corev1 "k8s.io/api/core/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestConnectivityDiagnostics(t *testing.T) {
+	// the API server accepting only the "valid" token and allowing only the "valid" user to discover the APIs
+	newAPIServer := func(t *testing.T, healthz string) *httptest.Server {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Header.Get("Authorization") {
+			case "Bearer valid":
+			case "Bearer forbidden":
+				w.WriteHeader(http.StatusForbidden)
+				return
+			default:
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Path == "/healthz" {
+				if healthz != "ok" {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+				_, _ = w.Write([]byte(healthz))
+				return
+			}
+			_, _ = w.Write([]byte("{}"))
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	restConfigFor := func(server *httptest.Server, token string) *rest.Config {
+		return &rest.Config{
+			Host:        server.URL,
+			BearerToken: token,
+			TLSClientConfig: rest.TLSClientConfig{
+				CAData: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}),
+			},
+		}
+	}
+	stagesOf := func(report cluster.ConnectivityReport) []cluster.DiagnosticsStage {
+		var stages []cluster.DiagnosticsStage
+		for _, stage := range report.Stages {
+			stages = append(stages, stage.Stage)
+		}
+		return stages
+	}
+	allStages := []cluster.DiagnosticsStage{cluster.DNSStage, cluster.TCPStage, cluster.TLSStage, cluster.AuthStage, cluster.HealthzStage}
+	diagnostics := &ConnectivityDiagnostics{StageTimeout: 5 * time.Second}
+
+	t.Run("all stages pass", func(t *testing.T) {
+		// given
+		server := newAPIServer(t, "ok")
+
+		// when
+		report := diagnostics.Diagnose(context.TODO(), restConfigFor(server, "valid"))
+
+		// then
+		assert.Equal(t, allStages, stagesOf(report))
+		_, failed := report.Failed()
+		assert.False(t, failed)
+		require.Len(t, report.Certificates, 1)
+		assert.Contains(t, report.Certificates[0].IPAddresses, "127.0.0.1")
+		assert.Contains(t, report.Stages[2].Details, "was verified")
+		assert.Equal(t, toolchainv1alpha1.Condition{
+			Type:    ConnectivityConditionType,
+			Status:  corev1.ConditionTrue,
+			Reason:  ConnectivityVerifiedReason,
+			Message: "the stages DNS, TCP, TLS, Auth, Healthz passed",
+		}, connectivityCondition(report))
+	})
+
+	t.Run("TLS stage is skipped for plain HTTP", func(t *testing.T) {
+		// given
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+
+		// when
+		report := diagnostics.Diagnose(context.TODO(), &rest.Config{Host: server.URL})
+
+		// then
+		assert.Equal(t, []cluster.DiagnosticsStage{cluster.DNSStage, cluster.TCPStage, cluster.AuthStage, cluster.HealthzStage}, stagesOf(report))
+		_, failed := report.Failed()
+		assert.False(t, failed)
+		assert.Empty(t, report.Certificates)
+	})
+
+	t.Run("DNS resolution fails", func(t *testing.T) {
+		// given
+		diagnostics := &ConnectivityDiagnostics{
+			lookupHost: func(_ context.Context, host string) ([]string, error) {
+				return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+			},
+		}
+
+		// when
+		report := diagnostics.Diagnose(context.TODO(), &rest.Config{Host: "https://api.cluster.example:6443"})
+
+		// then
+		assertFailedStage(t, report, cluster.DNSStage, DNSResolutionFailedReason,
+			"unable to resolve the host api.cluster.example of the API server: lookup api.cluster.example: no such host")
+		assert.Len(t, report.Stages, 1)
+	})
+
+	t.Run("all stages are limited by the timeout", func(t *testing.T) {
+		// given
+		diagnostics := &ConnectivityDiagnostics{
+			StageTimeout: time.Hour,
+			Timeout:      10 * time.Millisecond,
+			lookupHost: func(ctx context.Context, _ string) ([]string, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+		}
+
+		// when
+		report := diagnostics.Diagnose(context.TODO(), &rest.Config{Host: "https://api.cluster.example:6443"})
+
+		// then
+		failed := assertFailedStage(t, report, cluster.DNSStage, DNSResolutionFailedReason, "")
+		assert.Contains(t, failed.Details, "context deadline exceeded")
+		assert.Less(t, failed.Duration, time.Second)
+	})
+
+	t.Run("DNS resolves the host", func(t *testing.T) {
+		// given
+		server := newAPIServer(t, "ok")
+		serverURL, err := url.Parse(server.URL)
+		require.NoError(t, err)
+		diagnostics := &ConnectivityDiagnostics{
+			lookupHost: func(_ context.Context, host string) ([]string, error) {
+				if host != "api.cluster.example" {
+					return nil, fmt.Errorf("unexpected host %s", host)
+				}
+				return []string{"127.0.0.1"}, nil
+			},
+		}
+		restConfig := restConfigFor(server, "valid")
+		restConfig.Host = "https://api.cluster.example:" + serverURL.Port()
+
+		// when
+		report := diagnostics.Diagnose(context.TODO(), restConfig)
+
+		// then
+		require.NotEmpty(t, report.Stages)
+		assert.True(t, report.Stages[0].Passed)
+		assert.Equal(t, "the host api.cluster.example of the API server resolved to 127.0.0.1", report.Stages[0].Details)
+		// the address is resolved by the system resolver when the TCP connection is opened
+		assert.Equal(t, cluster.TCPStage, report.Stages[1].Stage)
+	})
+
+	t.Run("TCP connection fails", func(t *testing.T) {
+		// given
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		address := listener.Addr().String()
+		require.NoError(t, listener.Close())
+
+		// when
+		report := diagnostics.Diagnose(context.TODO(), &rest.Config{Host: "https://" + address})
+
+		// then
+		failed := assertFailedStage(t, report, cluster.TCPStage, TCPConnectFailedReason, "")
+		assert.Contains(t, failed.Details, "unable to connect to "+address)
+		assert.Contains(t, failed.Details, "connection refused")
+	})
+
+	t.Run("TLS handshake fails", func(t *testing.T) {
+		t.Run("unknown authority", func(t *testing.T) {
+			// given
+			server := newAPIServer(t, "ok")
+			restConfig := restConfigFor(server, "valid")
+			restConfig.CAData = nil
+
+			// when
+			report := diagnostics.Diagnose(context.TODO(), restConfig)
+
+			// then
+			failed := assertFailedStage(t, report, cluster.TLSStage, CertificateUnknownAuthorityReason, "")
+			assert.Contains(t, failed.Details, "the certificate of 127.0.0.1 was rejected: x509: certificate signed by unknown authority")
+			assert.Contains(t, failed.Details, "certificate chain: 'O=Acme Co' issued by 'O=Acme Co'")
+			require.Len(t, report.Certificates, 1)
+			assert.Equal(t, "O=Acme Co", report.Certificates[0].Issuer)
+		})
+
+		t.Run("SAN mismatch", func(t *testing.T) {
+			// given
+			server := newAPIServer(t, "ok")
+			restConfig := restConfigFor(server, "valid")
+			restConfig.ServerName = "api.other.example"
+
+			// when
+			report := diagnostics.Diagnose(context.TODO(), restConfig)
+
+			// then
+			failed := assertFailedStage(t, report, cluster.TLSStage, CertificateNameMismatchReason, "")
+			assert.Contains(t, failed.Details, "the certificate of api.other.example was rejected: x509: certificate is valid for example.com")
+			assert.Contains(t, failed.Details, "not api.other.example")
+			require.Len(t, report.Certificates, 1)
+			assert.Contains(t, report.Certificates[0].DNSNames, "example.com")
+		})
+
+		t.Run("not TLS", func(t *testing.T) {
+			// given
+			server := httptest.NewServer(http.NotFoundHandler())
+			defer server.Close()
+
+			// when
+			report := diagnostics.Diagnose(context.TODO(), &rest.Config{Host: strings.Replace(server.URL, "http://", "https://", 1)})
+
+			// then
+			failed := assertFailedStage(t, report, cluster.TLSStage, TLSHandshakeFailedReason, "")
+			assert.Contains(t, failed.Details, "the TLS handshake with "+strings.TrimPrefix(server.URL, "http://")+" failed")
+			assert.Empty(t, report.Certificates)
+		})
+
+		t.Run("not verified with insecure config", func(t *testing.T) {
+			// given
+			server := newAPIServer(t, "ok")
+			restConfig := restConfigFor(server, "valid")
+			restConfig.CAData = nil
+			restConfig.Insecure = true
+
+			// when
+			report := diagnostics.Diagnose(context.TODO(), restConfig)
+
+			// then
+			assert.Equal(t, allStages, stagesOf(report))
+			assert.True(t, report.Stages[2].Passed)
+			assert.Contains(t, report.Stages[2].Details, "the certificate was not verified")
+		})
+	})
+
+	t.Run("authentication fails", func(t *testing.T) {
+		t.Run("unauthorized", func(t *testing.T) {
+			// given
+			server := newAPIServer(t, "ok")
+
+			// when
+			report := diagnostics.Diagnose(context.TODO(), restConfigFor(server, "expired"))
+
+			// then
+			assertFailedStage(t, report, cluster.AuthStage, UnauthorizedReason, "the API server rejected the credentials (401 Unauthorized)")
+			assert.Equal(t, toolchainv1alpha1.Condition{
+				Type:    ConnectivityConditionType,
+				Status:  corev1.ConditionFalse,
+				Reason:  UnauthorizedReason,
+				Message: "the Auth stage failed: the API server rejected the credentials (401 Unauthorized)",
+			}, connectivityCondition(report))
+		})
+
+		t.Run("forbidden", func(t *testing.T) {
+			// given
+			server := newAPIServer(t, "ok")
+
+			// when
+			report := diagnostics.Diagnose(context.TODO(), restConfigFor(server, "forbidden"))
+
+			// then
+			assertFailedStage(t, report, cluster.AuthStage, ForbiddenReason,
+				"the credentials were accepted, but the user is not allowed to discover the APIs (403 Forbidden)")
+		})
+	})
+
+	t.Run("healthz fails", func(t *testing.T) {
+		// given
+		server := newAPIServer(t, "[-]etcd failed: reason withheld")
+
+		// when
+		report := diagnostics.Diagnose(context.TODO(), restConfigFor(server, "valid"))
+
+		// then
+		assertFailedStage(t, report, cluster.HealthzStage, HealthzCheckFailedReason,
+			"/healthz responded with 500 Internal Server Error: [-]etcd failed: reason withheld")
+	})
+
+	t.Run("proxy is checked instead of the API server", func(t *testing.T) {
+		// given
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		proxyAddress := listener.Addr().String()
+		require.NoError(t, listener.Close())
+		proxyURL, err := url.Parse("http://" + proxyAddress)
+		require.NoError(t, err)
+		restConfig := &rest.Config{Host: "https://api.cluster.example:6443", Proxy: http.ProxyURL(proxyURL)}
+
+		// when
+		report := diagnostics.Diagnose(context.TODO(), restConfig)
+
+		// then
+		assert.Equal(t, "the address of the proxy is the IP address 127.0.0.1", report.Stages[0].Details)
+		failed := assertFailedStage(t, report, cluster.TCPStage, TCPConnectFailedReason, "")
+		assert.Contains(t, failed.Details, "unable to connect to "+proxyAddress)
+	})
+
+	t.Run("invalid API endpoint", func(t *testing.T) {
+		// when
+		report := diagnostics.Diagnose(context.TODO(), &rest.Config{Host: "https://api cluster"})
+
+		// then
+		failed := assertFailedStage(t, report, cluster.DNSStage, InvalidConnectionConfigurationReason, "")
+		assert.Contains(t, failed.Details, "invalid API endpoint")
+	})
+}
+
+func TestReconcileWithConnectivityDiagnostics(t *testing.T) {
+	// given
+	defer gock.Off()
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	// the kubeconfig doesn't contain the CA of the server
+	unreachable, sec := newToolchainCluster(t, "unreachable", "test-namespace", server.URL)
+	cl := test.NewFakeClient(t, unreachable, sec)
+	reset := setupCachedClusters(t, cl, unreachable)
+	defer reset()
+	controller, req := prepareReconcile(unreachable, cl, requeAfter)
+	controller.ConnectivityDiagnostics = &ConnectivityDiagnostics{}
+	// the report is published by a patch
+	cl.MockUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+		return errors.New("unexpected update")
+	}
+	clock := newTestClock()
+	controller.now = clock.now
+	healthy := false
+	controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (bool, error) {
+		if !healthy {
+			return false, errors.New("tls: failed to verify certificate")
+		}
+		return true, nil
+	}
+
+	t.Run("failed stage is reported", func(t *testing.T) {
+		// when
+		recResult, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		require.Equal(t, reconcile.Result{RequeueAfter: requeAfter}, recResult)
+		tc := &toolchainv1alpha1.ToolchainCluster{}
+		require.NoError(t, cl.Get(context.TODO(), req.NamespacedName, tc))
+		require.Len(t, tc.Status.Conditions, 2)
+		ready := tc.Status.Conditions[0]
+		assert.Equal(t, toolchainv1alpha1.ToolchainClusterClusterNotReachableReason, ready.Reason)
+		assert.True(t, strings.HasPrefix(ready.Message, "tls: failed to verify certificate; the TLS stage failed: the certificate of 127.0.0.1 was rejected"), ready.Message)
+		connectivity := tc.Status.Conditions[1]
+		assert.Equal(t, ConnectivityConditionType, connectivity.Type)
+		assert.Equal(t, corev1.ConditionFalse, connectivity.Status)
+		assert.Equal(t, CertificateUnknownAuthorityReason, connectivity.Reason)
+
+		history, found := controller.ProbeHistory.Get("unreachable")
+		require.True(t, found)
+		require.Len(t, history.Records, 1)
+		require.NotNil(t, history.Records[0].Connectivity)
+		failed, found := history.Records[0].Connectivity.Failed()
+		require.True(t, found)
+		assert.Equal(t, cluster.TLSStage, failed.Stage)
+
+		published, err := cluster.GetConnectivityReport(tc)
+		require.NoError(t, err)
+		assert.Equal(t, history.Records[0].Connectivity.Time.UTC(), published.Time.UTC())
+		publishedFailed, found := published.Failed()
+		require.True(t, found)
+		assert.Equal(t, CertificateUnknownAuthorityReason, publishedFailed.Reason)
+		require.NotEmpty(t, published.Certificates)
+		assert.False(t, published.Certificates[0].NotAfter.IsZero())
+	})
+
+	t.Run("diagnostics are not repeated within the interval", func(t *testing.T) {
+		// given
+		clock.advance(requeAfter)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		history, _ := controller.ProbeHistory.Get("unreachable")
+		require.Len(t, history.Records, 2)
+		assert.Same(t, history.Records[0].Connectivity, history.Records[1].Connectivity)
+		tc := &toolchainv1alpha1.ToolchainCluster{}
+		require.NoError(t, cl.Get(context.TODO(), req.NamespacedName, tc))
+		ready, _ := condition.FindConditionByType(tc.Status.Conditions, toolchainv1alpha1.ConditionReady)
+		assert.Contains(t, ready.Message, "the TLS stage failed")
+	})
+
+	t.Run("diagnostics are repeated after the interval", func(t *testing.T) {
+		// given
+		controller.ConnectivityDiagnostics.Interval = requeAfter
+		clock.advance(requeAfter)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		history, _ := controller.ProbeHistory.Get("unreachable")
+		require.Len(t, history.Records, 3)
+		require.NotNil(t, history.Records[2].Connectivity)
+		assert.True(t, history.Records[2].Connectivity.Time.After(history.Records[1].Connectivity.Time))
+	})
+
+	t.Run("condition is removed when the cluster is reachable", func(t *testing.T) {
+		// given
+		clock.advance(requeAfter)
+		healthy = true
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assertClusterStatus(t, cl, "unreachable", clusterReadyCondition())
+		history, _ := controller.ProbeHistory.Get("unreachable")
+		assert.Nil(t, history.Records[len(history.Records)-1].Connectivity)
+		tc := &toolchainv1alpha1.ToolchainCluster{}
+		require.NoError(t, cl.Get(context.TODO(), req.NamespacedName, tc))
+		assert.NotContains(t, tc.Annotations, cluster.ConnectivityAnnotationKey)
+	})
+}
+
+func assertFailedStage(t *testing.T, report cluster.ConnectivityReport, stage cluster.DiagnosticsStage, reason, details string) cluster.StageResult {
+	t.Helper()
+	failed, found := report.Failed()
+	require.True(t, found, "no stage failed: %+v", report.Stages)
+	assert.Equal(t, stage, failed.Stage)
+	assert.Equal(t, reason, failed.Reason)
+	if details != "" {
+		assert.Equal(t, details, failed.Details)
+	}
+	assert.Equal(t, failed, report.Stages[len(report.Stages)-1])
+	return failed
+}
@@ -0,0 +1,136 @@
+package toolchaincluster
+
+import (
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+)
+
+// DefaultProbeHistorySize is the default number of the latest health checks kept in the ProbeHistory for every cluster
+const DefaultProbeHistorySize = 10
+
+// ProbeRecord is the result of a single health check of a cluster (ie. of all the configured probes)
+type ProbeRecord struct {
+	// Time when the health check started
+	Time time.Time
+	// Healthy is true if the cluster was reachable and all the probes passed
+	Healthy bool
+	// Reason and Message of the Ready condition computed from the results of the probes
+	Reason  string
+	Message string
+	// Latency is the duration of all the probes
+	Latency time.Duration
+	// Connectivity is the result of the connectivity diagnostics, set only if the cluster was not reachable and the diagnostics are enabled
+	Connectivity *cluster.ConnectivityReport
+}
+
+// LatencyStats are the statistics of the latencies of the health checks kept in the history
+type LatencyStats struct {
+	Last    time.Duration
+	Min     time.Duration
+	Max     time.Duration
+	Average time.Duration
+}
+
+// ClusterProbeHistory is a snapshot of the health check history of a single cluster
+type ClusterProbeHistory struct {
+	// Records are the latest health checks, the oldest one first
+	Records []ProbeRecord
+	// ConsecutiveFailures is the number of the latest health checks that failed in a row (zero if the last one passed)
+	ConsecutiveFailures int
+	// ConsecutiveSuccesses is the number of the latest health checks that passed in a row (zero if the last one failed)
+	ConsecutiveSuccesses int
+	// Latency contains the statistics computed from the Records
+	Latency LatencyStats
+}
+
+// ProbeHistory keeps a rolling in-memory history of the health checks of the ToolchainClusters. It's safe for concurrent use.
+type ProbeHistory struct {
+	size     int
+	mu       sync.RWMutex
+	clusters map[string]*ClusterProbeHistory
+}
+
+// NewProbeHistory creates a new ProbeHistory keeping the given number of the latest health checks of every cluster.
+// DefaultProbeHistorySize is used if the size is not positive.
+func NewProbeHistory(size int) *ProbeHistory {
+	if size <= 0 {
+		size = DefaultProbeHistorySize
+	}
+	return &ProbeHistory{
+		size:     size,
+		clusters: map[string]*ClusterProbeHistory{},
+	}
+}
+
+// Get returns a snapshot of the history of the cluster with the given name
+func (h *ProbeHistory) Get(clusterName string) (ClusterProbeHistory, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	history, found := h.clusters[clusterName]
+	if !found {
+		return ClusterProbeHistory{}, false
+	}
+	return history.snapshot(), true
+}
+
+// record adds the record to the history of the cluster and returns the snapshot of the updated history
+func (h *ProbeHistory) record(clusterName string, record ProbeRecord) ClusterProbeHistory {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	history, found := h.clusters[clusterName]
+	if !found {
+		history = &ClusterProbeHistory{}
+		h.clusters[clusterName] = history
+	}
+	history.Records = append(history.Records, record)
+	if len(history.Records) > h.size {
+		history.Records = history.Records[len(history.Records)-h.size:]
+	}
+	if record.Healthy {
+		history.ConsecutiveSuccesses++
+		history.ConsecutiveFailures = 0
+	} else {
+		history.ConsecutiveFailures++
+		history.ConsecutiveSuccesses = 0
+	}
+	history.Latency = latencyStats(history.Records)
+	return history.snapshot()
+}
+
+// forget removes the history of the cluster with the given name
+func (h *ProbeHistory) forget(clusterName string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clusters, clusterName)
+}
+
+func (h *ClusterProbeHistory) snapshot() ClusterProbeHistory {
+	snapshot := *h
+	snapshot.Records = append([]ProbeRecord(nil), h.Records...)
+	return snapshot
+}
+
+func latencyStats(records []ProbeRecord) LatencyStats {
+	if len(records) == 0 {
+		return LatencyStats{}
+	}
+	stats := LatencyStats{
+		Last: records[len(records)-1].Latency,
+		Min:  records[0].Latency,
+		Max:  records[0].Latency,
+	}
+	var total time.Duration
+	for _, record := range records {
+		total += record.Latency
+		if record.Latency < stats.Min {
+			stats.Min = record.Latency
+		}
+		if record.Latency > stats.Max {
+			stats.Max = record.Latency
+		}
+	}
+	stats.Average = total / time.Duration(len(records))
+	return stats
+}
@@ -0,0 +1,99 @@
+package toolchaincluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeHistory(t *testing.T) {
+	record := func(healthy bool, latency time.Duration) ProbeRecord {
+		return ProbeRecord{Time: time.Now(), Healthy: healthy, Latency: latency}
+	}
+
+	t.Run("consecutive failures and successes", func(t *testing.T) {
+		// given
+		history := NewProbeHistory(10)
+
+		// when
+		history.record("member", record(true, time.Millisecond))
+		history.record("member", record(false, time.Millisecond))
+		snapshot := history.record("member", record(false, time.Millisecond))
+
+		// then
+		assert.Equal(t, 2, snapshot.ConsecutiveFailures)
+		assert.Equal(t, 0, snapshot.ConsecutiveSuccesses)
+
+		t.Run("success resets the failures", func(t *testing.T) {
+			// when
+			snapshot := history.record("member", record(true, time.Millisecond))
+
+			// then
+			assert.Equal(t, 0, snapshot.ConsecutiveFailures)
+			assert.Equal(t, 1, snapshot.ConsecutiveSuccesses)
+		})
+	})
+
+	t.Run("only the latest records are kept", func(t *testing.T) {
+		// given
+		history := NewProbeHistory(3)
+
+		// when
+		for i := 1; i <= 5; i++ {
+			history.record("member", record(false, time.Duration(i)*time.Second))
+		}
+
+		// then
+		snapshot, found := history.Get("member")
+		require.True(t, found)
+		require.Len(t, snapshot.Records, 3)
+		assert.Equal(t, 3*time.Second, snapshot.Records[0].Latency)
+		assert.Equal(t, 5*time.Second, snapshot.Records[2].Latency)
+		// the counter is not limited by the size of the history
+		assert.Equal(t, 5, snapshot.ConsecutiveFailures)
+		assert.Equal(t, LatencyStats{
+			Last:    5 * time.Second,
+			Min:     3 * time.Second,
+			Max:     5 * time.Second,
+			Average: 4 * time.Second,
+		}, snapshot.Latency)
+	})
+
+	t.Run("snapshot is not modified by new records", func(t *testing.T) {
+		// given
+		history := NewProbeHistory(2)
+		snapshot := history.record("member", record(true, time.Second))
+
+		// when
+		history.record("member", record(false, 2*time.Second))
+		history.record("member", record(false, 3*time.Second))
+
+		// then
+		require.Len(t, snapshot.Records, 1)
+		assert.Equal(t, time.Second, snapshot.Records[0].Latency)
+	})
+
+	t.Run("clusters are tracked separately and can be forgotten", func(t *testing.T) {
+		// given
+		history := NewProbeHistory(0)
+		history.record("member-1", record(true, time.Second))
+		history.record("member-2", record(false, time.Second))
+
+		// when
+		history.forget("member-1")
+
+		// then
+		_, found := history.Get("member-1")
+		assert.False(t, found)
+		snapshot, found := history.Get("member-2")
+		require.True(t, found)
+		assert.Equal(t, 1, snapshot.ConsecutiveFailures)
+	})
+
+	t.Run("default size", func(t *testing.T) {
+		assert.Equal(t, DefaultProbeHistorySize, NewProbeHistory(0).size)
+		assert.Equal(t, DefaultProbeHistorySize, NewProbeHistory(-1).size)
+	})
+}
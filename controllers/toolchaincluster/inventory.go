@@ -0,0 +1,192 @@
+package toolchaincluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var (
+	clusterVersionResource = schema.GroupVersionResource{Group: "config.openshift.io", Version: "v1", Resource: "clusterversions"}
+	crdResource            = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+)
+
+// InventoryCollector collects some of the facts about a remote cluster. The facts collected by all the collectors configured
+// in the Reconciler are published in the inventory annotation of the ToolchainCluster (see cluster.GetInventory).
+type InventoryCollector interface {
+	// Name identifies the collector (eg. in the logs and in the errors of the inventory)
+	Name() string
+	// Collect sets the facts about the remote cluster in the inventory
+	Collect(ctx context.Context, target ProbeTarget, inventory *cluster.Inventory) error
+}
+
+// DefaultInventoryCollectors returns the collectors of the versions and the nodes of the cluster and the collector checking
+// the given CustomResourceDefinitions, if any
+func DefaultInventoryCollectors(crdNames ...string) []InventoryCollector {
+	collectors := []InventoryCollector{VersionCollector(), NodesCollector()}
+	if len(crdNames) > 0 {
+		collectors = append(collectors, CRDsCollector(crdNames...))
+	}
+	return collectors
+}
+
+type versionCollector struct{}
+
+// VersionCollector returns the collector of the Kubernetes version and, if the cluster is an OpenShift one, of the OpenShift version
+func VersionCollector() InventoryCollector {
+	return &versionCollector{}
+}
+
+func (c *versionCollector) Name() string {
+	return "version"
+}
+
+func (c *versionCollector) Collect(ctx context.Context, target ProbeTarget, inventory *cluster.Inventory) error {
+	info, err := target.Clientset.DiscoveryClient.ServerVersion()
+	if err != nil {
+		return err
+	}
+	inventory.KubernetesVersion = info.GitVersion
+
+	dynamicClient, err := target.Cluster.DynamicClient()
+	if err != nil {
+		return err
+	}
+	clusterVersion, err := dynamicClient.Resource(clusterVersionResource).Get(ctx, "version", metav1.GetOptions{})
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			// not an OpenShift cluster
+			return nil
+		}
+		return fmt.Errorf("unable to get the OpenShift version: %w", err)
+	}
+	openShiftVersion, _, err := unstructured.NestedString(clusterVersion.Object, "status", "desired", "version")
+	if err != nil {
+		return fmt.Errorf("unable to get the OpenShift version: %w", err)
+	}
+	inventory.OpenShiftVersion = openShiftVersion
+	return nil
+}
+
+type nodesCollector struct{}
+
+// NodesCollector returns the collector of the number of the nodes and of their allocatable CPU and memory by the node roles
+func NodesCollector() InventoryCollector {
+	return &nodesCollector{}
+}
+
+func (c *nodesCollector) Name() string {
+	return "nodes"
+}
+
+func (c *nodesCollector) Collect(ctx context.Context, target ProbeTarget, inventory *cluster.Inventory) error {
+	nodes, err := target.Clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	inventory.NodeCount = len(nodes.Items)
+	inventory.NodeRoles = map[string]cluster.NodeRoleCapacity{}
+	for _, node := range nodes.Items {
+		for _, role := range nodeRoles(node) {
+			capacity := inventory.NodeRoles[role]
+			capacity.Nodes++
+			capacity.AllocatableCPU = sum(capacity.AllocatableCPU, node.Status.Allocatable[corev1.ResourceCPU])
+			capacity.AllocatableMemory = sum(capacity.AllocatableMemory, node.Status.Allocatable[corev1.ResourceMemory])
+			inventory.NodeRoles[role] = capacity
+		}
+	}
+	return nil
+}
+
+func nodeRoles(node corev1.Node) []string {
+	var roles []string
+	for label := range node.Labels {
+		if role, found := strings.CutPrefix(label, cluster.NodeRoleLabelPrefix); found && role != "" {
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) == 0 {
+		return []string{cluster.NoNodeRole}
+	}
+	return roles
+}
+
+func sum(total, value resource.Quantity) resource.Quantity {
+	total.Add(value)
+	return total
+}
+
+type crdsCollector struct {
+	names []string
+}
+
+// CRDsCollector returns the collector checking whether the CustomResourceDefinitions with the given names
+// (eg. `virtualmachines.kubevirt.io`) are installed
+func CRDsCollector(names ...string) InventoryCollector {
+	return &crdsCollector{names: names}
+}
+
+func (c *crdsCollector) Name() string {
+	return "crds"
+}
+
+func (c *crdsCollector) Collect(ctx context.Context, target ProbeTarget, inventory *cluster.Inventory) error {
+	dynamicClient, err := target.Cluster.DynamicClient()
+	if err != nil {
+		return err
+	}
+	crds := make(map[string]bool, len(c.names))
+	for _, name := range c.names {
+		_, err := dynamicClient.Resource(crdResource).Get(ctx, name, metav1.GetOptions{})
+		switch {
+		case err == nil:
+			crds[name] = true
+		case kerrors.IsNotFound(err):
+			crds[name] = false
+		default:
+			return fmt.Errorf("unable to check the CustomResourceDefinition %s: %w", name, err)
+		}
+	}
+	inventory.CRDs = crds
+	return nil
+}
+
+// collectInventory runs all the configured collectors. The errors of the collectors are recorded in the inventory,
+// so the facts collected by the other collectors are still published.
+func (r *Reconciler) collectInventory(ctx context.Context, target ProbeTarget) cluster.Inventory {
+	inventory := cluster.Inventory{}
+	for _, collector := range r.InventoryCollectors {
+		if err := collector.Collect(ctx, target, &inventory); err != nil {
+			log.FromContext(ctx).Error(err, "unable to collect the inventory of the cluster", "collector", collector.Name())
+			if inventory.Errors == nil {
+				inventory.Errors = map[string]string{}
+			}
+			inventory.Errors[collector.Name()] = err.Error()
+		}
+	}
+	return inventory
+}
+
+// publishInventory collects the inventory and patches the ToolchainCluster if the inventory changed
+func (r *Reconciler) publishInventory(ctx context.Context, toolchainCluster *toolchainv1alpha1.ToolchainCluster, target ProbeTarget) error {
+	patch := client.MergeFrom(toolchainCluster.DeepCopy())
+	changed, err := cluster.SetInventory(toolchainCluster, r.collectInventory(ctx, target))
+	if err != nil || !changed {
+		return err
+	}
+	if err := r.Client.Patch(ctx, toolchainCluster, patch); err != nil {
+		return fmt.Errorf("failed to update the inventory of cluster - %s: %w", toolchainCluster.Name, err)
+	}
+	return nil
+}
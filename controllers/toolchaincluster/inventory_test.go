@@ -0,0 +1,251 @@
+package toolchaincluster
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const inventoryNodes = `{"kind":"NodeList","apiVersion":"v1","items":[
+{"metadata":{"name":"master-0","labels":{"node-role.kubernetes.io/master":"","node-role.kubernetes.io/control-plane":""}},
+ "status":{"allocatable":{"cpu":"3500m","memory":"14Gi"}}},
+{"metadata":{"name":"worker-0","labels":{"node-role.kubernetes.io/worker":""}},
+ "status":{"allocatable":{"cpu":"7500m","memory":"30Gi"}}},
+{"metadata":{"name":"worker-1","labels":{"node-role.kubernetes.io/worker":""}},
+ "status":{"allocatable":{"cpu":"7500m","memory":"30Gi"}}},
+{"metadata":{"name":"unlabeled"},
+ "status":{"allocatable":{"cpu":"2","memory":"8Gi"}}}]}`
+
+func mockInventory(apiEndpoint string, openShift bool) {
+	gock.New(apiEndpoint).
+		Get("api/v1/nodes").
+		Persist().
+		Reply(200).
+		SetHeader("Content-Type", "application/json").
+		BodyString(inventoryNodes)
+	// registered before the /version, which would match the path of the ClusterVersion as well
+	if openShift {
+		gock.New(apiEndpoint).
+			Get("apis/config.openshift.io/v1/clusterversions/version").
+			Persist().
+			Reply(200).
+			SetHeader("Content-Type", "application/json").
+			BodyString(`{"apiVersion":"config.openshift.io/v1","kind":"ClusterVersion","metadata":{"name":"version"},"status":{"desired":{"version":"4.18.3"}}}`)
+	} else {
+		gock.New(apiEndpoint).
+			Get("apis/config.openshift.io/v1/clusterversions/version").
+			Persist().
+			Reply(404)
+	}
+	gock.New(apiEndpoint).
+		Get("version").
+		Persist().
+		Reply(200).
+		SetHeader("Content-Type", "application/json").
+		BodyString(`{"major":"1","minor":"31","gitVersion":"v1.31.4"}`)
+	gock.New(apiEndpoint).
+		Get("apis/apiextensions.k8s.io/v1/customresourcedefinitions/virtualmachines.kubevirt.io").
+		Persist().
+		Reply(200).
+		SetHeader("Content-Type", "application/json").
+		BodyString(`{"apiVersion":"apiextensions.k8s.io/v1","kind":"CustomResourceDefinition","metadata":{"name":"virtualmachines.kubevirt.io"}}`)
+	gock.New(apiEndpoint).
+		Get("apis/apiextensions.k8s.io/v1/customresourcedefinitions/").
+		Persist().
+		Reply(404)
+}
+
+func TestInventoryCollectors(t *testing.T) {
+	// given
+	defer gock.Off()
+	mockInventory("https://openshift.com", true)
+	mockInventory("https://kubernetes.com", false)
+	gock.New("https://broken.com").
+		Get("").
+		Persist().
+		Reply(500)
+
+	t.Run("version", func(t *testing.T) {
+		t.Run("OpenShift", func(t *testing.T) {
+			// given
+			target, reset := newProbeTarget(t, "openshift", "https://openshift.com")
+			defer reset()
+			inventory := &cluster.Inventory{}
+
+			// when
+			err := VersionCollector().Collect(context.TODO(), target, inventory)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, "v1.31.4", inventory.KubernetesVersion)
+			assert.Equal(t, "4.18.3", inventory.OpenShiftVersion)
+		})
+
+		t.Run("Kubernetes", func(t *testing.T) {
+			// given
+			target, reset := newProbeTarget(t, "kubernetes", "https://kubernetes.com")
+			defer reset()
+			inventory := &cluster.Inventory{}
+
+			// when
+			err := VersionCollector().Collect(context.TODO(), target, inventory)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, "v1.31.4", inventory.KubernetesVersion)
+			assert.Empty(t, inventory.OpenShiftVersion)
+		})
+	})
+
+	t.Run("nodes", func(t *testing.T) {
+		// given
+		target, reset := newProbeTarget(t, "kubernetes", "https://kubernetes.com")
+		defer reset()
+		inventory := &cluster.Inventory{}
+
+		// when
+		err := NodesCollector().Collect(context.TODO(), target, inventory)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 4, inventory.NodeCount)
+		require.Len(t, inventory.NodeRoles, 4)
+		assertNodeRole(t, inventory, "worker", 2, "15", "60Gi")
+		assertNodeRole(t, inventory, "master", 1, "3500m", "14Gi")
+		assertNodeRole(t, inventory, "control-plane", 1, "3500m", "14Gi")
+		assertNodeRole(t, inventory, cluster.NoNodeRole, 1, "2", "8Gi")
+	})
+
+	t.Run("CRDs", func(t *testing.T) {
+		// given
+		target, reset := newProbeTarget(t, "kubernetes", "https://kubernetes.com")
+		defer reset()
+		inventory := &cluster.Inventory{}
+
+		// when
+		err := CRDsCollector("virtualmachines.kubevirt.io", "pipelines.tekton.dev").Collect(context.TODO(), target, inventory)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, map[string]bool{"virtualmachines.kubevirt.io": true, "pipelines.tekton.dev": false}, inventory.CRDs)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		// given
+		target, reset := newProbeTarget(t, "broken", "https://broken.com")
+		defer reset()
+		controller := Reconciler{InventoryCollectors: DefaultInventoryCollectors("virtualmachines.kubevirt.io")}
+
+		// when
+		inventory := controller.collectInventory(context.TODO(), target)
+
+		// then
+		assert.Empty(t, inventory.KubernetesVersion)
+		assert.Zero(t, inventory.NodeCount)
+		assert.Nil(t, inventory.CRDs)
+		assert.Len(t, inventory.Errors, 3)
+		assert.Contains(t, inventory.Errors["crds"], "unable to check the CustomResourceDefinition virtualmachines.kubevirt.io")
+	})
+}
+
+func TestReconcileWithInventory(t *testing.T) {
+	// given
+	defer gock.Off()
+	mockInventory("https://openshift.com", true)
+	gock.New("https://openshift.com").
+		Get("healthz").
+		Persist().
+		Reply(200).
+		BodyString("ok")
+	tc, sec := newToolchainCluster(t, "openshift", "test-namespace", "https://openshift.com")
+	cl := test.NewFakeClient(t, tc, sec)
+	reset := setupCachedClusters(t, cl, tc)
+	defer reset()
+	controller, req := prepareReconcile(tc, cl, requeAfter)
+	controller.InventoryCollectors = DefaultInventoryCollectors("virtualmachines.kubevirt.io")
+	// the inventory is published by a patch
+	cl.MockUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+		return errors.New("unexpected update")
+	}
+	clock := newTestClock()
+	controller.now = clock.now
+	getInventory := func(t *testing.T) *cluster.Inventory {
+		t.Helper()
+		tc := &toolchainv1alpha1.ToolchainCluster{}
+		require.NoError(t, cl.Get(context.TODO(), req.NamespacedName, tc))
+		inventory, err := cluster.GetInventory(tc)
+		require.NoError(t, err)
+		return inventory
+	}
+
+	t.Run("inventory is published", func(t *testing.T) {
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assertClusterStatus(t, cl, "openshift", clusterReadyCondition())
+		inventory := getInventory(t)
+		require.NotNil(t, inventory)
+		assert.Equal(t, "v1.31.4", inventory.KubernetesVersion)
+		assert.Equal(t, "4.18.3", inventory.OpenShiftVersion)
+		assert.Equal(t, 4, inventory.NodeCount)
+		assert.Equal(t, map[string]bool{"virtualmachines.kubevirt.io": true}, inventory.CRDs)
+		assert.Empty(t, inventory.Errors)
+	})
+
+	t.Run("unchanged inventory doesn't update the ToolchainCluster", func(t *testing.T) {
+		// given
+		cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+			return errors.New("unexpected patch")
+		}
+		defer func() {
+			cl.MockPatch = nil
+		}()
+		clock.advance(requeAfter)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+	})
+
+	t.Run("last inventory is kept while the cluster is not reachable", func(t *testing.T) {
+		// given
+		published := getInventory(t)
+		controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (bool, error) {
+			return false, errors.New("connection refused")
+		}
+		controller.InventoryCollectors = []InventoryCollector{NodesCollector()}
+		clock.advance(requeAfter)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assertClusterStatus(t, cl, "openshift", clusterOfflineCondition("connection refused"))
+		inventory := getInventory(t)
+		assert.Equal(t, published, inventory)
+	})
+}
+
+func assertNodeRole(t *testing.T, inventory *cluster.Inventory, role string, nodes int, cpu, memory string) {
+	t.Helper()
+	capacity, found := inventory.NodeRoles[role]
+	require.True(t, found, "role %s not found", role)
+	assert.Equal(t, nodes, capacity.Nodes)
+	assert.True(t, resource.MustParse(cpu).Equal(capacity.AllocatableCPU), "expected %s CPU, got %s", cpu, capacity.AllocatableCPU.String())
+	assert.True(t, resource.MustParse(memory).Equal(capacity.AllocatableMemory), "expected %s memory, got %s", memory, capacity.AllocatableMemory.String())
+}
@@ -0,0 +1,242 @@
+package toolchaincluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	kubeclientset "k8s.io/client-go/kubernetes"
+)
+
+// the reasons of the failed probes
+const (
+	ReadyzCheckFailedReason              = "ReadyzCheckFailed"
+	LivezCheckFailedReason               = "LivezCheckFailed"
+	APILatencyExceededReason             = "APILatencyExceeded"
+	UnsupportedServerVersionReason       = "UnsupportedServerVersion"
+	OperatorNamespaceNotFoundReason      = "OperatorNamespaceNotFound"
+	OperatorNamespaceNotAccessibleReason = "OperatorNamespaceNotAccessible"
+)
+
+// ProbeTarget is the remote cluster checked by the health probes
+type ProbeTarget struct {
+	Cluster   *cluster.CachedToolchainCluster
+	Clientset *kubeclientset.Clientset
+}
+
+// ProbeResult is the result of a single health probe
+type ProbeResult struct {
+	// Healthy is true if the probe passed
+	Healthy bool
+	// Reason is the reason of the failure in the CamelCase format (used as the reason of the Ready condition)
+	Reason string
+	// Message describes the result of the probe
+	Message string
+	// Err is set when the probe couldn't be executed, eg. because the cluster is not reachable
+	Err error
+}
+
+// HealthProbe checks one aspect of the health of a remote cluster. The results of all the probes configured in the Reconciler
+// are aggregated into the Ready condition of the ToolchainCluster.
+type HealthProbe interface {
+	// Name identifies the probe (eg. in the logs)
+	Name() string
+	// Probe checks the remote cluster
+	Probe(ctx context.Context, target ProbeTarget) ProbeResult
+}
+
+type healthzProbe struct {
+	checkHealth func(context.Context, *kubeclientset.Clientset) (bool, error)
+}
+
+// HealthzProbe returns the probe requesting the `/healthz` endpoint. It's the only probe used when no probe is configured in the Reconciler.
+func HealthzProbe() HealthProbe {
+	return &healthzProbe{checkHealth: getClusterHealthStatus}
+}
+
+func (p *healthzProbe) Name() string {
+	return "healthz"
+}
+
+func (p *healthzProbe) Probe(ctx context.Context, target ProbeTarget) ProbeResult {
+	isHealthy, err := p.checkHealth(ctx, target.Clientset)
+	if err != nil {
+		return ProbeResult{Err: err}
+	}
+	if !isHealthy {
+		return ProbeResult{Reason: toolchainv1alpha1.ToolchainClusterClusterNotReadyReason, Message: healthzNotOk}
+	}
+	return ProbeResult{Healthy: true, Message: healthzOk}
+}
+
+type verboseHealthProbe struct {
+	path         string
+	failedReason string
+	exclude      []string
+}
+
+// ReadyzProbe returns the probe requesting the `/readyz?verbose` endpoint. The names of the failed checks (eg. `etcd`) are part of the result.
+// The given checks are excluded from the readiness check.
+func ReadyzProbe(exclude ...string) HealthProbe {
+	return &verboseHealthProbe{path: "/readyz", failedReason: ReadyzCheckFailedReason, exclude: exclude}
+}
+
+// LivezProbe returns the probe requesting the `/livez?verbose` endpoint. The names of the failed checks are part of the result.
+// The given checks are excluded from the liveness check.
+func LivezProbe(exclude ...string) HealthProbe {
+	return &verboseHealthProbe{path: "/livez", failedReason: LivezCheckFailedReason, exclude: exclude}
+}
+
+func (p *verboseHealthProbe) Name() string {
+	return strings.TrimPrefix(p.path, "/")
+}
+
+func (p *verboseHealthProbe) Probe(ctx context.Context, target ProbeTarget) ProbeResult {
+	request := target.Clientset.DiscoveryClient.RESTClient().Get().AbsPath(p.path).Param("verbose", "true")
+	for _, check := range p.exclude {
+		request = request.Param("exclude", check)
+	}
+	var statusCode int
+	body, err := request.Do(ctx).StatusCode(&statusCode).Raw()
+	if statusCode == http.StatusOK {
+		return ProbeResult{Healthy: true, Message: fmt.Sprintf("%s responded with ok", p.path)}
+	}
+	// the endpoint responds with 500 and the list of the checks when some of them failed
+	if failed := parseFailedChecks(body); statusCode == http.StatusInternalServerError && len(failed) > 0 {
+		return ProbeResult{
+			Reason:  p.failedReason,
+			Message: fmt.Sprintf("%s checks failed: %s", p.path, strings.Join(failed, ", ")),
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("%s responded with the status code %d", p.path, statusCode)
+	}
+	return ProbeResult{Err: err}
+}
+
+// parseFailedChecks returns the names of the failed checks from the verbose output of the health endpoints, eg. from the line
+// `[-]etcd failed: reason withheld` it returns `etcd`
+func parseFailedChecks(body []byte) []string {
+	var failed []string
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "[-]") {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimPrefix(line, "[-]"), " ")
+		failed = append(failed, name)
+	}
+	return failed
+}
+
+type latencyProbe struct {
+	threshold time.Duration
+	now       func() time.Time
+}
+
+// LatencyProbe returns the probe measuring the duration of a request to the `/version` endpoint. The probe fails if the duration
+// exceeds the given threshold.
+func LatencyProbe(threshold time.Duration) HealthProbe {
+	return &latencyProbe{threshold: threshold, now: time.Now}
+}
+
+func (p *latencyProbe) Name() string {
+	return "latency"
+}
+
+func (p *latencyProbe) Probe(ctx context.Context, target ProbeTarget) ProbeResult {
+	start := p.now()
+	if _, err := target.Clientset.DiscoveryClient.RESTClient().Get().AbsPath("/version").Do(ctx).Raw(); err != nil {
+		return ProbeResult{Err: err}
+	}
+	latency := p.now().Sub(start)
+	if latency > p.threshold {
+		return ProbeResult{
+			Reason:  APILatencyExceededReason,
+			Message: fmt.Sprintf("the API latency %s exceeded the threshold %s", latency, p.threshold),
+		}
+	}
+	return ProbeResult{Healthy: true, Message: fmt.Sprintf("the API latency %s is within the threshold %s", latency, p.threshold)}
+}
+
+type versionProbe struct {
+	minVersion *version.Version
+}
+
+// VersionProbe returns the probe checking the version of the remote API server. The probe fails if the version is lower
+// than the given minimal version (eg. `v1.30.0`). If the minimal version is empty, then the version is only reported.
+func VersionProbe(minVersion string) (HealthProbe, error) {
+	probe := &versionProbe{}
+	if minVersion != "" {
+		v, err := version.ParseGeneric(minVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid minimal version '%s': %w", minVersion, err)
+		}
+		probe.minVersion = v
+	}
+	return probe, nil
+}
+
+func (p *versionProbe) Name() string {
+	return "version"
+}
+
+func (p *versionProbe) Probe(_ context.Context, target ProbeTarget) ProbeResult {
+	info, err := target.Clientset.DiscoveryClient.ServerVersion()
+	if err != nil {
+		return ProbeResult{Err: err}
+	}
+	if p.minVersion != nil {
+		serverVersion, err := version.ParseGeneric(info.GitVersion)
+		if err != nil {
+			return ProbeResult{Err: fmt.Errorf("unable to parse the server version '%s': %w", info.GitVersion, err)}
+		}
+		if serverVersion.LessThan(p.minVersion) {
+			return ProbeResult{
+				Reason:  UnsupportedServerVersionReason,
+				Message: fmt.Sprintf("the server version %s is lower than the minimal supported version %s", info.GitVersion, p.minVersion),
+			}
+		}
+	}
+	return ProbeResult{Healthy: true, Message: fmt.Sprintf("the server version is %s", info.GitVersion)}
+}
+
+type operatorNamespaceProbe struct{}
+
+// OperatorNamespaceProbe returns the probe checking that the operator namespace of the ToolchainCluster exists
+// and that it is accessible with the configured token.
+func OperatorNamespaceProbe() HealthProbe {
+	return &operatorNamespaceProbe{}
+}
+
+func (p *operatorNamespaceProbe) Name() string {
+	return "operator-namespace"
+}
+
+func (p *operatorNamespaceProbe) Probe(ctx context.Context, target ProbeTarget) ProbeResult {
+	namespace := target.Cluster.OperatorNamespace
+	_, err := target.Clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		return ProbeResult{Healthy: true, Message: fmt.Sprintf("the operator namespace '%s' is accessible", namespace)}
+	case kerrors.IsNotFound(err):
+		return ProbeResult{
+			Reason:  OperatorNamespaceNotFoundReason,
+			Message: fmt.Sprintf("the operator namespace '%s' doesn't exist", namespace),
+		}
+	case kerrors.IsForbidden(err) || kerrors.IsUnauthorized(err):
+		return ProbeResult{
+			Reason:  OperatorNamespaceNotAccessibleReason,
+			Message: fmt.Sprintf("the operator namespace '%s' is not accessible: %s", namespace, err.Error()),
+		}
+	default:
+		return ProbeResult{Err: err}
+	}
+}
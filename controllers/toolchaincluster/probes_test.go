@@ -0,0 +1,384 @@
+package toolchaincluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+	corev1 "k8s.io/api/core/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const failingReadyz = `[+]ping ok
+[+]log ok
+[-]etcd failed: reason withheld
+[+]poststarthook/start-apiserver-admission-initializer ok
+[-]etcd-readiness failed: reason withheld
+readyz check failed`
+
+func newProbeTarget(t *testing.T, name, apiEndpoint string) (ProbeTarget, func()) {
+	tc, sec := newToolchainCluster(t, name, "test-namespace", apiEndpoint)
+	cl := test.NewFakeClient(t, tc, sec)
+	reset := setupCachedClusters(t, cl, tc)
+	cachedTC, found := cluster.GetCachedToolchainCluster(tc.Name)
+	require.True(t, found)
+	clientSet, err := kubeclientset.NewForConfig(cachedTC.RestConfig)
+	require.NoError(t, err)
+	return ProbeTarget{Cluster: cachedTC, Clientset: clientSet}, reset
+}
+
+func TestVerboseHealthProbes(t *testing.T) {
+	// given
+	defer gock.Off()
+	gock.New("https://healthy.com").
+		Get("readyz").
+		MatchParam("verbose", "true").
+		Persist().
+		Reply(200).
+		BodyString("[+]ping ok\nreadyz check passed")
+	gock.New("https://healthy.com").
+		Get("livez").
+		MatchParam("verbose", "true").
+		Persist().
+		Reply(200).
+		BodyString("[+]ping ok\nlivez check passed")
+	gock.New("https://degraded.com").
+		Get("readyz").
+		MatchParam("verbose", "true").
+		MatchParam("exclude", "etcd-readiness").
+		Persist().
+		Reply(500).
+		BodyString("[+]ping ok\n[-]etcd failed: reason withheld\nreadyz check failed")
+	gock.New("https://degraded.com").
+		Get("readyz").
+		MatchParam("verbose", "true").
+		Persist().
+		Reply(500).
+		BodyString(failingReadyz)
+	gock.New("https://degraded.com").
+		Get("livez").
+		MatchParam("verbose", "true").
+		Persist().
+		Reply(500).
+		BodyString("[+]ping ok\n[-]etcd failed: reason withheld\nlivez check failed")
+	gock.New("https://not-found.com").
+		Get("readyz").
+		Persist().
+		Reply(404)
+
+	t.Run("readyz", func(t *testing.T) {
+		t.Run("healthy", func(t *testing.T) {
+			// given
+			target, reset := newProbeTarget(t, "healthy", "https://healthy.com")
+			defer reset()
+
+			// when
+			result := ReadyzProbe().Probe(context.TODO(), target)
+
+			// then
+			assert.Equal(t, ProbeResult{Healthy: true, Message: "/readyz responded with ok"}, result)
+		})
+
+		t.Run("failed checks", func(t *testing.T) {
+			// given
+			target, reset := newProbeTarget(t, "degraded", "https://degraded.com")
+			defer reset()
+
+			// when
+			result := ReadyzProbe().Probe(context.TODO(), target)
+
+			// then
+			assert.Equal(t, ProbeResult{Reason: "ReadyzCheckFailed", Message: "/readyz checks failed: etcd, etcd-readiness"}, result)
+		})
+
+		t.Run("excluded check", func(t *testing.T) {
+			// given
+			target, reset := newProbeTarget(t, "degraded", "https://degraded.com")
+			defer reset()
+
+			// when
+			result := ReadyzProbe("etcd-readiness").Probe(context.TODO(), target)
+
+			// then
+			assert.Equal(t, ProbeResult{Reason: "ReadyzCheckFailed", Message: "/readyz checks failed: etcd"}, result)
+		})
+
+		t.Run("error", func(t *testing.T) {
+			// given
+			target, reset := newProbeTarget(t, "not-found", "https://not-found.com")
+			defer reset()
+
+			// when
+			result := ReadyzProbe().Probe(context.TODO(), target)
+
+			// then
+			assert.False(t, result.Healthy)
+			require.EqualError(t, result.Err, "the server could not find the requested resource")
+		})
+	})
+
+	t.Run("livez", func(t *testing.T) {
+		t.Run("healthy", func(t *testing.T) {
+			// given
+			target, reset := newProbeTarget(t, "healthy", "https://healthy.com")
+			defer reset()
+
+			// when
+			result := LivezProbe().Probe(context.TODO(), target)
+
+			// then
+			assert.Equal(t, ProbeResult{Healthy: true, Message: "/livez responded with ok"}, result)
+		})
+
+		t.Run("failed checks", func(t *testing.T) {
+			// given
+			target, reset := newProbeTarget(t, "degraded", "https://degraded.com")
+			defer reset()
+
+			// when
+			result := LivezProbe().Probe(context.TODO(), target)
+
+			// then
+			assert.Equal(t, ProbeResult{Reason: "LivezCheckFailed", Message: "/livez checks failed: etcd"}, result)
+		})
+	})
+}
+
+func TestParseFailedChecks(t *testing.T) {
+	assert.Equal(t, []string{"etcd", "etcd-readiness"}, parseFailedChecks([]byte(failingReadyz)))
+	assert.Empty(t, parseFailedChecks([]byte("[+]ping ok\nreadyz check passed")))
+	assert.Empty(t, parseFailedChecks(nil))
+}
+
+func TestLatencyProbe(t *testing.T) {
+	// given
+	defer gock.Off()
+	gock.New("https://cluster.com").
+		Get("version").
+		Persist().
+		Reply(200).
+		BodyString(`{"gitVersion": "v1.30.2"}`)
+	target, reset := newProbeTarget(t, "stable", "https://cluster.com")
+	defer reset()
+	newProbe := func(latency time.Duration) HealthProbe {
+		probe := LatencyProbe(time.Second).(*latencyProbe)
+		start := time.Now()
+		calls := 0
+		probe.now = func() time.Time {
+			calls++
+			if calls%2 == 0 {
+				return start.Add(latency)
+			}
+			return start
+		}
+		return probe
+	}
+
+	t.Run("within the threshold", func(t *testing.T) {
+		// when
+		result := newProbe(500*time.Millisecond).Probe(context.TODO(), target)
+
+		// then
+		assert.Equal(t, ProbeResult{Healthy: true, Message: "the API latency 500ms is within the threshold 1s"}, result)
+	})
+
+	t.Run("exceeded the threshold", func(t *testing.T) {
+		// when
+		result := newProbe(1500*time.Millisecond).Probe(context.TODO(), target)
+
+		// then
+		assert.Equal(t, ProbeResult{Reason: "APILatencyExceeded", Message: "the API latency 1.5s exceeded the threshold 1s"}, result)
+	})
+}
+
+func TestVersionProbe(t *testing.T) {
+	// given
+	defer gock.Off()
+	gock.New("https://cluster.com").
+		Get("version").
+		Persist().
+		Reply(200).
+		BodyString(`{"major": "1", "minor": "30", "gitVersion": "v1.30.2"}`)
+	target, reset := newProbeTarget(t, "stable", "https://cluster.com")
+	defer reset()
+
+	t.Run("supported version", func(t *testing.T) {
+		// given
+		probe, err := VersionProbe("v1.29.0")
+		require.NoError(t, err)
+
+		// when
+		result := probe.Probe(context.TODO(), target)
+
+		// then
+		assert.Equal(t, ProbeResult{Healthy: true, Message: "the server version is v1.30.2"}, result)
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		// given
+		probe, err := VersionProbe("1.31")
+		require.NoError(t, err)
+
+		// when
+		result := probe.Probe(context.TODO(), target)
+
+		// then
+		assert.Equal(t, ProbeResult{Reason: "UnsupportedServerVersion", Message: "the server version v1.30.2 is lower than the minimal supported version 1.31"}, result)
+	})
+
+	t.Run("no minimal version", func(t *testing.T) {
+		// given
+		probe, err := VersionProbe("")
+		require.NoError(t, err)
+
+		// when
+		result := probe.Probe(context.TODO(), target)
+
+		// then
+		assert.True(t, result.Healthy)
+	})
+
+	t.Run("invalid minimal version", func(t *testing.T) {
+		// when
+		_, err := VersionProbe("latest")
+
+		// then
+		require.ErrorContains(t, err, "invalid minimal version 'latest'")
+	})
+}
+
+func TestOperatorNamespaceProbe(t *testing.T) {
+	// given
+	defer gock.Off()
+	gock.New("https://accessible.com").
+		Get("api/v1/namespaces/test-namespace").
+		Persist().
+		Reply(200).
+		SetHeader("Content-Type", "application/json").
+		BodyString(`{"kind": "Namespace", "apiVersion": "v1", "metadata": {"name": "test-namespace"}}`)
+	gock.New("https://missing.com").
+		Get("api/v1/namespaces/test-namespace").
+		Persist().
+		Reply(404).
+		SetHeader("Content-Type", "application/json").
+		BodyString(`{"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "NotFound", "code": 404, "message": "namespaces \"test-namespace\" not found"}`)
+	gock.New("https://forbidden.com").
+		Get("api/v1/namespaces/test-namespace").
+		Persist().
+		Reply(403).
+		SetHeader("Content-Type", "application/json").
+		BodyString(`{"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "Forbidden", "code": 403, "message": "forbidden"}`)
+
+	for name, tc := range map[string]struct {
+		apiEndpoint string
+		expected    ProbeResult
+	}{
+		"accessible": {
+			apiEndpoint: "https://accessible.com",
+			expected:    ProbeResult{Healthy: true, Message: "the operator namespace 'test-namespace' is accessible"},
+		},
+		"missing": {
+			apiEndpoint: "https://missing.com",
+			expected:    ProbeResult{Reason: "OperatorNamespaceNotFound", Message: "the operator namespace 'test-namespace' doesn't exist"},
+		},
+		"forbidden": {
+			apiEndpoint: "https://forbidden.com",
+			expected:    ProbeResult{Reason: "OperatorNamespaceNotAccessible", Message: "the operator namespace 'test-namespace' is not accessible: forbidden"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// given
+			target, reset := newProbeTarget(t, name, tc.apiEndpoint)
+			defer reset()
+
+			// when
+			result := OperatorNamespaceProbe().Probe(context.TODO(), target)
+
+			// then
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+type fakeProbe struct {
+	result ProbeResult
+}
+
+func (p *fakeProbe) Name() string {
+	return "fake"
+}
+
+func (p *fakeProbe) Probe(context.Context, ProbeTarget) ProbeResult {
+	return p.result
+}
+
+func TestReconcileWithProbes(t *testing.T) {
+	// given
+	defer gock.Off()
+	healthy := &fakeProbe{result: ProbeResult{Healthy: true, Message: "/healthz responded with ok"}}
+	readyzFailed := &fakeProbe{result: ProbeResult{Reason: "ReadyzCheckFailed", Message: "/readyz checks failed: etcd"}}
+	versionFailed := &fakeProbe{result: ProbeResult{Reason: "UnsupportedServerVersion", Message: "the server version v1.20.0 is lower than the minimal supported version 1.29"}}
+	unreachable := &fakeProbe{result: ProbeResult{Err: assert.AnError}}
+
+	for name, tc := range map[string]struct {
+		probes   []HealthProbe
+		expected toolchainv1alpha1.Condition
+	}{
+		"all probes healthy": {
+			probes: []HealthProbe{healthy, &fakeProbe{result: ProbeResult{Healthy: true, Message: "the server version is v1.30.2"}}},
+			expected: toolchainv1alpha1.Condition{
+				Type:    toolchainv1alpha1.ConditionReady,
+				Status:  corev1.ConditionTrue,
+				Reason:  "ClusterReady",
+				Message: "/healthz responded with ok; the server version is v1.30.2",
+			},
+		},
+		"degraded cluster is not ready": {
+			probes: []HealthProbe{healthy, readyzFailed, versionFailed},
+			expected: toolchainv1alpha1.Condition{
+				Type:    toolchainv1alpha1.ConditionReady,
+				Status:  corev1.ConditionFalse,
+				Reason:  "ReadyzCheckFailed",
+				Message: "/readyz checks failed: etcd; the server version v1.20.0 is lower than the minimal supported version 1.29",
+			},
+		},
+		"failure without reason": {
+			probes: []HealthProbe{&fakeProbe{result: ProbeResult{Message: "something is wrong"}}},
+			expected: toolchainv1alpha1.Condition{
+				Type:    toolchainv1alpha1.ConditionReady,
+				Status:  corev1.ConditionFalse,
+				Reason:  "ClusterNotReady",
+				Message: "something is wrong",
+			},
+		},
+		"unreachable cluster": {
+			probes:   []HealthProbe{healthy, readyzFailed, unreachable},
+			expected: clusterOfflineCondition(assert.AnError.Error()),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// given
+			stable, sec := newToolchainCluster(t, "stable", "test-namespace", "https://cluster.com")
+			cl := test.NewFakeClient(t, stable, sec)
+			reset := setupCachedClusters(t, cl, stable)
+			defer reset()
+			controller, req := prepareReconcile(stable, cl, requeAfter)
+			controller.Probes = tc.probes
+
+			// when
+			recResult, err := controller.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			require.Equal(t, reconcile.Result{RequeueAfter: requeAfter}, recResult)
+			assertClusterStatus(t, cl, "stable", tc.expected)
+		})
+	}
+}
@@ -2,17 +2,22 @@ package toolchaincluster
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	commonpredicates "github.com/codeready-toolchain/toolchain-common/pkg/predicate"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	kubeclientset "k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -20,16 +25,45 @@ import (
 
 // Reconciler reconciles a ToolchainCluster object
 type Reconciler struct {
-	Client      client.Client
-	Scheme      *runtime.Scheme
-	RequeAfter  time.Duration
-	checkHealth func(context.Context, *kubeclientset.Clientset) (bool, error)
+	Client     client.Client
+	Scheme     *runtime.Scheme
+	RequeAfter time.Duration
+	// Probes check the health of the remote clusters. Only the `/healthz` endpoint is checked if no probe is configured.
+	Probes []HealthProbe
+	// FailureThreshold is the number of consecutive failed health checks before a ready cluster is reported as not ready.
+	// It prevents brief network blips from flipping the Ready condition. Defaults to 1.
+	// The health check is done (and counted towards the thresholds) only if the RequeAfter interval has elapsed since the previous one,
+	// the reconciles triggered by the updates of the ToolchainCluster in between keep the current conditions.
+	FailureThreshold int
+	// SuccessThreshold is the number of consecutive passed health checks before a not ready cluster is reported as ready again.
+	// Defaults to 1.
+	SuccessThreshold int
+	// CredentialsExpiryWarning is the period before the expiration of the credentials in the kubeconfig when the expiration
+	// is reported in the CredentialsValid condition. Defaults to DefaultCredentialsExpiryWarning.
+	CredentialsExpiryWarning time.Duration
+	// ClusterCache is the cache the remote clusters are taken from. The default cluster cache is used if not set.
+	ClusterCache *cluster.ClusterCache
+	// TokenRotator rotates the tokens in the secrets of the ToolchainClusters before they expire. The tokens are not rotated if not set.
+	TokenRotator *cluster.TokenRotator
+	// ProbeHistory keeps the latest health checks and their latencies. A history with the default size is created on the first reconcile if not set.
+	ProbeHistory *ProbeHistory
+	// ConnectivityDiagnostics diagnose the connection to the clusters that are not reachable - the failed stage is reported
+	// in the Ready and Connectivity conditions, the whole report is published in the connectivity annotation of the ToolchainCluster
+	// and kept in the ProbeHistory. The connectivity is not diagnosed if not set.
+	ConnectivityDiagnostics *ConnectivityDiagnostics
+	// InventoryCollectors collect the facts about the reachable clusters on every health check, see DefaultInventoryCollectors.
+	// The facts are published in the inventory annotation of the ToolchainCluster. No inventory is collected if not set.
+	InventoryCollectors []InventoryCollector
+	checkHealth         func(context.Context, *kubeclientset.Clientset) (bool, error)
+	now                 func() time.Time
+	probeHistoryInit    sync.Once
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// the controller's own updates of the status and of the annotations don't trigger any reconcile
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&toolchainv1alpha1.ToolchainCluster{}).
+		For(&toolchainv1alpha1.ToolchainCluster{}, builder.WithPredicates(commonpredicates.LabelsAndGenerationPredicate{})).
 		Complete(r)
 }
 
@@ -41,6 +75,7 @@ func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
 func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
 	reqLogger := log.FromContext(ctx)
 	reqLogger.Info("Reconciling ToolchainCluster")
+	probeHistory := r.probeHistory()
 
 	// Fetch the ToolchainCluster instance
 	toolchainCluster := &toolchainv1alpha1.ToolchainCluster{}
@@ -48,14 +83,33 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 	if err != nil {
 		if kerrors.IsNotFound(err) {
 			// Stop monitoring the toolchain cluster as it is deleted
+			probeHistory.forget(request.Name)
+			deleteCredentialsMetrics(request.Name)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
 
-	cachedCluster, ok := cluster.GetCachedToolchainCluster(toolchainCluster.Name)
+	if r.TokenRotator != nil {
+		// a failed rotation is not fatal - the token can still be valid and its expiration is reported in the CredentialsValid condition
+		if rotated, err := r.TokenRotator.RotateIfNeeded(ctx, toolchainCluster); err != nil {
+			reqLogger.Error(err, "unable to rotate the token of the ToolchainCluster")
+		} else if rotated {
+			reqLogger.Info("the token of the ToolchainCluster was rotated")
+		}
+	}
+
+	cachedCluster, ok := r.clusterCache().GetCachedToolchainCluster(toolchainCluster.Name)
 	if !ok {
+		// the credentials that can never work are reported with a specific reason, there is no point in retrying before they are fixed
+		if _, err := cluster.NewClusterConfig(r.Client, toolchainCluster, 0); errors.Is(err, cluster.ErrUnsupportedAuthentication) {
+			if err := r.updateStatus(ctx, toolchainCluster, nil, unsupportedAuthenticationCondition(err.Error())); err != nil {
+				reqLogger.Error(err, "unable to update cluster status of ToolchainCluster")
+				return reconcile.Result{}, err
+			}
+			return reconcile.Result{RequeueAfter: r.RequeAfter}, nil
+		}
 		err := fmt.Errorf("cluster %s not found in cache", toolchainCluster.Name)
 		if err := r.updateStatus(ctx, toolchainCluster, nil, clusterOfflineCondition(err.Error())); err != nil {
 			reqLogger.Error(err, "unable to update cluster status of ToolchainCluster")
@@ -63,7 +117,7 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 		return reconcile.Result{}, err
 	}
 
-	clientSet, err := kubeclientset.NewForConfig(cachedCluster.RestConfig)
+	clientSet, err := cachedCluster.Clientset()
 	if err != nil {
 		reqLogger.Error(err, "cannot create ClientSet for the ToolchainCluster")
 		if err := r.updateStatus(ctx, toolchainCluster, cachedCluster, clusterOfflineCondition(err.Error())); err != nil {
@@ -72,14 +126,56 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 		return reconcile.Result{}, err
 	}
 
-	// execute healthcheck
-	healthCheckResult := r.getClusterHealthCondition(ctx, clientSet)
+	// execute healthcheck, unless the previous one was done less than the interval ago (eg. when reconciling after an update of the ToolchainCluster)
+	start := r.currentTime()
+	if remaining, due := r.probeDue(toolchainCluster.Name, start); !due {
+		reqLogger.Info("skipping the health check, the previous one was done less than the interval ago", "interval", r.RequeAfter)
+		return reconcile.Result{RequeueAfter: remaining}, nil
+	}
+	observed := r.getClusterHealthCondition(ctx, ProbeTarget{Cluster: cachedCluster, Clientset: clientSet})
+	latency := r.currentTime().Sub(start)
+	connectivity := r.diagnoseConnectivity(ctx, toolchainCluster.Name, cachedCluster, &observed, start)
+	history := probeHistory.record(toolchainCluster.Name, ProbeRecord{
+		Time:         start,
+		Healthy:      observed.Status == corev1.ConditionTrue,
+		Reason:       observed.Reason,
+		Message:      observed.Message,
+		Latency:      latency,
+		Connectivity: connectivity,
+	})
+	conditions := []toolchainv1alpha1.Condition{r.dampHealthCondition(ctx, toolchainCluster.Status.Conditions, observed, history)}
+	if connectivity != nil {
+		conditions = append(conditions, connectivityCondition(*connectivity))
+	} else {
+		toolchainCluster.Status.Conditions = removeCondition(toolchainCluster.Status.Conditions, ConnectivityConditionType)
+	}
+
+	// check the expiration of the credentials
+	if credentialsCondition := r.getCredentialsCondition(ctx, toolchainCluster.Name, cachedCluster.RestConfig); credentialsCondition != nil {
+		conditions = append(conditions, *credentialsCondition)
+	} else {
+		toolchainCluster.Status.Conditions = removeCondition(toolchainCluster.Status.Conditions, CredentialsValidConditionType)
+	}
 
 	// update the status of the individual cluster.
-	if err := r.updateStatus(ctx, toolchainCluster, cachedCluster, healthCheckResult); err != nil {
+	if err := r.updateStatus(ctx, toolchainCluster, cachedCluster, conditions...); err != nil {
 		reqLogger.Error(err, "unable to update cluster status of ToolchainCluster")
 		return reconcile.Result{}, err
 	}
+
+	// publish the report of the connectivity diagnostics, it's removed as soon as the cluster is reachable again
+	if err := r.publishConnectivity(ctx, toolchainCluster, connectivity); err != nil {
+		reqLogger.Error(err, "unable to publish the connectivity report of ToolchainCluster")
+		return reconcile.Result{}, err
+	}
+
+	// publish the facts about the cluster, the last known ones are kept while the cluster is not reachable
+	if len(r.InventoryCollectors) > 0 && observed.Reason != toolchainv1alpha1.ToolchainClusterClusterNotReachableReason {
+		if err := r.publishInventory(ctx, toolchainCluster, ProbeTarget{Cluster: cachedCluster, Clientset: clientSet}); err != nil {
+			reqLogger.Error(err, "unable to publish the inventory of ToolchainCluster")
+			return reconcile.Result{}, err
+		}
+	}
 	return reconcile.Result{RequeueAfter: r.RequeAfter}, nil
 }
 
@@ -100,47 +196,180 @@ func (r *Reconciler) updateStatus(ctx context.Context, toolchainCluster *toolcha
 	return nil
 }
 
-func (r *Reconciler) getClusterHealthCondition(ctx context.Context, remoteClusterClientset *kubeclientset.Clientset) toolchainv1alpha1.Condition {
-	isHealthy, err := r.getClusterHealth(ctx, remoteClusterClientset)
-	if err != nil {
-		return clusterOfflineCondition(err.Error())
+// getClusterHealthCondition runs all the probes and aggregates their results into the Ready condition. The cluster is not reachable
+// if any of the probes couldn't be executed and it's not ready if any of the probes failed. The reason of the condition is the reason
+// of the first failed probe and the message contains the messages of all the failed probes.
+func (r *Reconciler) getClusterHealthCondition(ctx context.Context, target ProbeTarget) toolchainv1alpha1.Condition {
+	lgr := log.FromContext(ctx)
+	var errMsgs, failedMsgs, okMsgs []string
+	failedReason := ""
+	for _, probe := range r.probes() {
+		result := probe.Probe(ctx, target)
+		switch {
+		case result.Err != nil:
+			lgr.Error(result.Err, "health probe could not be executed", "probe", probe.Name())
+			errMsgs = append(errMsgs, result.Err.Error())
+		case !result.Healthy:
+			lgr.Info("health probe failed", "probe", probe.Name(), "reason", result.Reason, "message", result.Message)
+			if failedReason == "" {
+				failedReason = result.Reason
+			}
+			failedMsgs = append(failedMsgs, result.Message)
+		default:
+			okMsgs = append(okMsgs, result.Message)
+		}
 	}
-	if !isHealthy {
-		return clusterNotReadyCondition()
+	if len(errMsgs) > 0 {
+		return clusterOfflineCondition(strings.Join(errMsgs, "; "))
+	}
+	if len(failedMsgs) > 0 {
+		if failedReason == "" {
+			failedReason = toolchainv1alpha1.ToolchainClusterClusterNotReadyReason
+		}
+		return toolchainv1alpha1.Condition{
+			Type:    toolchainv1alpha1.ConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  failedReason,
+			Message: strings.Join(failedMsgs, "; "),
+		}
+	}
+	return toolchainv1alpha1.Condition{
+		Type:    toolchainv1alpha1.ConditionReady,
+		Status:  corev1.ConditionTrue,
+		Reason:  toolchainv1alpha1.ToolchainClusterClusterReadyReason,
+		Message: strings.Join(okMsgs, "; "),
 	}
-	return clusterReadyCondition()
 }
 
-func (r *Reconciler) getClusterHealth(ctx context.Context, remoteClusterClientset *kubeclientset.Clientset) (bool, error) {
-	if r.checkHealth != nil {
-		return r.checkHealth(ctx, remoteClusterClientset)
+// diagnoseConnectivity runs the connectivity diagnostics if the cluster is not reachable and appends the failed stage, if any,
+// to the message of the observed Ready condition. The diagnostics are run when the cluster becomes not reachable and then at most
+// once per the interval of the diagnostics - the last report is reused in between. It returns nil if the cluster is reachable
+// or the diagnostics are not enabled.
+func (r *Reconciler) diagnoseConnectivity(ctx context.Context, clusterName string, cachedCluster *cluster.CachedToolchainCluster, observed *toolchainv1alpha1.Condition, now time.Time) *cluster.ConnectivityReport {
+	if r.ConnectivityDiagnostics == nil || observed.Reason != toolchainv1alpha1.ToolchainClusterClusterNotReachableReason {
+		return nil
 	}
-	return getClusterHealthStatus(ctx, remoteClusterClientset)
+	report := r.lastConnectivityReport(clusterName)
+	if report == nil || now.Sub(report.Time) >= r.ConnectivityDiagnostics.interval() {
+		diagnosed := r.ConnectivityDiagnostics.diagnose(ctx, cachedCluster.RestConfig, now)
+		report = &diagnosed
+		if failed, found := report.Failed(); found {
+			log.FromContext(ctx).Info("connectivity diagnostics failed", "stage", failed.Stage, "reason", failed.Reason, "details", failed.Details)
+		}
+	}
+	if failed, found := report.Failed(); found {
+		observed.Message = fmt.Sprintf("%s; %s", observed.Message, failed.Summary())
+	}
+	return report
 }
 
-func clusterOfflineCondition(errMsg string) toolchainv1alpha1.Condition {
-	return toolchainv1alpha1.Condition{
-		Type:    toolchainv1alpha1.ConditionReady,
-		Status:  corev1.ConditionFalse,
-		Reason:  toolchainv1alpha1.ToolchainClusterClusterNotReachableReason,
-		Message: errMsg,
+// probeDue returns true if the health check is due at the given time, ie. if the RequeAfter interval has elapsed since the previous
+// recorded health check (or there is none). Otherwise, it returns the remaining time until the health check is due.
+func (r *Reconciler) probeDue(clusterName string, now time.Time) (time.Duration, bool) {
+	history, found := r.probeHistory().Get(clusterName)
+	if !found || len(history.Records) == 0 {
+		return 0, true
 	}
+	remaining := r.RequeAfter - now.Sub(history.Records[len(history.Records)-1].Time)
+	return remaining, remaining <= 0
 }
 
-func clusterReadyCondition() toolchainv1alpha1.Condition {
-	return toolchainv1alpha1.Condition{
-		Type:    toolchainv1alpha1.ConditionReady,
-		Status:  corev1.ConditionTrue,
-		Reason:  toolchainv1alpha1.ToolchainClusterClusterReadyReason,
-		Message: healthzOk,
+// probeHistory returns the ProbeHistory, a history with the default size is created if not set
+func (r *Reconciler) probeHistory() *ProbeHistory {
+	r.probeHistoryInit.Do(func() {
+		if r.ProbeHistory == nil {
+			r.ProbeHistory = NewProbeHistory(DefaultProbeHistorySize)
+		}
+	})
+	return r.ProbeHistory
+}
+
+func (r *Reconciler) currentTime() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+// lastConnectivityReport returns the report of the previous health check if the cluster was not reachable already
+func (r *Reconciler) lastConnectivityReport(clusterName string) *cluster.ConnectivityReport {
+	history, found := r.probeHistory().Get(clusterName)
+	if !found || len(history.Records) == 0 {
+		return nil
+	}
+	last := history.Records[len(history.Records)-1]
+	if last.Reason != toolchainv1alpha1.ToolchainClusterClusterNotReachableReason {
+		return nil
 	}
+	return last.Connectivity
 }
 
-func clusterNotReadyCondition() toolchainv1alpha1.Condition {
+// publishConnectivity publishes the connectivity report in the annotation of the ToolchainCluster (or removes the annotation
+// if there is no report) and patches the ToolchainCluster if the annotation changed
+func (r *Reconciler) publishConnectivity(ctx context.Context, toolchainCluster *toolchainv1alpha1.ToolchainCluster, report *cluster.ConnectivityReport) error {
+	patch := client.MergeFrom(toolchainCluster.DeepCopy())
+	changed, err := cluster.SetConnectivityReport(toolchainCluster, report)
+	if err != nil || !changed {
+		return err
+	}
+	if err := r.Client.Patch(ctx, toolchainCluster, patch); err != nil {
+		return fmt.Errorf("failed to update the connectivity report of cluster - %s: %w", toolchainCluster.Name, err)
+	}
+	return nil
+}
+
+// dampHealthCondition returns the Ready condition to be set in the status. The Ready condition changes from true to false only
+// after the FailureThreshold consecutive failed health checks and from false to true only after the SuccessThreshold consecutive
+// passed health checks. Until then, the current condition is kept.
+func (r *Reconciler) dampHealthCondition(ctx context.Context, conditions []toolchainv1alpha1.Condition, observed toolchainv1alpha1.Condition, history ClusterProbeHistory) toolchainv1alpha1.Condition {
+	current, found := condition.FindConditionByType(conditions, toolchainv1alpha1.ConditionReady)
+	if !found {
+		return observed
+	}
+	wasReady := current.Status == corev1.ConditionTrue
+	isReady := observed.Status == corev1.ConditionTrue
+	switch {
+	case wasReady && !isReady && history.ConsecutiveFailures < threshold(r.FailureThreshold):
+		log.FromContext(ctx).Info("keeping the cluster ready until the failure threshold is reached",
+			"failures", history.ConsecutiveFailures, "threshold", r.FailureThreshold, "reason", observed.Reason)
+		return current
+	case !wasReady && isReady && history.ConsecutiveSuccesses < threshold(r.SuccessThreshold):
+		log.FromContext(ctx).Info("keeping the cluster not ready until the success threshold is reached",
+			"successes", history.ConsecutiveSuccesses, "threshold", r.SuccessThreshold)
+		return current
+	}
+	return observed
+}
+
+func threshold(value int) int {
+	if value < 1 {
+		return 1
+	}
+	return value
+}
+
+func (r *Reconciler) clusterCache() *cluster.ClusterCache {
+	if r.ClusterCache != nil {
+		return r.ClusterCache
+	}
+	return cluster.DefaultClusterCache()
+}
+
+func (r *Reconciler) probes() []HealthProbe {
+	if len(r.Probes) > 0 {
+		return r.Probes
+	}
+	if r.checkHealth != nil {
+		return []HealthProbe{&healthzProbe{checkHealth: r.checkHealth}}
+	}
+	return []HealthProbe{HealthzProbe()}
+}
+
+func clusterOfflineCondition(errMsg string) toolchainv1alpha1.Condition {
 	return toolchainv1alpha1.Condition{
 		Type:    toolchainv1alpha1.ConditionReady,
 		Status:  corev1.ConditionFalse,
-		Reason:  toolchainv1alpha1.ToolchainClusterClusterNotReadyReason,
-		Message: healthzNotOk,
+		Reason:  toolchainv1alpha1.ToolchainClusterClusterNotReachableReason,
+		Message: errMsg,
 	}
 }
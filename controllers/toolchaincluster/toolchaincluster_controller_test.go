@@ -9,12 +9,15 @@ import (
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/h2non/gock.v1"
 	corev1 "k8s.io/api/core/v1"
 	kubeclientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -114,6 +117,31 @@ func TestClusterControllerChecks(t *testing.T) {
 		assertClusterStatus(t, cl, "unstable", clusterOfflineCondition("cluster unstable not found in cache"))
 	})
 
+	t.Run("toolchain cluster with unsupported authentication", func(t *testing.T) {
+		// given
+		unsupported, sec := newToolchainCluster(t, "unsupported", tcNs, "https://cluster.com")
+		kubeConfig, err := clientcmd.Load(sec.Data["kubeconfig"])
+		require.NoError(t, err)
+		for _, authInfo := range kubeConfig.AuthInfos {
+			authInfo.Token = ""
+			authInfo.Exec = &clientcmdapi.ExecConfig{Command: "oc-login", APIVersion: "client.authentication.k8s.io/v1"}
+		}
+		sec.Data["kubeconfig"], err = clientcmd.Write(*kubeConfig)
+		require.NoError(t, err)
+
+		cl := test.NewFakeClient(t, unsupported, sec)
+		controller, req := prepareReconcile(unsupported, cl, requeAfter)
+
+		// when
+		recResult, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		require.Equal(t, reconcile.Result{RequeueAfter: requeAfter}, recResult)
+		assertClusterStatus(t, cl, "unsupported", unsupportedAuthenticationCondition(
+			"unsupported authentication: the user 'auth' uses the exec plugin 'oc-login', use a token or a client certificate instead"))
+	})
+
 	t.Run("error while updating a toolchain cluster status on cache not found", func(t *testing.T) {
 		// given
 		stable, _ := newToolchainCluster(t, "stable", tcNs, "https://cluster.com")
@@ -157,6 +185,27 @@ func TestClusterControllerChecks(t *testing.T) {
 		require.Equal(t, reconcile.Result{}, recResult)
 		assertClusterStatus(t, cl, "stable")
 	})
+
+	t.Run("probe history is created if not set", func(t *testing.T) {
+		// given
+		stable, sec := newToolchainCluster(t, "stable", tcNs, "https://cluster.com")
+		cl := test.NewFakeClient(t, stable, sec)
+		reset := setupCachedClusters(t, cl, stable)
+		defer reset()
+		controller := &Reconciler{Client: cl, Scheme: scheme.Scheme, RequeAfter: requeAfter}
+		controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (bool, error) {
+			return true, nil
+		}
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), reconcile.Request{NamespacedName: test.NamespacedName(tcNs, "stable")})
+
+		// then
+		require.NoError(t, err)
+		require.NotNil(t, controller.ProbeHistory)
+		_, found := controller.ProbeHistory.Get("stable")
+		assert.True(t, found)
+	})
 }
 
 func TestGetClusterHealth(t *testing.T) {
@@ -204,6 +253,171 @@ func TestGetClusterHealth(t *testing.T) {
 	})
 }
 
+func TestReconcileWithClusterCache(t *testing.T) {
+	// given
+	defer gock.Off()
+	stable, sec := newToolchainCluster(t, "stable", "test-namespace", "https://cluster.com")
+	cl := test.NewFakeClient(t, stable, sec)
+	cache := cluster.NewClusterCache()
+	service := cluster.NewToolchainClusterServiceWithClient(cl, logf.Log, "test-namespace", 0, func(*rest.Config, runtimeclient.Options) (runtimeclient.Client, error) {
+		return test.NewFakeClient(t), nil
+	}, cluster.WithClusterCache(cache))
+	require.NoError(t, service.AddOrUpdateToolchainCluster(stable))
+	controller, req := prepareReconcile(stable, cl, requeAfter)
+	controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (bool, error) {
+		return true, nil
+	}
+
+	t.Run("cluster not found in the default cache", func(t *testing.T) {
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.EqualError(t, err, "cluster stable not found in cache")
+	})
+
+	t.Run("cluster found in the configured cache", func(t *testing.T) {
+		// given
+		controller.ClusterCache = cache
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assertClusterStatus(t, cl, "stable", clusterReadyCondition())
+	})
+}
+
+func TestHealthConditionDamping(t *testing.T) {
+	// given
+	stable, sec := newToolchainCluster(t, "stable", "test-namespace", "https://cluster.com")
+	cl := test.NewFakeClient(t, stable, sec)
+	reset := setupCachedClusters(t, cl, stable)
+	defer reset()
+	controller, req := prepareReconcile(stable, cl, requeAfter)
+	clock := newTestClock()
+	controller.now = clock.now
+	controller.FailureThreshold = 3
+	controller.SuccessThreshold = 2
+	healthy := true
+	controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (bool, error) {
+		if !healthy {
+			return false, fmt.Errorf("connection refused")
+		}
+		return true, nil
+	}
+	reconcileAndAssert := func(t *testing.T, expected toolchainv1alpha1.Condition) {
+		clock.advance(requeAfter)
+		recResult, err := controller.Reconcile(context.TODO(), req)
+		require.NoError(t, err)
+		require.Equal(t, reconcile.Result{RequeueAfter: requeAfter}, recResult)
+		assertClusterStatus(t, cl, "stable", expected)
+	}
+
+	t.Run("first health check is reported as it is", func(t *testing.T) {
+		reconcileAndAssert(t, clusterReadyCondition())
+	})
+
+	t.Run("ready until the failure threshold is reached", func(t *testing.T) {
+		// given
+		healthy = false
+
+		// when & then
+		reconcileAndAssert(t, clusterReadyCondition())
+		reconcileAndAssert(t, clusterReadyCondition())
+		reconcileAndAssert(t, clusterOfflineCondition("connection refused"))
+
+		history, found := controller.ProbeHistory.Get("stable")
+		require.True(t, found)
+		assert.Equal(t, 3, history.ConsecutiveFailures)
+		require.Len(t, history.Records, 4)
+		assert.True(t, history.Records[0].Healthy)
+		assert.False(t, history.Records[3].Healthy)
+		assert.Equal(t, toolchainv1alpha1.ToolchainClusterClusterNotReachableReason, history.Records[3].Reason)
+	})
+
+	t.Run("not ready until the success threshold is reached", func(t *testing.T) {
+		// given
+		healthy = true
+
+		// when & then
+		reconcileAndAssert(t, clusterOfflineCondition("connection refused"))
+		reconcileAndAssert(t, clusterReadyCondition())
+	})
+
+	t.Run("brief failure doesn't change the condition", func(t *testing.T) {
+		// given
+		healthy = false
+		reconcileAndAssert(t, clusterReadyCondition())
+
+		// when
+		healthy = true
+
+		// then
+		reconcileAndAssert(t, clusterReadyCondition())
+		history, _ := controller.ProbeHistory.Get("stable")
+		assert.Equal(t, 0, history.ConsecutiveFailures)
+	})
+
+	t.Run("health checks are skipped within the interval", func(t *testing.T) {
+		// given
+		healthy = false
+		reconcileAndAssert(t, clusterReadyCondition())
+		checkHealth := controller.checkHealth
+		checks := 0
+		controller.checkHealth = func(ctx context.Context, clientset *kubeclientset.Clientset) (bool, error) {
+			checks++
+			return checkHealth(ctx, clientset)
+		}
+		defer func() {
+			controller.checkHealth = checkHealth
+		}()
+
+		// when - reconciles triggered by the updates of the ToolchainCluster before the interval elapsed
+		for i := 1; i <= 3; i++ {
+			clock.advance(requeAfter / 10)
+			recResult, err := controller.Reconcile(context.TODO(), req)
+			require.NoError(t, err)
+			require.Equal(t, reconcile.Result{RequeueAfter: requeAfter - time.Duration(i)*requeAfter/10}, recResult)
+		}
+
+		// then
+		assert.Zero(t, checks)
+		assertClusterStatus(t, cl, "stable", clusterReadyCondition())
+		history, _ := controller.ProbeHistory.Get("stable")
+		assert.Equal(t, 1, history.ConsecutiveFailures)
+
+		t.Run("health check after the interval is counted", func(t *testing.T) {
+			// given
+			clock.advance(requeAfter - 3*requeAfter/10)
+
+			// when
+			_, err := controller.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, 1, checks)
+			assertClusterStatus(t, cl, "stable", clusterReadyCondition())
+			history, _ := controller.ProbeHistory.Get("stable")
+			assert.Equal(t, 2, history.ConsecutiveFailures)
+		})
+	})
+
+	t.Run("history is removed when the ToolchainCluster is deleted", func(t *testing.T) {
+		// given
+		require.NoError(t, cl.Delete(context.TODO(), stable))
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		_, found := controller.ProbeHistory.Get("stable")
+		assert.False(t, found)
+	})
+}
+
 func setupCachedClusters(t *testing.T, cl *test.FakeClient, clusters ...*toolchainv1alpha1.ToolchainCluster) func() {
 	service := cluster.NewToolchainClusterServiceWithClient(cl, logf.Log, test.MemberOperatorNs, 0, func(config *rest.Config, options runtimeclient.Options) (runtimeclient.Client, error) {
 		// make sure that insecure is false to make Gock mocking working properly
@@ -229,8 +443,8 @@ func newToolchainCluster(t *testing.T, name, tcNs string, apiEndpoint string) (*
 	return toolchainCluster, secret
 }
 
-func prepareReconcile(toolchainCluster *toolchainv1alpha1.ToolchainCluster, cl *test.FakeClient, requeAfter time.Duration) (Reconciler, reconcile.Request) {
-	controller := Reconciler{
+func prepareReconcile(toolchainCluster *toolchainv1alpha1.ToolchainCluster, cl *test.FakeClient, requeAfter time.Duration) (*Reconciler, reconcile.Request) {
+	controller := &Reconciler{
 		Client:     cl,
 		Scheme:     scheme.Scheme,
 		RequeAfter: requeAfter,
@@ -241,9 +455,44 @@ func prepareReconcile(toolchainCluster *toolchainv1alpha1.ToolchainCluster, cl *
 	return controller, req
 }
 
+// testClock is the clock of the Reconciler moved forward by the tests, so the health checks can be counted without waiting for the interval
+type testClock struct {
+	current time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{current: time.Now()}
+}
+
+func (c *testClock) now() time.Time {
+	return c.current
+}
+
+func (c *testClock) advance(duration time.Duration) {
+	c.current = c.current.Add(duration)
+}
+
 func assertClusterStatus(t *testing.T, cl runtimeclient.Client, clusterName string, clusterConds ...toolchainv1alpha1.Condition) {
 	tc := &toolchainv1alpha1.ToolchainCluster{}
 	err := cl.Get(context.TODO(), test.NamespacedName("test-namespace", clusterName), tc)
 	require.NoError(t, err)
 	test.AssertConditionsMatch(t, tc.Status.Conditions, clusterConds...)
 }
+
+func clusterReadyCondition() toolchainv1alpha1.Condition {
+	return toolchainv1alpha1.Condition{
+		Type:    toolchainv1alpha1.ConditionReady,
+		Status:  corev1.ConditionTrue,
+		Reason:  toolchainv1alpha1.ToolchainClusterClusterReadyReason,
+		Message: healthzOk,
+	}
+}
+
+func clusterNotReadyCondition() toolchainv1alpha1.Condition {
+	return toolchainv1alpha1.Condition{
+		Type:    toolchainv1alpha1.ConditionReady,
+		Status:  corev1.ConditionFalse,
+		Reason:  toolchainv1alpha1.ToolchainClusterClusterNotReadyReason,
+		Message: healthzNotOk,
+	}
+}
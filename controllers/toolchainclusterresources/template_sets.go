@@ -0,0 +1,103 @@
+package toolchainclusterresources
+
+import (
+	"embed"
+	"fmt"
+	"slices"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/template"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// TemplateSetLabelKey is added to all the resources applied from a template set, its value is the name of the set
+const TemplateSetLabelKey = toolchainv1alpha1.LabelKeyPrefix + "template-set"
+
+// ClusterRole is the role of the cluster the operator running the Reconciler is deployed in
+type ClusterRole string
+
+const (
+	HostCluster   ClusterRole = "host"
+	MemberCluster ClusterRole = "member"
+)
+
+// TemplateSet is a named set of templates applied by the Reconciler. Every set is rendered with its own variables and
+// can be applied only in the clusters with certain roles or only when a feature toggle is enabled, so the host-only and
+// the member-only resources can be managed by the same controller.
+type TemplateSet struct {
+	// Name identifies the set. It's the value of the TemplateSetLabelKey label of all the applied resources.
+	Name string
+	// Templates is the embedded filesystem containing the templates of the set
+	Templates *embed.FS
+	// Namespace is the target namespace available as `{{.Namespace}}` in the templates. Defaults to the operator namespace.
+	Namespace string
+	// Variables are the custom variables available as `{{.Values.name}}` in the templates
+	Variables map[string]string
+	// Selector selects the objects from the templates that are applied. All the objects are applied if not set.
+	Selector labels.Selector
+	// ClusterRoles are the roles of the clusters the set is applied in. The set is applied in all the clusters if empty.
+	ClusterRoles []ClusterRole
+	// FeatureToggle is the name of the feature toggle that has to be enabled for the set to be applied
+	// (see Reconciler.FeatureEnabled). The set is always applied if empty.
+	FeatureToggle string
+}
+
+// loadedTemplateSet is a template set with its rendered objects
+type loadedTemplateSet struct {
+	TemplateSet
+	objects []*unstructured.Unstructured
+}
+
+// loadTemplateSets renders the objects of all the template sets
+func (r *Reconciler) loadTemplateSets(operatorNamespace string) error {
+	loaded := make([]loadedTemplateSet, 0, len(r.TemplateSets))
+	names := map[string]bool{}
+	for _, set := range r.TemplateSets {
+		if set.Name == "" {
+			return fmt.Errorf("the name of the template set is not set")
+		}
+		if names[set.Name] {
+			return fmt.Errorf("duplicate template set '%s'", set.Name)
+		}
+		names[set.Name] = true
+		if set.Templates == nil {
+			return fmt.Errorf("no templates FS configured for the template set '%s'", set.Name)
+		}
+		namespace := set.Namespace
+		if namespace == "" {
+			namespace = operatorNamespace
+		}
+		objects, err := template.LoadObjectsFromEmbedFS(set.Templates, &template.Variables{Namespace: namespace, Values: set.Variables})
+		if err != nil {
+			return fmt.Errorf("unable to load the template set '%s': %w", set.Name, err)
+		}
+		if set.Selector != nil {
+			objects = slices.DeleteFunc(objects, func(obj *unstructured.Unstructured) bool {
+				return !set.Selector.Matches(labels.Set(obj.GetLabels()))
+			})
+		}
+		for _, obj := range objects {
+			objLabels := obj.GetLabels()
+			if objLabels == nil {
+				objLabels = map[string]string{}
+			}
+			objLabels[TemplateSetLabelKey] = set.Name
+			obj.SetLabels(objLabels)
+		}
+		loaded = append(loaded, loadedTemplateSet{TemplateSet: set, objects: objects})
+	}
+	r.templateSets = loaded
+	return nil
+}
+
+// included returns true if the set should be applied in the cluster with the current role and feature toggles
+func (r *Reconciler) included(set TemplateSet) bool {
+	if len(set.ClusterRoles) > 0 && !slices.Contains(set.ClusterRoles, r.ClusterRole) {
+		return false
+	}
+	if set.FeatureToggle != "" && (r.FeatureEnabled == nil || !r.FeatureEnabled(set.FeatureToggle)) {
+		return false
+	}
+	return true
+}
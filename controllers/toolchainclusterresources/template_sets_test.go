@@ -0,0 +1,219 @@
+package toolchainclusterresources
+
+import (
+	"context"
+	"embed"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+//go:embed testdata/sets/host/*
+var hostSetFS embed.FS
+
+//go:embed testdata/sets/member/*
+var memberSetFS embed.FS
+
+//go:embed testdata/sets/feature/*
+var featureSetFS embed.FS
+
+func TestTemplateSets(t *testing.T) {
+	sets := []TemplateSet{
+		{
+			Name:         "host",
+			Templates:    &hostSetFS,
+			Namespace:    "host-ns",
+			Variables:    map[string]string{"region": "eu"},
+			Selector:     labels.SelectorFromSet(labels.Set{"tier": "core"}),
+			ClusterRoles: []ClusterRole{HostCluster},
+		},
+		{
+			Name:         "member",
+			Templates:    &memberSetFS,
+			ClusterRoles: []ClusterRole{MemberCluster},
+		},
+		{
+			Name:          "feature",
+			Templates:     &featureSetFS,
+			FeatureToggle: "my-feature",
+		},
+	}
+	newReconciler := func(t *testing.T, role ClusterRole, enabledFeatures ...string) (Reconciler, *test.FakeClient) {
+		cl := test.NewFakeClient(t)
+		controller := Reconciler{
+			Client:       cl,
+			Scheme:       scheme.Scheme,
+			TemplateSets: sets,
+			ClusterRole:  role,
+			FeatureEnabled: func(name string) bool {
+				for _, feature := range enabledFeatures {
+					if feature == name {
+						return true
+					}
+				}
+				return false
+			},
+			FieldManager:      "testOwner",
+			operatorNamespace: test.MemberOperatorNs,
+		}
+		require.NoError(t, controller.loadTemplateSets(test.MemberOperatorNs))
+		return controller, cl
+	}
+	assertConfigMap := func(t *testing.T, cl *test.FakeClient, namespace, name, set string) *v1.ConfigMap {
+		t.Helper()
+		cm := &v1.ConfigMap{}
+		require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, cm))
+		assert.Equal(t, ResourceControllerLabelValue, cm.Labels[toolchainv1alpha1.ProviderLabelKey])
+		assert.Equal(t, set, cm.Labels[TemplateSetLabelKey])
+		return cm
+	}
+	assertNoConfigMap := func(t *testing.T, cl *test.FakeClient, namespace, name string) {
+		t.Helper()
+		err := cl.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, &v1.ConfigMap{})
+		assert.True(t, apierrors.IsNotFound(err), "unexpected error: %v", err)
+	}
+
+	t.Run("host cluster", func(t *testing.T) {
+		// given
+		controller, cl := newReconciler(t, HostCluster)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), reconcile.Request{})
+
+		// then
+		require.NoError(t, err)
+		cm := assertConfigMap(t, cl, "host-ns", "host-config", "host")
+		assert.Equal(t, map[string]string{"region": "eu"}, cm.Data)
+		assertNoConfigMap(t, cl, "host-ns", "host-optional-config")
+		assertNoConfigMap(t, cl, test.MemberOperatorNs, "member-config")
+		assertNoConfigMap(t, cl, test.MemberOperatorNs, "feature-config")
+	})
+
+	t.Run("member cluster", func(t *testing.T) {
+		// given
+		controller, cl := newReconciler(t, MemberCluster)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), reconcile.Request{})
+
+		// then
+		require.NoError(t, err)
+		assertConfigMap(t, cl, test.MemberOperatorNs, "member-config", "member")
+		assertNoConfigMap(t, cl, "host-ns", "host-config")
+		assertNoConfigMap(t, cl, test.MemberOperatorNs, "feature-config")
+	})
+
+	t.Run("enabled feature", func(t *testing.T) {
+		// given
+		controller, cl := newReconciler(t, MemberCluster, "my-feature")
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), reconcile.Request{})
+
+		// then
+		require.NoError(t, err)
+		assertConfigMap(t, cl, test.MemberOperatorNs, "member-config", "member")
+		assertConfigMap(t, cl, test.MemberOperatorNs, "feature-config", "feature")
+	})
+
+	t.Run("no feature toggles configured", func(t *testing.T) {
+		// given
+		controller, cl := newReconciler(t, MemberCluster)
+		controller.FeatureEnabled = nil
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), reconcile.Request{})
+
+		// then
+		require.NoError(t, err)
+		assertNoConfigMap(t, cl, test.MemberOperatorNs, "feature-config")
+	})
+
+	t.Run("objects of the excluded set are deleted", func(t *testing.T) {
+		// given
+		controller, cl := newReconciler(t, MemberCluster, "my-feature")
+		_, err := controller.Reconcile(context.TODO(), reconcile.Request{})
+		require.NoError(t, err)
+		assertConfigMap(t, cl, test.MemberOperatorNs, "feature-config", "feature")
+		controller.FeatureEnabled = nil
+
+		// when
+		_, err = controller.Reconcile(context.TODO(), reconcile.Request{})
+
+		// then
+		require.NoError(t, err)
+		assertConfigMap(t, cl, test.MemberOperatorNs, "member-config", "member")
+		assertNoConfigMap(t, cl, test.MemberOperatorNs, "feature-config")
+	})
+
+	t.Run("together with the templates", func(t *testing.T) {
+		// given
+		controller, cl := newReconciler(t, MemberCluster)
+		sa := &v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "existing-sa", Namespace: test.MemberOperatorNs}}
+		withTemplates, _ := prepareReconcile(sa, cl, &serviceAccountFS)
+		controller.Templates = withTemplates.Templates
+		controller.templateObjects = withTemplates.templateObjects
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), reconcile.Request{})
+
+		// then
+		require.NoError(t, err)
+		checkExpectedServiceAccountResources(t, cl)
+		assertConfigMap(t, cl, test.MemberOperatorNs, "member-config", "member")
+		assert.Len(t, controller.allTemplateObjects(), 6)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Run("invalid template sets", func(t *testing.T) {
+			for expectedErr, sets := range map[string][]TemplateSet{
+				"the name of the template set is not set":                  {{Templates: &memberSetFS}},
+				"duplicate template set 'member'":                          {{Name: "member", Templates: &memberSetFS}, {Name: "member", Templates: &memberSetFS}},
+				"no templates FS configured for the template set 'member'": {{Name: "member"}},
+			} {
+				t.Run(expectedErr, func(t *testing.T) {
+					// given
+					controller := Reconciler{TemplateSets: sets}
+
+					// when
+					err := controller.loadTemplateSets(test.MemberOperatorNs)
+
+					// then
+					require.EqualError(t, err, expectedErr)
+				})
+			}
+		})
+
+		t.Run("missing variable", func(t *testing.T) {
+			// given
+			controller := Reconciler{TemplateSets: []TemplateSet{{Name: "host", Templates: &hostSetFS}}}
+
+			// when
+			err := controller.loadTemplateSets(test.MemberOperatorNs)
+
+			// then
+			require.ErrorContains(t, err, "unable to load the template set 'host'")
+		})
+
+		t.Run("template sets not loaded", func(t *testing.T) {
+			// given
+			controller := Reconciler{Client: test.NewFakeClient(t), TemplateSets: sets}
+
+			// when
+			_, err := controller.Reconcile(context.TODO(), reconcile.Request{})
+
+			// then
+			require.EqualError(t, err, "the template sets are not loaded")
+		})
+	})
+}
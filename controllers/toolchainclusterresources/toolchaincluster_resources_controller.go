@@ -4,6 +4,7 @@ import (
 	"context"
 	"embed"
 	"fmt"
+	"slices"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	commoncontroller "github.com/codeready-toolchain/toolchain-common/controllers"
@@ -13,6 +14,8 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
@@ -25,55 +28,148 @@ import (
 // It's then used to filter all the events on those resources by using a mapper function in the watcher configuration.
 const ResourceControllerLabelValue = "toolchaincluster-resources-controller" // TODO move this label value to api repo
 
+// OperatorNamespaceLabelKey is added to all the resources managed by this controller, its value is the namespace of the operator
+// running the controller. It distinguishes the resources of the host and member operators running in the same cluster when
+// pruning the resources that were removed from the templates.
+const OperatorNamespaceLabelKey = toolchainv1alpha1.LabelKeyPrefix + "operator-namespace"
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager, operatorNamespace string) error {
 	// check for required templates FS directory
-	if r.Templates == nil {
+	if r.Templates == nil && len(r.TemplateSets) == 0 {
 		return fmt.Errorf("no templates FS configured")
 	}
 
 	build := ctrl.NewControllerManagedBy(mgr).
 		For(&v1.ServiceAccount{})
+	r.operatorNamespace = operatorNamespace
 
 	// add watcher for all kinds from given templates
-	var err error
-	r.templateObjects, err = template.LoadObjectsFromEmbedFS(r.Templates, &template.Variables{Namespace: operatorNamespace})
-	if err != nil {
+	if r.Templates != nil {
+		var err error
+		r.templateObjects, err = template.LoadObjectsFromEmbedFS(r.Templates, &template.Variables{Namespace: operatorNamespace})
+		if err != nil {
+			return err
+		}
+	}
+	if err := r.loadTemplateSets(operatorNamespace); err != nil {
 		return err
 	}
 
 	mapToOwnerByLabel := handler.EnqueueRequestsFromMapFunc(commoncontroller.MapToControllerByMatchingLabel(toolchainv1alpha1.ProviderLabelKey, ResourceControllerLabelValue))
-	for _, obj := range r.templateObjects {
-		build = build.Watches(obj.DeepCopyObject().(runtimeclient.Object), mapToOwnerByLabel, builder.WithPredicates(commonpredicates.LabelsAndGenerationPredicate{}))
+	for _, gvk := range r.watchedGVKs() {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		build = build.Watches(obj, mapToOwnerByLabel, builder.WithPredicates(commonpredicates.LabelsAndGenerationPredicate{}))
 	}
 	return build.Complete(r)
 }
 
 // Reconciler reconciles a ToolchainCluster object
 type Reconciler struct {
-	Client          runtimeclient.Client
-	Scheme          *runtime.Scheme
-	Templates       *embed.FS
-	FieldManager    string
-	templateObjects []*unstructured.Unstructured
+	Client runtimeclient.Client
+	Scheme *runtime.Scheme
+	// Templates are applied in the operator namespace. Either the Templates or the TemplateSets (or both) have to be configured.
+	Templates *embed.FS
+	// TemplateSets are the named sets of templates, each of them applied with its own variables and in its own namespace
+	TemplateSets []TemplateSet
+	// ClusterRole is the role of the cluster the operator is running in. Only the template sets for this role are applied.
+	ClusterRole ClusterRole
+	// FeatureEnabled returns true if the feature toggle with the given name is enabled. The template sets requiring
+	// a feature toggle are not applied if not set.
+	FeatureEnabled    func(name string) bool
+	FieldManager      string
+	operatorNamespace string
+	templateObjects   []*unstructured.Unstructured
+	templateSets      []loadedTemplateSet
 }
 
-// Reconcile loads all the manifests from a given embed.FS folder, evaluates the supported variables and applies the objects in the cluster.
+// Reconcile applies the objects loaded from the manifests in the Templates and in the template sets that are included in the cluster.
+// The objects of the watched kinds that were applied by this controller but are no longer in the templates (or belong to a template set
+// that is not included in the cluster anymore) are deleted, unless they are annotated with the applycl.PruneProtectionAnnotationKey.
+// Only the objects labeled with the namespace of the operator and living in the namespaces of the templates (or cluster-scoped) are deleted,
+// so the controllers of different operators running in the same cluster don't delete each other's objects. The namespaced objects applied
+// by the previous versions of the controller, which are labeled with the provider label only, are deleted as well.
 func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
 	reqLogger := log.FromContext(ctx)
 	reqLogger.Info("Reconciling ToolchainCluster resources controller")
 	// check for required templates FS directory
-	if r.Templates == nil {
+	if r.Templates == nil && len(r.TemplateSets) == 0 {
 		return reconcile.Result{}, fmt.Errorf("no templates FS configured")
 	}
+	if len(r.templateSets) != len(r.TemplateSets) {
+		return reconcile.Result{}, fmt.Errorf("the template sets are not loaded")
+	}
+	if r.operatorNamespace == "" {
+		return reconcile.Result{}, fmt.Errorf("the operator namespace is not set")
+	}
+
+	objects := slices.Clone(r.templateObjects)
+	for _, set := range r.templateSets {
+		if !r.included(set.TemplateSet) {
+			reqLogger.Info("skipping the template set", "name", set.Name)
+			continue
+		}
+		objects = append(objects, set.objects...)
+	}
 
-	// apply all the objects with a custom label
+	// apply all the objects with a custom label and delete the labeled objects of the watched kinds that are no longer
+	// in the templates (or in the included template sets), unless they are protected by the PruneProtectionAnnotationKey annotation
 	newLabels := map[string]string{
 		toolchainv1alpha1.ProviderLabelKey: ResourceControllerLabelValue,
+		OperatorNamespaceLabelKey:          r.operatorNamespace,
+	}
+	cl := applycl.NewSSAApplyClient(r.Client, r.FieldManager)
+	// the objects applied by the previous versions of the controller are labelled with the provider label only, they are pruned
+	// too if they are in the namespaces of the templates
+	pruned, err := applycl.ApplyAllAndPrune(ctx, cl, objects,
+		applycl.Prune(newLabels, r.watchedGVKs()...),
+		applycl.PruneInNamespaces(r.templateNamespaces()...),
+		applycl.PruneLegacyLabels(map[string]string{toolchainv1alpha1.ProviderLabelKey: ResourceControllerLabelValue}))
+	if removed := deletedObjects(pruned); len(removed) > 0 {
+		reqLogger.Info("deleted the objects that were removed from the templates", "objects", removed)
 	}
+	return reconcile.Result{}, err
+}
 
-	// TODO implement delete logic for objects that were renamed/removed from the templates
+// allTemplateObjects returns the objects of the Templates and of all the template sets
+func (r *Reconciler) allTemplateObjects() []*unstructured.Unstructured {
+	objects := slices.Clone(r.templateObjects)
+	for _, set := range r.templateSets {
+		objects = append(objects, set.objects...)
+	}
+	return objects
+}
 
-	cl := applycl.NewSSAApplyClient(r.Client, r.FieldManager)
-	return reconcile.Result{}, applycl.ApplyAll(ctx, cl, r.templateObjects, applycl.EnsureLabels(newLabels)) // apply objects on the cluster
+// watchedGVKs returns the kinds of all the objects in the Templates and in the template sets (including the ones that are not
+// included in the cluster), so the objects of a kind that is completely removed from the templates are not deleted
+func (r *Reconciler) watchedGVKs() []schema.GroupVersionKind {
+	var gvks []schema.GroupVersionKind
+	for _, obj := range r.allTemplateObjects() {
+		if gvk := obj.GroupVersionKind(); !slices.Contains(gvks, gvk) {
+			gvks = append(gvks, gvk)
+		}
+	}
+	return gvks
+}
+
+// templateNamespaces returns the namespaces of all the objects in the Templates and in the template sets (including the ones that are not
+// included in the cluster) together with the operator namespace
+func (r *Reconciler) templateNamespaces() []string {
+	namespaces := []string{r.operatorNamespace}
+	for _, obj := range r.allTemplateObjects() {
+		if namespace := obj.GetNamespace(); namespace != "" && !slices.Contains(namespaces, namespace) {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces
+}
+
+// deletedObjects returns the kinds, namespaces and names of the pruned objects
+func deletedObjects(pruned []*applycl.ApplyResult) []string {
+	deleted := make([]string, 0, len(pruned))
+	for _, result := range pruned {
+		deleted = append(deleted, fmt.Sprintf("%s %s", result.GVK.Kind, types.NamespacedName{Namespace: result.Namespace, Name: result.Name}))
+	}
+	return deleted
 }
@@ -6,11 +6,13 @@ import (
 	"testing"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	applycl "github.com/codeready-toolchain/toolchain-common/pkg/client"
 	"github.com/codeready-toolchain/toolchain-common/pkg/template"
 	"github.com/codeready-toolchain/toolchain-common/pkg/test"
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 	rbac "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -68,6 +70,99 @@ func TestToolchainClusterResources(t *testing.T) {
 		require.Equal(t, ResourceControllerLabelValue, cr.Labels[toolchainv1alpha1.ProviderLabelKey])
 	})
 
+	t.Run("controller should delete the resources removed from the templates", func(t *testing.T) {
+		// given
+		providerLabels := map[string]string{
+			toolchainv1alpha1.ProviderLabelKey: ResourceControllerLabelValue,
+			OperatorNamespaceLabelKey:          test.MemberOperatorNs,
+		}
+		newRole := func(name string, labels, annotations map[string]string) *rbac.Role {
+			return &rbac.Role{ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   test.MemberOperatorNs,
+				Labels:      labels,
+				Annotations: annotations,
+			}}
+		}
+		removed := newRole("removed", providerLabels, nil)
+		protected := newRole("protected", providerLabels, map[string]string{applycl.PruneProtectionAnnotationKey: "true"})
+		notManaged := newRole("not-managed", nil, nil)
+		otherKind := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "other-kind", Namespace: test.MemberOperatorNs, Labels: providerLabels}}
+		cl := test.NewFakeClient(t, sa, removed, protected, notManaged, otherKind)
+		controller, req := prepareReconcile(sa, cl, &serviceAccountFS)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		checkExpectedServiceAccountResources(t, cl)
+		err = cl.Get(context.TODO(), client.ObjectKeyFromObject(removed), &rbac.Role{})
+		require.True(t, apierrors.IsNotFound(err), "unexpected error: %v", err)
+		require.NoError(t, cl.Get(context.TODO(), client.ObjectKeyFromObject(protected), &rbac.Role{}))
+		require.NoError(t, cl.Get(context.TODO(), client.ObjectKeyFromObject(notManaged), &rbac.Role{}))
+		require.NoError(t, cl.Get(context.TODO(), client.ObjectKeyFromObject(otherKind), &v1.ConfigMap{})) // the kind is not in the templates
+	})
+
+	t.Run("controller should delete the legacy resources removed from the templates", func(t *testing.T) {
+		// given
+		legacyLabels := map[string]string{
+			toolchainv1alpha1.ProviderLabelKey: ResourceControllerLabelValue,
+		}
+		legacy := &rbac.Role{ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: test.MemberOperatorNs, Labels: legacyLabels}}
+		legacyInOtherNamespace := &rbac.Role{ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: "other", Labels: legacyLabels}}
+		legacyApplied := &rbac.Role{ObjectMeta: metav1.ObjectMeta{Name: "toolchaincluster-host", Namespace: test.MemberOperatorNs, Labels: legacyLabels}}
+		cl := test.NewFakeClient(t, sa, legacy, legacyInOtherNamespace, legacyApplied)
+		controller, req := prepareReconcile(sa, cl, &serviceAccountFS)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		checkExpectedServiceAccountResources(t, cl)
+		err = cl.Get(context.TODO(), client.ObjectKeyFromObject(legacy), &rbac.Role{})
+		require.True(t, apierrors.IsNotFound(err), "unexpected error: %v", err)
+		require.NoError(t, cl.Get(context.TODO(), client.ObjectKeyFromObject(legacyInOtherNamespace), &rbac.Role{}))
+		applied := &rbac.Role{}
+		require.NoError(t, cl.Get(context.TODO(), client.ObjectKeyFromObject(legacyApplied), applied))
+		require.Equal(t, test.MemberOperatorNs, applied.Labels[OperatorNamespaceLabelKey])
+	})
+
+	t.Run("controllers of different operators in the same cluster don't delete each other's resources", func(t *testing.T) {
+		// given
+		hostSA := &v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "existing-sa", Namespace: test.HostOperatorNs}}
+		otherOperatorCR := &rbac.ClusterRole{ObjectMeta: metav1.ObjectMeta{
+			Name: "host-cr",
+			Labels: map[string]string{
+				toolchainv1alpha1.ProviderLabelKey: ResourceControllerLabelValue,
+				OperatorNamespaceLabelKey:          test.HostOperatorNs,
+			},
+		}}
+		cl := test.NewFakeClient(t, sa, hostSA, otherOperatorCR)
+		memberController, memberReq := prepareReconcile(sa, cl, &serviceAccountFS)
+		hostController, hostReq := prepareReconcile(hostSA, cl, &serviceAccountFS)
+		_, err := memberController.Reconcile(context.TODO(), memberReq)
+		require.NoError(t, err)
+
+		// when
+		_, err = hostController.Reconcile(context.TODO(), hostReq)
+		require.NoError(t, err)
+		_, err = memberController.Reconcile(context.TODO(), memberReq)
+		require.NoError(t, err)
+		memberCRController, memberCRReq := prepareReconcile(sa, cl, &clusterRoleFS)
+		_, err = memberCRController.Reconcile(context.TODO(), memberCRReq)
+		require.NoError(t, err)
+
+		// then
+		checkExpectedServiceAccountResources(t, cl)
+		for _, obj := range []client.Object{&v1.ServiceAccount{}, &rbac.Role{}, &rbac.RoleBinding{}} {
+			require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: test.HostOperatorNs, Name: "toolchaincluster-host"}, obj))
+			require.Equal(t, test.HostOperatorNs, obj.GetLabels()[OperatorNamespaceLabelKey])
+		}
+		require.NoError(t, cl.Get(context.TODO(), client.ObjectKeyFromObject(otherOperatorCR), &rbac.ClusterRole{}))
+	})
+
 	t.Run("controller should return error when not templates are configured", func(t *testing.T) {
 		// given
 		controller, req := prepareReconcile(sa, cl, nil) // no templates are passed to the controller initialization
@@ -107,11 +202,12 @@ func prepareReconcile(sa *v1.ServiceAccount, cl *test.FakeClient, templates *emb
 		return emptyReconciler(cl)
 	}
 	controller := Reconciler{
-		Client:          cl,
-		Scheme:          scheme.Scheme,
-		Templates:       templates,
-		FieldManager:    "testOwner",
-		templateObjects: templateObjects,
+		Client:            cl,
+		Scheme:            scheme.Scheme,
+		Templates:         templates,
+		FieldManager:      "testOwner",
+		operatorNamespace: sa.Namespace,
+		templateObjects:   templateObjects,
 	}
 	req := reconcile.Request{
 		NamespacedName: test.NamespacedName(sa.Namespace, sa.Name),
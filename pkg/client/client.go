@@ -15,6 +15,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -40,6 +41,7 @@ type applyObjectConfiguration struct {
 	owner             v1.Object
 	forceUpdate       bool
 	saveConfiguration bool
+	dryRun            bool
 }
 
 func newApplyObjectConfiguration(options ...ApplyObjectOption) applyObjectConfiguration {
@@ -47,6 +49,7 @@ func newApplyObjectConfiguration(options ...ApplyObjectOption) applyObjectConfig
 		owner:             nil,
 		forceUpdate:       false,
 		saveConfiguration: true,
+		dryRun:            false,
 	}
 	for _, apply := range options {
 		apply(&config)
@@ -79,32 +82,102 @@ func SaveConfiguration(saveConfiguration bool) ApplyObjectOption {
 	}
 }
 
+// DryRun runs the whole decision path of the apply logic without creating or updating
+// the resource in the cluster (default: `false`). The outcome is available in the returned ApplyResult.
+// The provided object is not modified when the dry-run mode is enabled.
+func DryRun(dryRun bool) ApplyObjectOption {
+	return func(config *applyObjectConfiguration) {
+		config.dryRun = dryRun
+	}
+}
+
+// ApplyAction the action that was (or would be, in the dry-run mode) taken when applying an object
+type ApplyAction string
+
+const (
+	// ApplyActionCreate the object is missing in the cluster and is created
+	ApplyActionCreate ApplyAction = "create"
+	// ApplyActionUpdate the object exists in the cluster and is updated
+	ApplyActionUpdate ApplyAction = "update"
+	// ApplyActionNoOp the object exists in the cluster and nothing changed
+	ApplyActionNoOp ApplyAction = "no-op"
+	// ApplyActionSkip the object was not applied at all because of the SkipIf option of the SSAApplyClient
+	ApplyActionSkip ApplyAction = "skip"
+	// ApplyActionDelete the object was deleted because it was no longer part of the applied objects (see the Prune option of the SSAApplyClient)
+	ApplyActionDelete ApplyAction = "delete"
+)
+
+// ApplyResult the outcome of applying a single object
+type ApplyResult struct {
+	// Action the action that was (or would be, in the dry-run mode) taken
+	Action ApplyAction
+	// DryRun is `true` when the object was not created nor updated in the cluster
+	DryRun bool
+	// GVK the GroupVersionKind of the applied object
+	GVK schema.GroupVersionKind
+	// Namespace the namespace of the applied object
+	Namespace string
+	// Name the name of the applied object
+	Name string
+	// Diff the field-level differences between the live object and the desired one.
+	// It's empty when the object is created, skipped or deleted.
+	Diff []FieldDiff
+	// createdOrUpdated is the legacy outcome returned by ApplyObject and Apply: `true` if the object was created or if its generation
+	// was changed by the update (or, in the dry-run mode, if the object would be created or updated)
+	createdOrUpdated bool
+}
+
+// Changed returns `true` if the object was (or would be, in the dry-run mode) either created, updated or deleted
+func (r *ApplyResult) Changed() bool {
+	return r.Action == ApplyActionCreate || r.Action == ApplyActionUpdate || r.Action == ApplyActionDelete
+}
+
 // ApplyRuntimeObject casts the provided object to client.Object and calls ApplyClient.ApplyObject method
 func (c ApplyClient) ApplyRuntimeObject(ctx context.Context, obj runtime.Object, options ...ApplyObjectOption) (bool, error) {
 	clientObj, ok := obj.(client.Object)
 	if !ok {
 		return false, fmt.Errorf("unable to cast of the object to client.Object: %+v", obj)
 	}
-	return c.applyObject(ctx, clientObj, options...)
+	result, err := c.applyObject(ctx, clientObj, options...)
+	return result.createdOrUpdated, err
 }
 
 // ApplyObject creates the object if is missing and if the owner object is provided, then it's set as a controller reference.
 // If the objects exists then when the spec content has changed (based on the content of the annotation in the original object) then it
 // is automatically updated. If it looks to be same then based on the value of forceUpdate param it updates the object or not.
 // The return boolean says if the object was either created or updated (`true`). If nothing changed (ie, the generation was not
-// incremented by the server), then it returns `false`.
+// incremented by the server), then it returns `false`. In the dry-run mode, it says if the object would be created or updated.
+// Use ApplyObjectWithResult to find out if any of the applied fields differs from the existing object.
 func (c ApplyClient) ApplyObject(ctx context.Context, obj client.Object, options ...ApplyObjectOption) (bool, error) {
+	result, err := c.ApplyObjectWithResult(ctx, obj, options...)
+	return result.createdOrUpdated, err
+}
+
+// ApplyObjectWithResult does the same as ApplyObject, but it returns a structured result that contains the action that was taken
+// (create, update or no-op) and the field-level diff between the live object and the desired one.
+// When the DryRun option is used, then the object is neither created nor updated and the result describes what would be done.
+func (c ApplyClient) ApplyObjectWithResult(ctx context.Context, obj client.Object, options ...ApplyObjectOption) (*ApplyResult, error) {
 	gvk := obj.GetObjectKind().GroupVersionKind()
-	createdOrUpdated, err := c.applyObject(ctx, obj, options...)
+	result, err := c.applyObject(ctx, obj, options...)
 	if err != nil {
-		return createdOrUpdated, fmt.Errorf("unable to create resource of kind: %s, version: %s: %w", gvk.Kind, gvk.Version, err)
+		return result, fmt.Errorf("unable to create resource of kind: %s, version: %s: %w", gvk.Kind, gvk.Version, err)
 	}
-	return createdOrUpdated, nil
+	return result, nil
 }
 
-func (c ApplyClient) applyObject(ctx context.Context, obj client.Object, options ...ApplyObjectOption) (bool, error) {
+func (c ApplyClient) applyObject(ctx context.Context, obj client.Object, options ...ApplyObjectOption) (*ApplyResult, error) {
 	// gets the meta accessor to the new resource
 	config := newApplyObjectConfiguration(options...)
+	if config.dryRun {
+		// don't modify the object provided by the caller
+		obj = obj.DeepCopyObject().(client.Object)
+	}
+	result := &ApplyResult{
+		DryRun:    config.dryRun,
+		GVK:       obj.GetObjectKind().GroupVersionKind(),
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+	}
 
 	// creates a deepcopy of the new resource to be used to check if it already exists
 	existing := obj.DeepCopyObject().(client.Object)
@@ -125,9 +198,11 @@ func (c ApplyClient) applyObject(ctx context.Context, obj client.Object, options
 	if err := c.Get(ctx, namespacedName, existing); err != nil {
 		if apierrors.IsNotFound(err) {
 			obj.SetResourceVersion("") // reset resource version when creating to avoid error: resourceVersion should not be set on objects to be created
-			return true, c.createObj(ctx, obj, config.owner)
+			result.Action = ApplyActionCreate
+			result.createdOrUpdated = true
+			return result, c.createObj(ctx, obj, config.owner, config.dryRun)
 		}
-		return false, fmt.Errorf("unable to get the resource '%v': %w", existing, err)
+		return result, fmt.Errorf("unable to get the resource '%v': %w", existing, err)
 	}
 
 	// as it already exists, check using the UpdateStrategy if it should be updated
@@ -136,7 +211,8 @@ func (c ApplyClient) applyObject(ctx context.Context, obj client.Object, options
 		if existingAnnotations != nil {
 			lastApplied, lastAppliedFound := existingAnnotations[LastAppliedConfigurationAnnotationKey]
 			if lastAppliedFound && newConfiguration != "" && newConfiguration == lastApplied {
-				return false, nil
+				result.Action = ApplyActionNoOp
+				return result, nil
 			}
 		}
 	}
@@ -147,6 +223,9 @@ func (c ApplyClient) applyObject(ctx context.Context, obj client.Object, options
 	originalGeneration := existing.GetGeneration()
 	obj.SetResourceVersion(existing.GetResourceVersion())
 
+	// keep a copy of the live object for computing the diff, the existing one may be modified below
+	live := existing.DeepCopyObject().(client.Object)
+
 	// Special handling of ServiceAccounts is required because if a ServiceAccount is reapplied when it already exists, it causes Kubernetes controllers to
 	// automatically create new Secrets for the ServiceAccounts. After enough time the number of Secrets created will hit the Secrets quota and then no new
 	// Secrets can be created. To prevent this from happening, we keep the existing refs to secrets.
@@ -161,14 +240,33 @@ func (c ApplyClient) applyObject(ctx context.Context, obj client.Object, options
 	// the update will fail with the following error:
 	// `Service "<name>" is invalid: spec.clusterIP: Invalid value: "": field is immutable`
 	if err := RetainClusterIP(obj, existing); err != nil {
-		return false, err
+		return result, err
 	}
-	if err := c.Update(ctx, obj); err != nil {
-		return false, fmt.Errorf("unable to update the resource '%v': %w", obj, err)
+
+	diff, err := diffObjects(live, obj)
+	if err != nil {
+		return result, fmt.Errorf("unable to compute the diff of the resource '%v': %w", obj, err)
+	}
+	result.Diff = diff
+	// the update is reported by the diff in both modes - the generation is not changed by the updates of the metadata
+	// or of the objects without the spec (eg. the data of the ConfigMaps)
+	if len(diff) == 0 {
+		result.Action = ApplyActionNoOp
+	} else {
+		result.Action = ApplyActionUpdate
+	}
+
+	if config.dryRun {
+		result.createdOrUpdated = result.Changed()
+		return result, nil
 	}
 
+	if err := c.Update(ctx, obj); err != nil {
+		return result, fmt.Errorf("unable to update the resource '%v': %w", obj, err)
+	}
 	// check if it was changed or not
-	return originalGeneration != obj.GetGeneration(), nil
+	result.createdOrUpdated = originalGeneration != obj.GetGeneration()
+	return result, nil
 }
 
 // RetainClusterIP sets the `spec.clusterIP` value from the given 'existing' object
@@ -231,13 +329,16 @@ func marshalObjectContent(newResource runtime.Object) ([]byte, error) {
 	return json.Marshal(newResource)
 }
 
-func (c ApplyClient) createObj(ctx context.Context, newResource client.Object, owner v1.Object) error {
+func (c ApplyClient) createObj(ctx context.Context, newResource client.Object, owner v1.Object, dryRun bool) error {
 	if owner != nil {
 		err := controllerutil.SetControllerReference(owner, newResource, c.Scheme())
 		if err != nil {
 			return errors.Wrap(err, "unable to set controller references")
 		}
 	}
+	if dryRun {
+		return nil
+	}
 	return c.Create(ctx, newResource)
 }
 
@@ -245,17 +346,31 @@ func (c ApplyClient) createObj(ctx context.Context, newResource client.Object, o
 // returns `true, nil` if at least one of the objects was created or modified,
 // `false, nil` if nothing changed, and `false, err` if an error occurred
 func (c ApplyClient) Apply(ctx context.Context, toolchainObjects []client.Object, newLabels map[string]string) (bool, error) {
+	results, err := c.ApplyWithResults(ctx, toolchainObjects, newLabels)
+	if err != nil {
+		return false, err
+	}
 	createdOrUpdated := false
+	for _, result := range results {
+		createdOrUpdated = createdOrUpdated || result.createdOrUpdated
+	}
+	return createdOrUpdated, nil
+}
+
+// ApplyWithResults applies the objects the same way as Apply does, but it returns the structured result for each of the objects.
+// The additional options are used when applying every single object, so the DryRun option can be used to preview the changes.
+func (c ApplyClient) ApplyWithResults(ctx context.Context, toolchainObjects []client.Object, newLabels map[string]string, options ...ApplyObjectOption) ([]*ApplyResult, error) {
+	results := make([]*ApplyResult, 0, len(toolchainObjects))
 	for _, toolchainObject := range toolchainObjects {
 		MergeLabels(toolchainObject, newLabels)
 
-		result, err := c.ApplyObject(ctx, toolchainObject, ForceUpdate(true))
+		result, err := c.ApplyObjectWithResult(ctx, toolchainObject, append([]ApplyObjectOption{ForceUpdate(true)}, options...)...)
 		if err != nil {
-			return false, fmt.Errorf("unable to create resource of kind: %s, version: %s: %w", toolchainObject.GetObjectKind().GroupVersionKind().Kind, toolchainObject.GetObjectKind().GroupVersionKind().Version, err)
+			return nil, fmt.Errorf("unable to create resource of kind: %s, version: %s: %w", toolchainObject.GetObjectKind().GroupVersionKind().Kind, toolchainObject.GetObjectKind().GroupVersionKind().Version, err)
 		}
-		createdOrUpdated = createdOrUpdated || result
+		results = append(results, result)
 	}
-	return createdOrUpdated, nil
+	return results, nil
 }
 
 // MergeLabels gets current exiting labels and merges them with the new ones provided
@@ -22,6 +22,7 @@ import (
 	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -441,6 +442,302 @@ func TestApplySingle(t *testing.T) {
 	})
 }
 
+func TestApplyObjectWithResult(t *testing.T) {
+	// given
+	addToScheme(t)
+
+	newService := func() *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "registration-service",
+				Namespace: "toolchain-host-operator",
+			},
+			Spec: corev1.ServiceSpec{
+				ClusterIP: "10.2.3.4",
+				Selector: map[string]string{
+					"run": "registration-service",
+				},
+			},
+		}
+	}
+
+	t.Run("in dry-run mode", func(t *testing.T) {
+
+		t.Run("when object is missing, it should report create", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			obj := newService()
+
+			// when
+			result, err := cl.ApplyObjectWithResult(context.TODO(), obj, client.DryRun(true))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyActionCreate, result.Action)
+			assert.True(t, result.DryRun)
+			assert.True(t, result.Changed())
+			assert.Empty(t, result.Diff)
+			assert.Equal(t, "registration-service", result.Name)
+			assert.Equal(t, "toolchain-host-operator", result.Namespace)
+			assert.Empty(t, obj.Annotations) // the provided object is not modified
+			err = cli.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(obj), &corev1.Service{})
+			assert.True(t, apierrors.IsNotFound(err))
+		})
+
+		t.Run("when object is missing and the owner is invalid, it should fail", func(t *testing.T) {
+			// given
+			cl, _ := newClient(t)
+			owner := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "owner",
+					Namespace: "another-namespace",
+				},
+			}
+
+			// when
+			_, err := cl.ApplyObjectWithResult(context.TODO(), newService(), client.DryRun(true), client.SetOwner(owner))
+
+			// then
+			require.ErrorContains(t, err, "unable to set controller references")
+		})
+
+		t.Run("when spec is different, it should report update with the diff", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			_, err := cl.ApplyObject(context.TODO(), newService())
+			require.NoError(t, err)
+			modified := newService()
+			modified.Spec.ClusterIP = ""
+			modified.Spec.Selector["run"] = "all-services"
+
+			// when
+			result, err := cl.ApplyObjectWithResult(context.TODO(), modified, client.DryRun(true), client.ForceUpdate(true))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyActionUpdate, result.Action)
+			assert.Equal(t, []client.FieldDiff{
+				{Path: "spec.selector.run", Live: "registration-service", Desired: "all-services"},
+			}, result.Diff)
+			inCluster := &corev1.Service{}
+			require.NoError(t, cli.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(modified), inCluster))
+			assert.Equal(t, "registration-service", inCluster.Spec.Selector["run"]) // not updated
+			assert.Empty(t, modified.ResourceVersion)                               // the provided object is not modified
+		})
+
+		t.Run("when the object is the same, it should report no-op", func(t *testing.T) {
+			// given
+			cl, _ := newClient(t)
+			_, err := cl.ApplyObject(context.TODO(), newService())
+			require.NoError(t, err)
+
+			t.Run("with forceUpdate=false", func(t *testing.T) {
+				// when
+				result, err := cl.ApplyObjectWithResult(context.TODO(), newService(), client.DryRun(true))
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, client.ApplyActionNoOp, result.Action)
+				assert.False(t, result.Changed())
+			})
+
+			t.Run("with forceUpdate=true", func(t *testing.T) {
+				// when
+				result, err := cl.ApplyObjectWithResult(context.TODO(), newService(), client.DryRun(true), client.ForceUpdate(true))
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, client.ApplyActionNoOp, result.Action)
+				assert.Empty(t, result.Diff)
+			})
+		})
+
+		t.Run("when a field was removed from the template, it should be part of the diff", func(t *testing.T) {
+			// given
+			cl, _ := newClient(t)
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "config",
+					Namespace: "toolchain-host-operator",
+				},
+				Data: map[string]string{
+					"first-param":  "first-value",
+					"second-param": "second-value",
+				},
+			}
+			_, err := cl.ApplyObject(context.TODO(), cm.DeepCopy())
+			require.NoError(t, err)
+			delete(cm.Data, "second-param")
+
+			// when
+			result, err := cl.ApplyObjectWithResult(context.TODO(), cm, client.DryRun(true))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyActionUpdate, result.Action)
+			assert.Equal(t, []client.FieldDiff{
+				{Path: "data.second-param", Live: "second-value"},
+			}, result.Diff)
+		})
+
+		t.Run("ServiceAccount secret refs are retained", func(t *testing.T) {
+			// given
+			cl, _ := newClient(t)
+			existingSA := newSA()
+			existingSA.Secrets = []corev1.ObjectReference{{Name: "secret", Namespace: existingSA.Namespace}}
+			_, err := cl.ApplyObject(context.TODO(), existingSA.DeepCopy())
+			require.NoError(t, err)
+			updatedSA := newSA()
+			updatedSA.Labels = map[string]string{"foo": "bar"}
+
+			// when
+			result, err := cl.ApplyObjectWithResult(context.TODO(), updatedSA, client.DryRun(true), client.ForceUpdate(true))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyActionUpdate, result.Action)
+			assert.Equal(t, []client.FieldDiff{
+				{Path: "metadata.labels", Desired: map[string]interface{}{"foo": "bar"}},
+			}, result.Diff)
+		})
+	})
+
+	t.Run("in normal mode", func(t *testing.T) {
+
+		t.Run("it should create and report create", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			obj := newService()
+
+			// when
+			result, err := cl.ApplyObjectWithResult(context.TODO(), obj)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyActionCreate, result.Action)
+			assert.False(t, result.DryRun)
+			require.NoError(t, cli.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(obj), &corev1.Service{}))
+		})
+
+		t.Run("it should update and report update with the diff", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			_, err := cl.ApplyObject(context.TODO(), newService())
+			require.NoError(t, err)
+			modified := newService()
+			modified.Spec.Selector["run"] = "all-services"
+
+			// when
+			result, err := cl.ApplyObjectWithResult(context.TODO(), modified)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyActionUpdate, result.Action)
+			assert.Equal(t, []client.FieldDiff{
+				{Path: "spec.selector.run", Live: "registration-service", Desired: "all-services"},
+			}, result.Diff)
+			inCluster := &corev1.Service{}
+			require.NoError(t, cli.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(modified), inCluster))
+			assert.Equal(t, "all-services", inCluster.Spec.Selector["run"])
+		})
+
+		t.Run("it should report update when the data of a ConfigMap is different", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			newConfigMap := func(value string) *corev1.ConfigMap {
+				return &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "registration-service",
+						Namespace: "toolchain-host-operator",
+					},
+					Data: map[string]string{
+						"environment": value,
+					},
+				}
+			}
+			_, err := cl.ApplyObject(context.TODO(), newConfigMap("prod"), client.SaveConfiguration(false))
+			require.NoError(t, err)
+			// like the API server, don't change the generation of the ConfigMap
+			cli.MockUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+				current := &corev1.ConfigMap{}
+				if err := cli.Client.Get(ctx, runtimeclient.ObjectKeyFromObject(obj), current); err != nil {
+					return err
+				}
+				obj.SetGeneration(current.GetGeneration())
+				return cli.Client.Update(ctx, obj, opts...)
+			}
+
+			// when
+			result, err := cl.ApplyObjectWithResult(context.TODO(), newConfigMap("dev"), client.SaveConfiguration(false))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyActionUpdate, result.Action)
+			assert.Equal(t, []client.FieldDiff{
+				{Path: "data.environment", Live: "prod", Desired: "dev"},
+			}, result.Diff)
+			inCluster := &corev1.ConfigMap{}
+			require.NoError(t, cli.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(newConfigMap("dev")), inCluster))
+			assert.Equal(t, "dev", inCluster.Data["environment"])
+
+			t.Run("and no-op when the data is the same", func(t *testing.T) {
+				// when
+				result, err := cl.ApplyObjectWithResult(context.TODO(), newConfigMap("dev"), client.SaveConfiguration(false))
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, client.ApplyActionNoOp, result.Action)
+				assert.Empty(t, result.Diff)
+			})
+
+			t.Run("and ApplyObject reports only the change of the generation", func(t *testing.T) {
+				// when
+				changed, err := cl.ApplyObject(context.TODO(), newConfigMap("test"), client.SaveConfiguration(false))
+
+				// then
+				require.NoError(t, err)
+				assert.False(t, changed)
+				inCluster := &corev1.ConfigMap{}
+				require.NoError(t, cli.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(newConfigMap("test")), inCluster))
+				assert.Equal(t, "test", inCluster.Data["environment"])
+			})
+		})
+	})
+}
+
+func TestApplyWithResults(t *testing.T) {
+	// given
+	addToScheme(t)
+	cl, cli := newClient(t)
+	existing := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "existing",
+			Namespace: "toolchain-host-operator",
+		},
+		Data: map[string]string{"key": "value"},
+	}
+	_, err := cl.ApplyObject(context.TODO(), existing.DeepCopy())
+	require.NoError(t, err)
+	missing := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "missing",
+			Namespace: "toolchain-host-operator",
+		},
+	}
+
+	// when
+	results, err := cl.ApplyWithResults(context.TODO(), []runtimeclient.Object{existing, missing}, map[string]string{"tier": "base"}, client.DryRun(true))
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, client.ApplyActionUpdate, results[0].Action)
+	assert.Equal(t, []client.FieldDiff{{Path: "metadata.labels", Desired: map[string]interface{}{"tier": "base"}}}, results[0].Diff)
+	assert.Equal(t, client.ApplyActionCreate, results[1].Action)
+	err = cli.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(missing), &corev1.ConfigMap{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
 func toUnstructured(obj runtime.Object) (*unstructured.Unstructured, error) {
 	content, err := json.Marshal(obj)
 	if err != nil {
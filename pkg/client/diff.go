@@ -0,0 +1,179 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FieldDiff describes a single field that differs between the live object in the cluster
+// and the desired object.
+type FieldDiff struct {
+	// Path is the path to the field, eg. `spec.ports[0].port` or `metadata.labels.app`
+	Path string
+	// Live is the value of the field in the cluster (`nil` if the field is not set)
+	Live interface{}
+	// Desired is the value of the field in the desired object (`nil` if the field is going to be removed)
+	Desired interface{}
+}
+
+func (d FieldDiff) String() string {
+	return fmt.Sprintf("%s: %v -> %v", d.Path, d.Live, d.Desired)
+}
+
+// ignoredMetadataFields are the metadata fields that are populated by the server
+// and that should not be part of the diff
+var ignoredMetadataFields = map[string]bool{
+	"creationTimestamp":          true,
+	"deletionGracePeriodSeconds": true,
+	"deletionTimestamp":          true,
+	"generation":                 true,
+	"managedFields":              true,
+	"resourceVersion":            true,
+	"selfLink":                   true,
+	"uid":                        true,
+}
+
+// diffObjects computes the field-level differences between the live and the desired objects.
+//
+// Only the fields that are set in the desired object are compared, so that the values defaulted by the server
+// are not reported as changes. A field that is present only in the live object is reported as removed
+// only when it was part of the last applied configuration stored in the live object (ie, the field was removed from the template).
+// The `status` field, the metadata fields managed by the server and the last-applied-configuration annotation itself
+// are ignored.
+func diffObjects(live, desired client.Object) ([]FieldDiff, error) {
+	var lastApplied map[string]interface{}
+	if config, found := live.GetAnnotations()[LastAppliedConfigurationAnnotationKey]; found {
+		if err := json.Unmarshal([]byte(config), &lastApplied); err != nil {
+			// the annotation is not usable, so the removed fields cannot be detected
+			lastApplied = nil
+		}
+	}
+	liveContent, desiredContent, err := toComparableContents(live, desired)
+	if err != nil {
+		return nil, err
+	}
+	removeIgnoredFields(lastApplied)
+	var diffs []FieldDiff
+	diffMaps("", liveContent, desiredContent, lastApplied, &diffs)
+	return diffs, nil
+}
+
+// diffAllFields computes the field-level differences between two versions of the same object as returned by the server,
+// (eg. before and after a patch). Unlike diffObjects, all the fields are compared, so the fields missing in the second object
+// are always reported as removed. The same fields as in diffObjects are ignored.
+func diffAllFields(before, after client.Object) ([]FieldDiff, error) {
+	beforeContent, afterContent, err := toComparableContents(before, after)
+	if err != nil {
+		return nil, err
+	}
+	var diffs []FieldDiff
+	// using the original content as the "last applied" one makes sure that all the removed fields are detected
+	diffMaps("", beforeContent, afterContent, beforeContent, &diffs)
+	return diffs, nil
+}
+
+func toComparableContents(first, second client.Object) (map[string]interface{}, map[string]interface{}, error) {
+	firstContent, err := toUnstructuredContent(first)
+	if err != nil {
+		return nil, nil, err
+	}
+	secondContent, err := toUnstructuredContent(second)
+	if err != nil {
+		return nil, nil, err
+	}
+	removeIgnoredFields(firstContent)
+	removeIgnoredFields(secondContent)
+	return firstContent, secondContent, nil
+}
+
+func removeIgnoredFields(content map[string]interface{}) {
+	if content == nil {
+		return
+	}
+	delete(content, "apiVersion")
+	delete(content, "kind")
+	delete(content, "status")
+	if metadata, ok := content["metadata"].(map[string]interface{}); ok {
+		for field := range ignoredMetadataFields {
+			delete(metadata, field)
+		}
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			delete(annotations, LastAppliedConfigurationAnnotationKey)
+			if len(annotations) == 0 {
+				delete(metadata, "annotations")
+			}
+		}
+	}
+}
+
+func toUnstructuredContent(obj runtime.Object) (map[string]interface{}, error) {
+	if u, ok := obj.(runtime.Unstructured); ok {
+		return runtime.DeepCopyJSON(u.UnstructuredContent()), nil
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+}
+
+func diffMaps(path string, live, desired, lastApplied map[string]interface{}, diffs *[]FieldDiff) {
+	keys := make([]string, 0, len(desired)+len(lastApplied))
+	for key := range desired {
+		keys = append(keys, key)
+	}
+	for key := range lastApplied {
+		if _, inDesired := desired[key]; !inDesired {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fieldPath := key
+		if path != "" {
+			fieldPath = path + "." + key
+		}
+		liveValue, inLive := live[key]
+		desiredValue, inDesired := desired[key]
+		if !inDesired {
+			// the field was part of the last applied configuration, but it's not there anymore
+			if inLive {
+				*diffs = append(*diffs, FieldDiff{Path: fieldPath, Live: liveValue})
+			}
+			continue
+		}
+		var lastAppliedValue interface{}
+		if lastApplied != nil {
+			lastAppliedValue = lastApplied[key]
+		}
+		diffValues(fieldPath, liveValue, desiredValue, lastAppliedValue, diffs)
+	}
+}
+
+func diffValues(path string, live, desired, lastApplied interface{}, diffs *[]FieldDiff) {
+	switch desired := desired.(type) {
+	case map[string]interface{}:
+		if live, ok := live.(map[string]interface{}); ok {
+			lastApplied, _ := lastApplied.(map[string]interface{})
+			diffMaps(path, live, desired, lastApplied, diffs)
+			return
+		}
+	case []interface{}:
+		if live, ok := live.([]interface{}); ok && len(live) == len(desired) {
+			lastApplied, _ := lastApplied.([]interface{})
+			for i := range desired {
+				var lastAppliedItem interface{}
+				if len(lastApplied) == len(desired) {
+					lastAppliedItem = lastApplied[i]
+				}
+				diffValues(fmt.Sprintf("%s[%d]", path, i), live[i], desired[i], lastAppliedItem, diffs)
+			}
+			return
+		}
+	}
+	if !reflect.DeepEqual(live, desired) {
+		*diffs = append(*diffs, FieldDiff{Path: path, Live: live, Desired: desired})
+	}
+}
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDiffObjects(t *testing.T) {
+	newPod := func() *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pod",
+				Namespace: "default",
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "first", Image: "first:1"},
+					{Name: "second", Image: "second:1"},
+				},
+			},
+		}
+	}
+
+	t.Run("no diff for same objects", func(t *testing.T) {
+		// when
+		diff, err := diffObjects(newPod(), newPod())
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, diff)
+	})
+
+	t.Run("server-managed fields are ignored", func(t *testing.T) {
+		// given
+		live := newPod()
+		live.ResourceVersion = "123"
+		live.UID = "abc"
+		live.Generation = 2
+		live.Status.Phase = corev1.PodRunning
+		live.Annotations = map[string]string{LastAppliedConfigurationAnnotationKey: "{}"}
+		desired := newPod()
+		desired.Annotations = map[string]string{LastAppliedConfigurationAnnotationKey: `{"spec":{}}`}
+
+		// when
+		diff, err := diffObjects(live, desired)
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, diff)
+	})
+
+	t.Run("defaulted fields are ignored", func(t *testing.T) {
+		// given
+		live := newPod()
+		live.Spec.RestartPolicy = corev1.RestartPolicyAlways
+		live.Spec.Containers[0].TerminationMessagePath = "/dev/termination-log"
+
+		// when
+		diff, err := diffObjects(live, newPod())
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, diff)
+	})
+
+	t.Run("changed items of a list", func(t *testing.T) {
+		// given
+		desired := newPod()
+		desired.Spec.Containers[1].Image = "second:2"
+
+		// when
+		diff, err := diffObjects(newPod(), desired)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []FieldDiff{{Path: "spec.containers[1].image", Live: "second:1", Desired: "second:2"}}, diff)
+	})
+
+	t.Run("list with a different length", func(t *testing.T) {
+		// given
+		desired := newPod()
+		desired.Spec.Containers = desired.Spec.Containers[:1]
+
+		// when
+		diff, err := diffObjects(newPod(), desired)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, diff, 1)
+		assert.Equal(t, "spec.containers", diff[0].Path)
+	})
+
+	t.Run("removed field", func(t *testing.T) {
+		// given
+		live := newPod()
+		live.Labels = map[string]string{"app": "pod", "version": "1"}
+		lastApplied := live.DeepCopy()
+		live.Annotations = map[string]string{LastAppliedConfigurationAnnotationKey: GetNewConfiguration(lastApplied)}
+		desired := newPod()
+		desired.Labels = map[string]string{"app": "pod"}
+
+		t.Run("reported when it's in the last applied configuration", func(t *testing.T) {
+			// when
+			diff, err := diffObjects(live, desired)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, []FieldDiff{{Path: "metadata.labels.version", Live: "1"}}, diff)
+		})
+
+		t.Run("not reported when there's no last applied configuration", func(t *testing.T) {
+			// given
+			live := live.DeepCopy()
+			live.Annotations = nil
+
+			// when
+			diff, err := diffObjects(live, desired)
+
+			// then
+			require.NoError(t, err)
+			assert.Empty(t, diff)
+		})
+	})
+
+	t.Run("unstructured and typed objects", func(t *testing.T) {
+		// given
+		desired := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name":      "config",
+				"namespace": "default",
+			},
+			"data": map[string]interface{}{
+				"key": "new-value",
+			},
+		}}
+		live := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "config",
+				Namespace: "default",
+			},
+			Data: map[string]string{"key": "value"},
+		}
+
+		// when
+		diff, err := diffObjects(live, desired)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []FieldDiff{{Path: "data.key", Live: "value", Desired: "new-value"}}, diff)
+	})
+}
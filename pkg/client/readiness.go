@@ -0,0 +1,279 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultReadinessInterval is the default interval between two readiness checks
+	DefaultReadinessInterval = 2 * time.Second
+	// DefaultReadinessTimeout is the default maximum duration of waiting for the objects to become ready
+	DefaultReadinessTimeout = 5 * time.Minute
+)
+
+// ObjectReadiness is the readiness of a single object as of the last check
+type ObjectReadiness struct {
+	GVK       schema.GroupVersionKind
+	Namespace string
+	Name      string
+	// Ready is `true` when the object is ready
+	Ready bool
+	// Failed is `true` when the object reached a state from which it won't become ready (eg. a failed Job)
+	Failed bool
+	// Message describes why the object is not ready (empty when the object is ready)
+	Message string
+}
+
+func (r ObjectReadiness) String() string {
+	name := r.Name
+	if r.Namespace != "" {
+		name = r.Namespace + "/" + r.Name
+	}
+	return fmt.Sprintf("%s %s: %s", r.GVK.Kind, name, r.Message)
+}
+
+// ReadinessReport contains the readiness of all the checked objects, in the same order as the objects were provided
+type ReadinessReport struct {
+	Objects []ObjectReadiness
+}
+
+// AllReady returns `true` if all the objects are ready
+func (r *ReadinessReport) AllReady() bool {
+	return len(r.NotReady()) == 0
+}
+
+// AnyFailed returns `true` if at least one of the objects failed
+func (r *ReadinessReport) AnyFailed() bool {
+	for _, obj := range r.Objects {
+		if obj.Failed {
+			return true
+		}
+	}
+	return false
+}
+
+// NotReady returns the readiness of the objects that are not ready
+func (r *ReadinessReport) NotReady() []ObjectReadiness {
+	var notReady []ObjectReadiness
+	for _, obj := range r.Objects {
+		if !obj.Ready {
+			notReady = append(notReady, obj)
+		}
+	}
+	return notReady
+}
+
+// Message summarizes the objects that are not ready. It returns an empty string when all the objects are ready.
+func (r *ReadinessReport) Message() string {
+	notReady := r.NotReady()
+	messages := make([]string, 0, len(notReady))
+	for _, obj := range notReady {
+		messages = append(messages, obj.String())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ReadinessWaiter waits until the applied objects become ready, eg. until the Deployments are rolled out,
+// the CRDs are established or the Namespaces are active.
+type ReadinessWaiter struct {
+	Client client.Client
+	// Interval between two readiness checks. DefaultReadinessInterval is used when not set.
+	Interval time.Duration
+	// Timeout is the maximum duration of waiting. DefaultReadinessTimeout is used when not set.
+	Timeout time.Duration
+}
+
+// NewReadinessWaiter creates a new ReadinessWaiter with the default interval and timeout
+func NewReadinessWaiter(cl client.Client) *ReadinessWaiter {
+	return &ReadinessWaiter{
+		Client:   cl,
+		Interval: DefaultReadinessInterval,
+		Timeout:  DefaultReadinessTimeout,
+	}
+}
+
+// Wait checks the readiness of the given objects repeatedly until all of them are ready, any of them fails,
+// the timeout is reached or the context is cancelled. The report from the last successful check is always returned,
+// it's nil if none of the checks succeeded (eg. when the very first check failed).
+// The error is returned when the objects didn't become ready.
+func (w *ReadinessWaiter) Wait(ctx context.Context, objs []client.Object) (*ReadinessReport, error) {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultReadinessInterval
+	}
+	timeout := w.Timeout
+	if timeout <= 0 {
+		timeout = DefaultReadinessTimeout
+	}
+	var report *ReadinessReport
+	err := wait.PollUntilContextTimeout(ctx, interval, timeout, true, func(ctx context.Context) (bool, error) {
+		checked, err := CheckReadiness(ctx, w.Client, objs)
+		if err != nil {
+			return false, err
+		}
+		report = checked
+		if report.AnyFailed() {
+			return false, fmt.Errorf("some of the objects failed: %s", report.Message())
+		}
+		return report.AllReady(), nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("the objects are not ready: %w", err)
+	}
+	return report, nil
+}
+
+// CheckReadiness fetches the given objects from the cluster and evaluates their readiness once.
+func CheckReadiness(ctx context.Context, cl client.Client, objs []client.Object) (*ReadinessReport, error) {
+	report := &ReadinessReport{
+		Objects: make([]ObjectReadiness, 0, len(objs)),
+	}
+	for _, obj := range objs {
+		if err := EnsureGVK(obj, cl.Scheme()); err != nil {
+			return nil, fmt.Errorf("unable to determine the GVK of the object '%s': %w", obj.GetName(), err)
+		}
+		readiness := ObjectReadiness{
+			GVK:       obj.GetObjectKind().GroupVersionKind(),
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+		}
+		current := &unstructured.Unstructured{}
+		current.SetGroupVersionKind(readiness.GVK)
+		if err := cl.Get(ctx, client.ObjectKeyFromObject(obj), current); err != nil {
+			if !apierrors.IsNotFound(err) {
+				// could be a temporary problem, let's try it again during the next check
+				readiness.Message = fmt.Sprintf("unable to get the object: %s", err.Error())
+			} else {
+				readiness.Message = "not found"
+			}
+		} else {
+			readiness.Ready, readiness.Failed, readiness.Message = evaluateReadiness(current)
+		}
+		report.Objects = append(report.Objects, readiness)
+	}
+	return report, nil
+}
+
+func evaluateReadiness(obj *unstructured.Unstructured) (bool, bool, string) {
+	if obj.GetDeletionTimestamp() != nil {
+		return false, false, "being deleted"
+	}
+	if observed, found, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration"); found && observed < obj.GetGeneration() {
+		return false, false, fmt.Sprintf("the latest generation %d not observed yet (observed: %d)", obj.GetGeneration(), observed)
+	}
+
+	gk := obj.GroupVersionKind().GroupKind()
+	switch gk {
+	case schema.GroupKind{Group: "apps", Kind: "Deployment"}:
+		return deploymentReadiness(obj)
+	case schema.GroupKind{Group: "apps", Kind: "StatefulSet"}:
+		return statefulSetReadiness(obj)
+	case schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}:
+		return conditionReadiness(obj, "Established")
+	case schema.GroupKind{Group: "", Kind: "Namespace"}:
+		if phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase"); phase != "Active" {
+			return false, false, fmt.Sprintf("the namespace is not active (phase: '%s')", phase)
+		}
+		return true, false, ""
+	case schema.GroupKind{Group: "batch", Kind: "Job"}:
+		return jobReadiness(obj)
+	}
+
+	// generic objects are ready when their Ready (or Available) condition is true, or when they don't have any of these conditions
+	for _, conditionType := range []string{"Ready", "Available"} {
+		if _, found := findCondition(obj, conditionType); found {
+			return conditionReadiness(obj, conditionType)
+		}
+	}
+	return true, false, ""
+}
+
+func deploymentReadiness(obj *unstructured.Unstructured) (bool, bool, string) {
+	replicas := desiredReplicas(obj)
+	updated, _, _ := unstructured.NestedInt64(obj.Object, "status", "updatedReplicas")
+	available, _, _ := unstructured.NestedInt64(obj.Object, "status", "availableReplicas")
+	total, _, _ := unstructured.NestedInt64(obj.Object, "status", "replicas")
+	if condition, found := findCondition(obj, "Progressing"); found && condition["reason"] == "ProgressDeadlineExceeded" {
+		return false, true, fmt.Sprintf("the rollout exceeded its progress deadline: %v", condition["message"])
+	}
+	if updated < replicas {
+		return false, false, fmt.Sprintf("%d of %d replicas updated", updated, replicas)
+	}
+	if total > updated {
+		return false, false, fmt.Sprintf("%d old replicas pending termination", total-updated)
+	}
+	if available < replicas {
+		return false, false, fmt.Sprintf("%d of %d replicas available", available, replicas)
+	}
+	return true, false, ""
+}
+
+func statefulSetReadiness(obj *unstructured.Unstructured) (bool, bool, string) {
+	replicas := desiredReplicas(obj)
+	ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+	updated, _, _ := unstructured.NestedInt64(obj.Object, "status", "updatedReplicas")
+	strategy, _, _ := unstructured.NestedString(obj.Object, "spec", "updateStrategy", "type")
+	if strategy != "OnDelete" {
+		if updated < replicas {
+			return false, false, fmt.Sprintf("%d of %d replicas updated", updated, replicas)
+		}
+		currentRevision, _, _ := unstructured.NestedString(obj.Object, "status", "currentRevision")
+		updateRevision, _, _ := unstructured.NestedString(obj.Object, "status", "updateRevision")
+		if updateRevision != "" && currentRevision != updateRevision {
+			return false, false, fmt.Sprintf("the revision %s not rolled out yet", updateRevision)
+		}
+	}
+	if ready < replicas {
+		return false, false, fmt.Sprintf("%d of %d replicas ready", ready, replicas)
+	}
+	return true, false, ""
+}
+
+func jobReadiness(obj *unstructured.Unstructured) (bool, bool, string) {
+	if condition, found := findCondition(obj, "Failed"); found && condition["status"] == "True" {
+		return false, true, fmt.Sprintf("the job failed: %v", condition["message"])
+	}
+	if condition, found := findCondition(obj, "Complete"); found && condition["status"] == "True" {
+		return true, false, ""
+	}
+	return false, false, "the job is not complete"
+}
+
+func conditionReadiness(obj *unstructured.Unstructured, conditionType string) (bool, bool, string) {
+	condition, found := findCondition(obj, conditionType)
+	if !found {
+		return false, false, fmt.Sprintf("the %s condition not found", conditionType)
+	}
+	if condition["status"] != "True" {
+		return false, false, fmt.Sprintf("the %s condition is not true (reason: '%v', message: '%v')", conditionType, condition["reason"], condition["message"])
+	}
+	return true, false, ""
+}
+
+func desiredReplicas(obj *unstructured.Unstructured) int64 {
+	replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if !found {
+		// the default number of replicas
+		return 1
+	}
+	return replicas
+}
+
+func findCondition(obj *unstructured.Unstructured, conditionType string) (map[string]interface{}, bool) {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		if condition, ok := c.(map[string]interface{}); ok && condition["type"] == conditionType {
+			return condition, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,380 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/ptr"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestCheckReadiness(t *testing.T) {
+	newDeployment := func(replicas int32, status appsv1.DeploymentStatus) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "operator", Namespace: "toolchain", Generation: 2},
+			Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(replicas)},
+			Status:     status,
+		}
+	}
+	newStatefulSet := func(status appsv1.StatefulSetStatus) *appsv1.StatefulSet {
+		return &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "toolchain", Generation: 1},
+			Spec:       appsv1.StatefulSetSpec{Replicas: ptr.To[int32](2)},
+			Status:     status,
+		}
+	}
+	newJob := func(conditions ...batchv1.JobCondition) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "migration", Namespace: "toolchain"},
+			Status:     batchv1.JobStatus{Conditions: conditions},
+		}
+	}
+	newCRD := func(established string) *unstructured.Unstructured {
+		crd := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apiextensions.k8s.io/v1",
+			"kind":       "CustomResourceDefinition",
+			"metadata": map[string]interface{}{
+				"name": "spaces.toolchain.dev.openshift.com",
+			},
+		}}
+		if established != "" {
+			crd.Object["status"] = map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Established", "status": established, "reason": "InitialNamesAccepted"},
+				},
+			}
+		}
+		return crd
+	}
+
+	for name, tc := range map[string]struct {
+		obj             runtimeclient.Object
+		expectedReady   bool
+		expectedFailed  bool
+		expectedMessage string
+	}{
+		"deployment rolled out": {
+			obj:           newDeployment(2, appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}),
+			expectedReady: true,
+		},
+		"deployment with generation not observed yet": {
+			obj:             newDeployment(2, appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}),
+			expectedMessage: "the latest generation 2 not observed yet (observed: 1)",
+		},
+		"deployment with replicas not updated": {
+			obj:             newDeployment(2, appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 1, AvailableReplicas: 2}),
+			expectedMessage: "1 of 2 replicas updated",
+		},
+		"deployment with old replicas": {
+			obj:             newDeployment(2, appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 2, AvailableReplicas: 2}),
+			expectedMessage: "1 old replicas pending termination",
+		},
+		"deployment with replicas not available": {
+			obj:             newDeployment(2, appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 1}),
+			expectedMessage: "1 of 2 replicas available",
+		},
+		"deployment exceeding progress deadline": {
+			obj: newDeployment(2, appsv1.DeploymentStatus{ObservedGeneration: 2, Conditions: []appsv1.DeploymentCondition{
+				{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: "ProgressDeadlineExceeded", Message: "timed out"},
+			}}),
+			expectedFailed:  true,
+			expectedMessage: "the rollout exceeded its progress deadline: timed out",
+		},
+		"statefulset rolled out": {
+			obj:           newStatefulSet(appsv1.StatefulSetStatus{ObservedGeneration: 1, ReadyReplicas: 2, UpdatedReplicas: 2, CurrentRevision: "db-1", UpdateRevision: "db-1"}),
+			expectedReady: true,
+		},
+		"statefulset with revision not rolled out": {
+			obj:             newStatefulSet(appsv1.StatefulSetStatus{ObservedGeneration: 1, ReadyReplicas: 2, UpdatedReplicas: 2, CurrentRevision: "db-1", UpdateRevision: "db-2"}),
+			expectedMessage: "the revision db-2 not rolled out yet",
+		},
+		"statefulset with replicas not ready": {
+			obj:             newStatefulSet(appsv1.StatefulSetStatus{ObservedGeneration: 1, ReadyReplicas: 1, UpdatedReplicas: 2}),
+			expectedMessage: "1 of 2 replicas ready",
+		},
+		"active namespace": {
+			obj:           &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "toolchain"}, Status: corev1.NamespaceStatus{Phase: corev1.NamespaceActive}},
+			expectedReady: true,
+		},
+		"terminating namespace": {
+			obj:             &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "toolchain"}, Status: corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating}},
+			expectedMessage: "the namespace is not active (phase: 'Terminating')",
+		},
+		"completed job": {
+			obj:           newJob(batchv1.JobCondition{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}),
+			expectedReady: true,
+		},
+		"running job": {
+			obj:             newJob(),
+			expectedMessage: "the job is not complete",
+		},
+		"failed job": {
+			obj:             newJob(batchv1.JobCondition{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "backoff limit exceeded"}),
+			expectedFailed:  true,
+			expectedMessage: "the job failed: backoff limit exceeded",
+		},
+		"established crd": {
+			obj:           newCRD("True"),
+			expectedReady: true,
+		},
+		"crd not established": {
+			obj:             newCRD("False"),
+			expectedMessage: "the Established condition is not true (reason: 'InitialNamesAccepted', message: '<nil>')",
+		},
+		"crd without conditions": {
+			obj:             newCRD(""),
+			expectedMessage: "the Established condition not found",
+		},
+		"generic object with ready condition": {
+			obj: &toolchainv1alpha1.Space{
+				ObjectMeta: metav1.ObjectMeta{Name: "john", Namespace: test.HostOperatorNs},
+				Status: toolchainv1alpha1.SpaceStatus{Conditions: []toolchainv1alpha1.Condition{
+					{Type: toolchainv1alpha1.ConditionReady, Status: corev1.ConditionTrue},
+				}},
+			},
+			expectedReady: true,
+		},
+		"generic object with condition not ready": {
+			obj: &toolchainv1alpha1.Space{
+				ObjectMeta: metav1.ObjectMeta{Name: "john", Namespace: test.HostOperatorNs},
+				Status: toolchainv1alpha1.SpaceStatus{Conditions: []toolchainv1alpha1.Condition{
+					{Type: toolchainv1alpha1.ConditionReady, Status: corev1.ConditionFalse, Reason: "Provisioning", Message: "provisioning"},
+				}},
+			},
+			expectedMessage: "the Ready condition is not true (reason: 'Provisioning', message: 'provisioning')",
+		},
+		"generic object without conditions": {
+			obj:           &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "toolchain"}},
+			expectedReady: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// given
+			cl := test.NewFakeClient(t, tc.obj)
+
+			// when
+			report, err := client.CheckReadiness(context.TODO(), cl, []runtimeclient.Object{tc.obj})
+
+			// then
+			require.NoError(t, err)
+			require.Len(t, report.Objects, 1)
+			readiness := report.Objects[0]
+			assert.Equal(t, tc.obj.GetName(), readiness.Name)
+			assert.Equal(t, tc.obj.GetNamespace(), readiness.Namespace)
+			assert.Equal(t, tc.expectedReady, readiness.Ready)
+			assert.Equal(t, tc.expectedFailed, readiness.Failed)
+			assert.Equal(t, tc.expectedMessage, readiness.Message)
+			assert.Equal(t, tc.expectedReady, report.AllReady())
+		})
+	}
+
+	t.Run("missing object is not ready", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t)
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "toolchain"}}
+
+		// when
+		report, err := client.CheckReadiness(context.TODO(), cl, []runtimeclient.Object{cm})
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, report.Objects, 1)
+		assert.False(t, report.Objects[0].Ready)
+		assert.Equal(t, "ConfigMap", report.Objects[0].GVK.Kind)
+		assert.Equal(t, "ConfigMap toolchain/config: not found", report.Message())
+	})
+
+	t.Run("failure to get the object is not ready", func(t *testing.T) {
+		// given
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "toolchain"}}
+		cl := test.NewFakeClient(t, cm)
+		cl.MockGet = func(_ context.Context, _ runtimeclient.ObjectKey, _ runtimeclient.Object, _ ...runtimeclient.GetOption) error {
+			return errors.New("some error")
+		}
+
+		// when
+		report, err := client.CheckReadiness(context.TODO(), cl, []runtimeclient.Object{cm})
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "ConfigMap toolchain/config: unable to get the object: some error", report.Message())
+	})
+}
+
+func TestReadinessWaiter(t *testing.T) {
+	newNamespace := func(phase corev1.NamespacePhase) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "toolchain"}, Status: corev1.NamespaceStatus{Phase: phase}}
+	}
+	newWaiter := func(cl runtimeclient.Client) *client.ReadinessWaiter {
+		waiter := client.NewReadinessWaiter(cl)
+		waiter.Interval = 10 * time.Millisecond
+		waiter.Timeout = time.Second
+		return waiter
+	}
+
+	t.Run("returns when all objects are ready", func(t *testing.T) {
+		// given
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "toolchain"}}
+		cl := test.NewFakeClient(t, newNamespace(corev1.NamespaceActive), cm)
+
+		// when
+		report, err := newWaiter(cl).Wait(context.TODO(), []runtimeclient.Object{newNamespace(""), cm})
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, report.AllReady())
+		assert.Len(t, report.Objects, 2)
+	})
+
+	t.Run("waits until the object becomes ready", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, newNamespace(""))
+		checks := 0
+		cl.MockGet = func(ctx context.Context, key runtimeclient.ObjectKey, obj runtimeclient.Object, opts ...runtimeclient.GetOption) error {
+			checks++
+			if err := cl.Client.Get(ctx, key, obj, opts...); err != nil {
+				return err
+			}
+			if checks == 3 {
+				// the namespace becomes active during the third check
+				return unstructured.SetNestedField(obj.(*unstructured.Unstructured).Object, string(corev1.NamespaceActive), "status", "phase")
+			}
+			return nil
+		}
+
+		// when
+		report, err := newWaiter(cl).Wait(context.TODO(), []runtimeclient.Object{newNamespace("")})
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, report.AllReady())
+		assert.Equal(t, 3, checks)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, newNamespace(corev1.NamespaceTerminating))
+		waiter := newWaiter(cl)
+		waiter.Timeout = 50 * time.Millisecond
+
+		// when
+		report, err := waiter.Wait(context.TODO(), []runtimeclient.Object{newNamespace("")})
+
+		// then
+		require.ErrorContains(t, err, "the objects are not ready")
+		require.NotNil(t, report)
+		assert.Equal(t, "Namespace toolchain: the namespace is not active (phase: 'Terminating')", report.Message())
+	})
+
+	t.Run("keeps the last report when a check fails", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, newNamespace(corev1.NamespaceTerminating))
+		cm := &unstructured.Unstructured{}
+		cm.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+		cm.SetName("config")
+		cm.SetNamespace("toolchain")
+		gets := 0
+		cl.MockGet = func(ctx context.Context, key runtimeclient.ObjectKey, obj runtimeclient.Object, opts ...runtimeclient.GetOption) error {
+			gets++
+			if gets == 3 {
+				// the GVK of the ConfigMap can't be determined during the second check
+				cm.SetGroupVersionKind(schema.GroupVersionKind{})
+			}
+			return cl.Client.Get(ctx, key, obj, opts...)
+		}
+
+		// when
+		report, err := newWaiter(cl).Wait(context.TODO(), []runtimeclient.Object{newNamespace(""), cm})
+
+		// then
+		require.ErrorContains(t, err, "unable to determine the GVK of the object 'config'")
+		require.NotNil(t, report)
+		require.Len(t, report.Objects, 2)
+		assert.Equal(t, "Namespace toolchain: the namespace is not active (phase: 'Terminating'); ConfigMap toolchain/config: not found", report.Message())
+	})
+
+	t.Run("no report when the first check fails", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t)
+		unknown := &unstructured.Unstructured{}
+		unknown.SetName("unknown")
+
+		// when
+		report, err := newWaiter(cl).Wait(context.TODO(), []runtimeclient.Object{unknown})
+
+		// then
+		require.ErrorContains(t, err, "unable to determine the GVK of the object 'unknown'")
+		assert.Nil(t, report)
+	})
+
+	t.Run("defaults the interval and timeout", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, newNamespace(""))
+		checks := 0
+		cl.MockGet = func(ctx context.Context, key runtimeclient.ObjectKey, obj runtimeclient.Object, opts ...runtimeclient.GetOption) error {
+			checks++
+			if err := cl.Client.Get(ctx, key, obj, opts...); err != nil {
+				return err
+			}
+			if checks == 2 {
+				return unstructured.SetNestedField(obj.(*unstructured.Unstructured).Object, string(corev1.NamespaceActive), "status", "phase")
+			}
+			return nil
+		}
+		waiter := &client.ReadinessWaiter{Client: cl}
+
+		// when
+		report, err := waiter.Wait(context.TODO(), []runtimeclient.Object{newNamespace("")})
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, report.AllReady())
+		assert.Equal(t, 2, checks)
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t)
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+
+		// when
+		_, err := newWaiter(cl).Wait(ctx, []runtimeclient.Object{newNamespace("")})
+
+		// then
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("stops when an object failed", func(t *testing.T) {
+		// given
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "migration", Namespace: "toolchain"},
+			Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "backoff limit exceeded"},
+			}},
+		}
+		cl := test.NewFakeClient(t, job)
+		waiter := newWaiter(cl)
+		waiter.Timeout = time.Hour
+
+		// when
+		report, err := waiter.Wait(context.TODO(), []runtimeclient.Object{job})
+
+		// then
+		require.EqualError(t, err, "the objects are not ready: some of the objects failed: Job toolchain/migration: the job failed: backoff limit exceeded")
+		assert.True(t, report.AnyFailed())
+	})
+}
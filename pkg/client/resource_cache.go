@@ -2,33 +2,97 @@ package client
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
 )
 
+const (
+	// DefaultResourceCacheTTL is the default duration after which the cached API resources are refreshed
+	DefaultResourceCacheTTL = 10 * time.Minute
+	// DefaultResourceCacheMinRefreshInterval is the default minimal duration between two refreshes of the cached API resources
+	// triggered by a lookup of an unknown kind or resource
+	DefaultResourceCacheMinRefreshInterval = 30 * time.Second
+)
+
 type ResourceCache struct {
-	mutex           sync.Mutex                // guard the initialization ofthe resourceLists
-	resourceLists   []*metav1.APIResourceList // All available API in the cluster
+	mutex           sync.Mutex           // guard the initialization ofthe resources
+	resources       *discoveredResources // All available API in the cluster
 	discoveryClient discovery.ServerResourcesInterface
+
+	ttl                time.Duration
+	minRefreshInterval time.Duration
+	keepPartialResults bool
+
+	loadedAt    time.Time // when the resources were loaded
+	lastRefresh time.Time // when the discovery was called for the last time (regardless of the result)
+	now         func() time.Time
+}
+
+// ResourceCacheOption configures the ResourceCache
+type ResourceCacheOption func(rc *ResourceCache)
+
+// ResourceCacheTTL sets the duration after which the cached API resources are considered stale and are loaded again.
+// Zero disables the expiration.
+func ResourceCacheTTL(ttl time.Duration) ResourceCacheOption {
+	return func(rc *ResourceCache) {
+		rc.ttl = ttl
+	}
+}
+
+// ResourceCacheMinRefreshInterval sets the minimal duration between two calls of the discovery API. When a looked up kind or resource
+// is not in the cache (eg. the CRD was installed after the cache was loaded), then the cache is refreshed only if
+// the previous refresh happened before this interval. Zero means that every miss triggers a refresh.
+func ResourceCacheMinRefreshInterval(interval time.Duration) ResourceCacheOption {
+	return func(rc *ResourceCache) {
+		rc.minRefreshInterval = interval
+	}
+}
+
+// KeepPartialDiscoveryResults makes the ResourceCache keep the API resources of the groups that were successfully discovered
+// when the discovery of some other groups failed. The failed groups are looked up again during the next refresh.
+// By default, the partial results are thrown away and the error is returned.
+func KeepPartialDiscoveryResults(keep bool) ResourceCacheOption {
+	return func(rc *ResourceCache) {
+		rc.keepPartialResults = keep
+	}
 }
 
 // NewResourceCache creates a new ResourceCache  with the provided discovery client.
 // The discovery client is used to fetch available API resources.
-func NewResourceCache(discoveryClient discovery.ServerResourcesInterface) *ResourceCache {
-	return &ResourceCache{
-		discoveryClient: discoveryClient,
+// The cached resources are refreshed after DefaultResourceCacheTTL and when a lookup doesn't find the kind or resource,
+// but at most once per DefaultResourceCacheMinRefreshInterval. Use the options to change it.
+func NewResourceCache(discoveryClient discovery.ServerResourcesInterface, options ...ResourceCacheOption) *ResourceCache {
+	rc := &ResourceCache{
+		discoveryClient:    discoveryClient,
+		ttl:                DefaultResourceCacheTTL,
+		minRefreshInterval: DefaultResourceCacheMinRefreshInterval,
+		now:                time.Now,
+	}
+	for _, apply := range options {
+		apply(rc)
 	}
+	return rc
+}
+
+// Invalidate drops the cached API resources, so they are loaded again during the next lookup.
+func (rc *ResourceCache) Invalidate() {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	rc.resources = nil
+	rc.loadedAt = time.Time{}
+	rc.lastRefresh = time.Time{}
 }
 
 // GVRForKind returns a group-resource-version for the supplied kind and api version.
 func (rc *ResourceCache) GVRForKind(kind, apiVersion string) (gvr schema.GroupVersionResource, found bool, namespaced bool, err error) {
-	if err = rc.ensureResourceList(); err != nil {
-		return
-	}
-
 	// Parse the group and version from the APIVersion (e.g., "apps/v1" -> group: "apps", version: "v1")
 	var gv schema.GroupVersion
 	gv, err = schema.ParseGroupVersion(apiVersion)
@@ -37,74 +101,262 @@ func (rc *ResourceCache) GVRForKind(kind, apiVersion string) (gvr schema.GroupVe
 		return
 	}
 
-	// Look for a matching resource
-	for _, resourceList := range rc.resourceLists {
-		if resourceList.GroupVersion == apiVersion {
-			for _, apiResource := range resourceList.APIResources {
-				if apiResource.Kind == kind {
-					// Construct the GVR
-					found = true
-					gvr = schema.GroupVersionResource{
-						Group:    gv.Group,
-						Version:  gv.Version,
-						Resource: apiResource.Name,
+	err = rc.lookup(func(resources *discoveredResources) (bool, error) {
+		// Look for a matching resource
+		for _, resourceList := range resources.lists {
+			if resourceList.GroupVersion == apiVersion {
+				for _, apiResource := range resourceList.APIResources {
+					if apiResource.Kind == kind && !isSubresource(apiResource.Name) {
+						// Construct the GVR
+						found = true
+						gvr = schema.GroupVersionResource{
+							Group:    gv.Group,
+							Version:  gv.Version,
+							Resource: apiResource.Name,
+						}
+						namespaced = apiResource.Namespaced
+						return true, nil
 					}
-					namespaced = apiResource.Namespaced
-
-					return
 				}
 			}
 		}
-	}
-
+		return false, nil
+	})
 	return
 }
 
 // GVKForGR given the group-resource, returns the first matching GVK for it.
 func (rc *ResourceCache) GVKForGR(gr schema.GroupResource) (gvk schema.GroupVersionKind, found bool, err error) {
-	if err = rc.ensureResourceList(); err != nil {
+	err = rc.lookup(func(resources *discoveredResources) (bool, error) {
+		for _, resourceList := range resources.lists {
+			gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+			if err != nil {
+				return false, fmt.Errorf("failed to parse GroupVersion %s: %w", resourceList.GroupVersion, err)
+			}
+			if gv.Group != gr.Group {
+				continue
+			}
+			for _, res := range resourceList.APIResources {
+				if res.Name == gr.Resource {
+					gvk = schema.GroupVersionKind{Group: gv.Group, Version: gv.Version, Kind: res.Kind}
+					found = true
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	})
+	return
+}
+
+// APIResourceInfo describes an API resource available in the cluster
+type APIResourceInfo struct {
+	GVR          schema.GroupVersionResource
+	GVK          schema.GroupVersionKind
+	Namespaced   bool
+	SingularName string
+	ShortNames   []string
+	// Verbs supported by the resource, eg. `get`, `list`, `patch`
+	Verbs []string
+	// Subresources of the resource, eg. `status` or `scale`. They are set only by ResourceInfoForGVK.
+	Subresources []string
+}
+
+// SupportsVerb returns true if the resource supports the given verb (eg. `patch`)
+func (i APIResourceInfo) SupportsVerb(verb string) bool {
+	return slices.Contains(i.Verbs, verb)
+}
+
+// HasSubresource returns true if the resource has the given subresource (eg. `status`)
+func (i APIResourceInfo) HasSubresource(subresource string) bool {
+	return slices.Contains(i.Subresources, subresource)
+}
+
+// ResourceInfoForName returns the resource matching the given name. The name can be the plural name (eg. `deployments`),
+// the singular name (eg. `deployment`) or any of the short names (eg. `deploy`) of the resource, optionally qualified
+// by the group the same way as in kubectl (eg. `deploy.apps`). The names are case-insensitive.
+func (rc *ResourceCache) ResourceInfoForName(name string) (info APIResourceInfo, found bool, err error) {
+	requested := schema.ParseGroupResource(strings.ToLower(name))
+	err = rc.lookup(func(resources *discoveredResources) (bool, error) {
+		info, found, err = resources.find(func(gv schema.GroupVersion, res metav1.APIResource) bool {
+			if name == "" || (requested.Group != "" && gv.Group != requested.Group) {
+				return false
+			}
+			return res.Name == requested.Resource || strings.ToLower(singularName(res)) == requested.Resource || slices.Contains(res.ShortNames, requested.Resource)
+		})
+		return found, err
+	})
+	return
+}
+
+// PreferredGVKForGroupKind returns the group-version-kind of the given group-kind in the version preferred by the cluster.
+func (rc *ResourceCache) PreferredGVKForGroupKind(gk schema.GroupKind) (gvk schema.GroupVersionKind, found bool, err error) {
+	err = rc.lookup(func(resources *discoveredResources) (bool, error) {
+		var info APIResourceInfo
+		info, found, err = resources.find(func(gv schema.GroupVersion, res metav1.APIResource) bool {
+			return gv.Group == gk.Group && res.Kind == gk.Kind
+		})
+		gvk = info.GVK
+		return found, err
+	})
+	return
+}
+
+// ResourceInfoForGVK returns the resource of the given group-version-kind including the list of its subresources.
+// The subresources are not part of the preferred resources, so they are fetched (and cached) per group-version on the first use.
+func (rc *ResourceCache) ResourceInfoForGVK(gvk schema.GroupVersionKind) (info APIResourceInfo, found bool, err error) {
+	var resources *discoveredResources
+	err = rc.lookup(func(current *discoveredResources) (bool, error) {
+		resources = current
+		info, found, err = current.find(func(gv schema.GroupVersion, res metav1.APIResource) bool {
+			return gv == gvk.GroupVersion() && res.Kind == gvk.Kind
+		})
+		return found, err
+	})
+	if err != nil || !found {
 		return
 	}
+	info.Subresources, err = rc.subresourcesFor(resources, info.GVR)
+	return
+}
 
-	for _, resourceList := range rc.resourceLists {
-		var gv schema.GroupVersion
-		gv, err = schema.ParseGroupVersion(resourceList.GroupVersion)
-		if err != nil {
-			err = fmt.Errorf("failed to parse GroupVersion %s: %w", resourceList.GroupVersion, err)
-			return
-		}
-		if gv.Group != gr.Group {
-			continue
-		}
+func (rc *ResourceCache) subresourcesFor(resources *discoveredResources, gvr schema.GroupVersionResource) ([]string, error) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	gv := gvr.GroupVersion()
+	if subresources, ok := resources.subresources[gv]; ok {
+		return subresources[gvr.Resource], nil
+	}
+	resourceList, err := rc.discoveryClient.ServerResourcesForGroupVersion(gv.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the resources of %s: %w", gv, err)
+	}
+	subresources := map[string][]string{}
+	if resourceList != nil {
 		for _, res := range resourceList.APIResources {
-			if res.Name == gr.Resource {
-				gvk = schema.GroupVersionKind{Group: gv.Group, Version: gv.Version, Kind: res.Kind}
-				found = true
-				return
+			if resource, subresource, ok := strings.Cut(res.Name, "/"); ok {
+				subresources[resource] = append(subresources[resource], subresource)
 			}
 		}
 	}
+	if resources.subresources == nil {
+		resources.subresources = map[schema.GroupVersion]map[string][]string{}
+	}
+	resources.subresources[gv] = subresources
+	return subresources[gvr.Resource], nil
+}
 
-	return
+// lookup calls the find function with the cached API resources. If nothing is found, then the cache is refreshed
+// (if allowed by the minimal refresh interval) and the find function is called again with the new API resources.
+func (rc *ResourceCache) lookup(find func(resources *discoveredResources) (bool, error)) error {
+	resources, _, err := rc.ensureResourceList(false)
+	if err != nil {
+		return err
+	}
+	if found, err := find(resources); found || err != nil {
+		return err
+	}
+
+	// the kind or resource might have been registered after the cache was loaded
+	resources, refreshed, err := rc.ensureResourceList(true)
+	if err != nil || !refreshed {
+		return err
+	}
+	_, err = find(resources)
+	return err
 }
 
-func (rc *ResourceCache) ensureResourceList() error {
+// ensureResourceList returns the cached API resources. They are (re)loaded when not loaded yet, when the TTL has expired
+// or when the refreshOnMiss is true. Apart from the initial load, the refreshes are limited by the minimal refresh interval.
+// The returned bool is true if the discovery was called.
+func (rc *ResourceCache) ensureResourceList(refreshOnMiss bool) (*discoveredResources, bool, error) {
 	rc.mutex.Lock()
 	defer rc.mutex.Unlock()
 
-	if rc.resourceLists == nil {
-		// Get all API resources from the cluster using the discovery client. We need it for constructing GVRs for unstructured objects.
-		// Do it here once, so we do not have to list it multiple times before listing/getting every unstructured resource.
-		//
-		// The ServerPreferredResources() method is meant to return partial results on failure.
-		// We ignore them here for the sake of simplicity. Let's just retry to get the full results the next time.
-		resourceList, err := rc.discoveryClient.ServerPreferredResources()
-		if err != nil {
-			return err
+	now := rc.now()
+	if rc.resources != nil {
+		expired := rc.ttl > 0 && now.Sub(rc.loadedAt) >= rc.ttl
+		if (!expired && !refreshOnMiss) || now.Sub(rc.lastRefresh) < rc.minRefreshInterval {
+			return rc.resources, false, nil
 		}
+	}
+
+	// Get all API resources from the cluster using the discovery client. We need it for constructing GVRs for unstructured objects.
+	// Do it here, so we do not have to list it multiple times before listing/getting every unstructured resource.
+	//
+	// The ServerPreferredResources() method is meant to return partial results on failure. These are kept only if configured so.
+	// Otherwise, let's just retry to get the full results the next time.
+	rc.lastRefresh = now
+	resourceLists, err := rc.discoveryClient.ServerPreferredResources()
+	if err != nil {
+		switch {
+		case rc.keepPartialResults && discovery.IsGroupDiscoveryFailedError(err) && len(resourceLists) > 0:
+			log.Info("some of the API groups could not be discovered, using the partial results", "error", err.Error())
+		case rc.resources != nil:
+			// it's better to use the previously loaded resources than to fail
+			log.Info("unable to refresh the API resources, using the previously loaded ones", "error", err.Error())
+			return rc.resources, false, nil
+		default:
+			return nil, false, err
+		}
+	}
+
+	if resourceLists == nil {
+		// nothing was discovered, let's try it again the next time
+		return &discoveredResources{}, true, nil
+	}
+	rc.resources = newDiscoveredResources(resourceLists)
+	rc.loadedAt = now
+	return rc.resources, true, nil
+}
+
+// discoveredResources contains the API resources loaded by one call of the discovery API
+type discoveredResources struct {
+	lists []*metav1.APIResourceList
+	// mapper of all the served versions of the resources, loaded lazily by the RESTMapper (guarded by the mutex of the ResourceCache)
+	mapper meta.RESTMapper
+	// subresources per group-version and resource, loaded lazily (guarded by the mutex of the ResourceCache)
+	subresources map[schema.GroupVersion]map[string][]string
+}
+
+func newDiscoveredResources(resourceLists []*metav1.APIResourceList) *discoveredResources {
+	return &discoveredResources{
+		lists: resourceLists,
+	}
+}
 
-		rc.resourceLists = resourceList
+// find returns the info of the first resource matching the given function
+func (r *discoveredResources) find(matches func(gv schema.GroupVersion, res metav1.APIResource) bool) (APIResourceInfo, bool, error) {
+	for _, resourceList := range r.lists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			return APIResourceInfo{}, false, fmt.Errorf("failed to parse GroupVersion %s: %w", resourceList.GroupVersion, err)
+		}
+		for _, res := range resourceList.APIResources {
+			if isSubresource(res.Name) || !matches(gv, res) {
+				continue
+			}
+			return APIResourceInfo{
+				GVR:          gv.WithResource(res.Name),
+				GVK:          gv.WithKind(res.Kind),
+				Namespaced:   res.Namespaced,
+				SingularName: singularName(res),
+				ShortNames:   res.ShortNames,
+				Verbs:        res.Verbs,
+			}, true, nil
+		}
 	}
+	return APIResourceInfo{}, false, nil
+}
 
-	return nil
+func isSubresource(name string) bool {
+	return strings.Contains(name, "/")
+}
+
+func singularName(res metav1.APIResource) string {
+	if res.SingularName != "" {
+		return res.SingularName
+	}
+	return strings.ToLower(res.Kind)
 }
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"fmt"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/restmapper"
+)
+
+// RESTMapper returns a meta.RESTMapper backed by the ResourceCache, so it can be passed to controller-runtime clients
+// and dynamic clients instead of a separate discovery-backed mapper. The mapper benefits from the refresh of the cache -
+// when a kind or resource is not found, then the cache is refreshed (if allowed by the minimal refresh interval) and the mapping is retried.
+// The resources can be referred by their short names as well.
+//
+// Unlike the other lookups of the cache, the mapper knows all the served versions of the resources, not only the preferred ones.
+// They are discovered on the first use of the mapper after every refresh of the cache. The preferred version is used when no version is requested.
+//
+// The returned mapper also implements meta.ResettableRESTMapper - the reset invalidates the whole cache.
+func (rc *ResourceCache) RESTMapper() meta.ResettableRESTMapper {
+	return &resourceCacheRESTMapper{cache: rc}
+}
+
+type resourceCacheRESTMapper struct {
+	cache *ResourceCache
+}
+
+var _ meta.ResettableRESTMapper = &resourceCacheRESTMapper{}
+
+// mapping calls the given function with the mapper built from the current resources and retries it once the cache is refreshed
+// if the function returns the "no match" error.
+func (m *resourceCacheRESTMapper) mapping(mapFn func(resources *discoveredResources) error) error {
+	var mappingErr error
+	err := m.cache.lookup(func(resources *discoveredResources) (bool, error) {
+		if err := m.cache.ensureMapper(resources); err != nil {
+			return false, err
+		}
+		mappingErr = mapFn(resources)
+		return !meta.IsNoMatchError(mappingErr), nil
+	})
+	if err != nil {
+		return err
+	}
+	return mappingErr
+}
+
+// ensureMapper builds the mapper of the given resources from all the groups and versions served by the cluster, if not built yet
+func (rc *ResourceCache) ensureMapper(resources *discoveredResources) error {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	if resources.mapper != nil {
+		return nil
+	}
+	groups, resourceLists, err := rc.discoveryClient.ServerGroupsAndResources()
+	if err != nil {
+		if !rc.keepPartialResults || !discovery.IsGroupDiscoveryFailedError(err) || len(resourceLists) == 0 {
+			return fmt.Errorf("failed to discover the served API resources: %w", err)
+		}
+		log.Info("some of the API groups could not be discovered, using the partial results in the REST mapper", "error", err.Error())
+	}
+
+	resourcesPerGroupVersion := make(map[string][]metav1.APIResource, len(resourceLists))
+	for _, resourceList := range resourceLists {
+		if resourceList != nil {
+			resourcesPerGroupVersion[resourceList.GroupVersion] = resourceList.APIResources
+		}
+	}
+	groupResources := make([]*restmapper.APIGroupResources, 0, len(groups))
+	for _, group := range groups {
+		if group == nil {
+			continue
+		}
+		versionedResources := map[string][]metav1.APIResource{}
+		for _, version := range group.Versions {
+			if apiResources, ok := resourcesPerGroupVersion[version.GroupVersion]; ok {
+				versionedResources[version.Version] = apiResources
+			}
+		}
+		groupResources = append(groupResources, &restmapper.APIGroupResources{Group: *group, VersionedResources: versionedResources})
+	}
+	resources.mapper = restmapper.NewDiscoveryRESTMapper(groupResources)
+	return nil
+}
+
+func (m *resourceCacheRESTMapper) KindFor(resource schema.GroupVersionResource) (gvk schema.GroupVersionKind, err error) {
+	err = m.mapping(func(resources *discoveredResources) (err error) {
+		gvk, err = resources.mapper.KindFor(resources.expandShortName(resource))
+		return
+	})
+	return
+}
+
+func (m *resourceCacheRESTMapper) KindsFor(resource schema.GroupVersionResource) (gvks []schema.GroupVersionKind, err error) {
+	err = m.mapping(func(resources *discoveredResources) (err error) {
+		gvks, err = resources.mapper.KindsFor(resources.expandShortName(resource))
+		return
+	})
+	return
+}
+
+func (m *resourceCacheRESTMapper) ResourceFor(input schema.GroupVersionResource) (gvr schema.GroupVersionResource, err error) {
+	err = m.mapping(func(resources *discoveredResources) (err error) {
+		gvr, err = resources.mapper.ResourceFor(resources.expandShortName(input))
+		return
+	})
+	return
+}
+
+func (m *resourceCacheRESTMapper) ResourcesFor(input schema.GroupVersionResource) (gvrs []schema.GroupVersionResource, err error) {
+	err = m.mapping(func(resources *discoveredResources) (err error) {
+		gvrs, err = resources.mapper.ResourcesFor(resources.expandShortName(input))
+		return
+	})
+	return
+}
+
+func (m *resourceCacheRESTMapper) RESTMapping(gk schema.GroupKind, versions ...string) (mapping *meta.RESTMapping, err error) {
+	err = m.mapping(func(resources *discoveredResources) (err error) {
+		mapping, err = resources.mapper.RESTMapping(gk, versions...)
+		return
+	})
+	return
+}
+
+func (m *resourceCacheRESTMapper) RESTMappings(gk schema.GroupKind, versions ...string) (mappings []*meta.RESTMapping, err error) {
+	err = m.mapping(func(resources *discoveredResources) (err error) {
+		mappings, err = resources.mapper.RESTMappings(gk, versions...)
+		return
+	})
+	return
+}
+
+func (m *resourceCacheRESTMapper) ResourceSingularizer(resource string) (singular string, err error) {
+	err = m.mapping(func(resources *discoveredResources) (err error) {
+		singular, err = resources.mapper.ResourceSingularizer(resources.expandShortName(schema.GroupVersionResource{Resource: resource}).Resource)
+		return
+	})
+	return
+}
+
+func (m *resourceCacheRESTMapper) Reset() {
+	m.cache.Invalidate()
+}
+
+// expandShortName replaces the short name of the resource (eg. `deploy`) with its plural name (eg. `deployments`).
+// The resource is returned unchanged if it's not a short name of any resource.
+func (r *discoveredResources) expandShortName(resource schema.GroupVersionResource) schema.GroupVersionResource {
+	info, found, err := r.find(func(gv schema.GroupVersion, res metav1.APIResource) bool {
+		return (resource.Group == "" || gv.Group == resource.Group) && slices.Contains(res.ShortNames, resource.Resource)
+	})
+	if err != nil || !found {
+		return resource
+	}
+	resource.Resource = info.GVR.Resource
+	if resource.Group == "" {
+		resource.Group = info.GVR.Group
+	}
+	return resource
+}
@@ -0,0 +1,221 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestResourceCacheRESTMapper(t *testing.T) {
+	coreResources := &metav1.APIResourceList{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{
+			{Name: "pods", SingularName: "pod", Kind: "Pod", Namespaced: true, ShortNames: []string{"po"}},
+			{Name: "namespaces", SingularName: "namespace", Kind: "Namespace", ShortNames: []string{"ns"}},
+		},
+	}
+	appsResources := &metav1.APIResourceList{
+		GroupVersion: "apps/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "deployments", SingularName: "deployment", Kind: "Deployment", Namespaced: true, ShortNames: []string{"deploy"}},
+		},
+	}
+	vmResources := &metav1.APIResourceList{
+		GroupVersion: "kubevirt.io/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "virtualmachines", SingularName: "virtualmachine", Kind: "VirtualMachine", Namespaced: true, ShortNames: []string{"vm"}},
+		},
+	}
+	deploymentGVK := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	deploymentGVR := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+
+	newMapper := func() (meta.ResettableRESTMapper, *fakeDiscoveryClient) {
+		dc := &fakeDiscoveryClient{resources: []*metav1.APIResourceList{coreResources, appsResources}}
+		return NewResourceCache(dc, ResourceCacheMinRefreshInterval(0)).RESTMapper(), dc
+	}
+
+	t.Run("RESTMapping", func(t *testing.T) {
+		mapper, _ := newMapper()
+
+		t.Run("namespaced", func(t *testing.T) {
+			mapping, err := mapper.RESTMapping(deploymentGVK.GroupKind())
+
+			require.NoError(t, err)
+			assert.Equal(t, deploymentGVK, mapping.GroupVersionKind)
+			assert.Equal(t, deploymentGVR, mapping.Resource)
+			assert.Equal(t, meta.RESTScopeNameNamespace, mapping.Scope.Name())
+		})
+
+		t.Run("cluster-scoped", func(t *testing.T) {
+			mapping, err := mapper.RESTMapping(schema.GroupKind{Kind: "Namespace"}, "v1")
+
+			require.NoError(t, err)
+			assert.Equal(t, schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, mapping.Resource)
+			assert.Equal(t, meta.RESTScopeNameRoot, mapping.Scope.Name())
+		})
+
+		t.Run("RESTMappings", func(t *testing.T) {
+			mappings, err := mapper.RESTMappings(deploymentGVK.GroupKind())
+
+			require.NoError(t, err)
+			require.Len(t, mappings, 1)
+			assert.Equal(t, deploymentGVR, mappings[0].Resource)
+		})
+
+		t.Run("no match", func(t *testing.T) {
+			_, err := mapper.RESTMapping(schema.GroupKind{Group: "apps", Kind: "StatefulSet"})
+
+			require.Error(t, err)
+			assert.True(t, meta.IsNoMatchError(err))
+		})
+	})
+
+	t.Run("KindFor", func(t *testing.T) {
+		mapper, _ := newMapper()
+
+		for _, resource := range []schema.GroupVersionResource{
+			deploymentGVR,
+			{Resource: "deployments"},
+			{Resource: "deployment"},
+			{Resource: "deploy"},
+			{Group: "apps", Resource: "deploy"},
+		} {
+			t.Run(resource.String(), func(t *testing.T) {
+				gvk, err := mapper.KindFor(resource)
+
+				require.NoError(t, err)
+				assert.Equal(t, deploymentGVK, gvk)
+
+				gvks, err := mapper.KindsFor(resource)
+
+				require.NoError(t, err)
+				assert.Equal(t, []schema.GroupVersionKind{deploymentGVK}, gvks)
+			})
+		}
+	})
+
+	t.Run("ResourceFor", func(t *testing.T) {
+		mapper, _ := newMapper()
+
+		gvr, err := mapper.ResourceFor(schema.GroupVersionResource{Resource: "po"})
+
+		require.NoError(t, err)
+		assert.Equal(t, schema.GroupVersionResource{Version: "v1", Resource: "pods"}, gvr)
+
+		gvrs, err := mapper.ResourcesFor(schema.GroupVersionResource{Resource: "deploy"})
+
+		require.NoError(t, err)
+		assert.Equal(t, []schema.GroupVersionResource{deploymentGVR}, gvrs)
+	})
+
+	t.Run("ResourceSingularizer", func(t *testing.T) {
+		mapper, _ := newMapper()
+
+		singular, err := mapper.ResourceSingularizer("deployments")
+
+		require.NoError(t, err)
+		assert.Equal(t, "deployment", singular)
+	})
+
+	t.Run("non-preferred version", func(t *testing.T) {
+		// given
+		hpaV2 := &metav1.APIResourceList{
+			GroupVersion: "autoscaling/v2",
+			APIResources: []metav1.APIResource{
+				{Name: "horizontalpodautoscalers", SingularName: "horizontalpodautoscaler", Kind: "HorizontalPodAutoscaler", Namespaced: true, ShortNames: []string{"hpa"}},
+			},
+		}
+		hpaV1 := &metav1.APIResourceList{
+			GroupVersion: "autoscaling/v1",
+			APIResources: hpaV2.APIResources,
+		}
+		dc := &fakeDiscoveryClient{
+			resources:    []*metav1.APIResourceList{coreResources, hpaV2},
+			allResources: []*metav1.APIResourceList{coreResources, hpaV2, hpaV1},
+		}
+		mapper := NewResourceCache(dc, ResourceCacheMinRefreshInterval(0)).RESTMapper()
+		hpaGK := schema.GroupKind{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"}
+		hpaV1GVR := schema.GroupVersionResource{Group: "autoscaling", Version: "v1", Resource: "horizontalpodautoscalers"}
+
+		t.Run("RESTMapping", func(t *testing.T) {
+			mapping, err := mapper.RESTMapping(hpaGK, "v1")
+
+			require.NoError(t, err)
+			assert.Equal(t, hpaV1GVR, mapping.Resource)
+			assert.Equal(t, hpaGK.WithVersion("v1"), mapping.GroupVersionKind)
+		})
+
+		t.Run("KindFor", func(t *testing.T) {
+			gvk, err := mapper.KindFor(hpaV1GVR)
+
+			require.NoError(t, err)
+			assert.Equal(t, hpaGK.WithVersion("v1"), gvk)
+		})
+
+		t.Run("ResourceFor", func(t *testing.T) {
+			gvr, err := mapper.ResourceFor(schema.GroupVersionResource{Group: "autoscaling", Version: "v1", Resource: "hpa"})
+
+			require.NoError(t, err)
+			assert.Equal(t, hpaV1GVR, gvr)
+		})
+
+		t.Run("preferred version when no version is requested", func(t *testing.T) {
+			mapping, err := mapper.RESTMapping(hpaGK)
+
+			require.NoError(t, err)
+			assert.Equal(t, hpaGK.WithVersion("v2"), mapping.GroupVersionKind)
+		})
+
+		t.Run("no rediscovery", func(t *testing.T) {
+			assert.Equal(t, int32(1), dc.calls.Load())
+			assert.Equal(t, int32(1), dc.groupsCalls.Load())
+		})
+	})
+
+	t.Run("refreshed when the kind is registered later", func(t *testing.T) {
+		// given
+		mapper, dc := newMapper()
+		_, err := mapper.RESTMapping(schema.GroupKind{Group: "kubevirt.io", Kind: "VirtualMachine"})
+		require.True(t, meta.IsNoMatchError(err))
+		dc.resources = []*metav1.APIResourceList{coreResources, appsResources, vmResources}
+
+		// when
+		mapping, err := mapper.RESTMapping(schema.GroupKind{Group: "kubevirt.io", Kind: "VirtualMachine"})
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, schema.GroupVersionResource{Group: "kubevirt.io", Version: "v1", Resource: "virtualmachines"}, mapping.Resource)
+	})
+
+	t.Run("reset invalidates the cache", func(t *testing.T) {
+		// given
+		dc := &fakeDiscoveryClient{resources: []*metav1.APIResourceList{coreResources}}
+		mapper := NewResourceCache(dc, ResourceCacheMinRefreshInterval(time.Hour)).RESTMapper()
+		_, err := mapper.KindFor(schema.GroupVersionResource{Resource: "pods"})
+		require.NoError(t, err)
+		dc.resources = []*metav1.APIResourceList{coreResources, vmResources}
+
+		// when
+		mapper.Reset()
+
+		// then
+		gvk, err := mapper.KindFor(schema.GroupVersionResource{Resource: "vm"})
+		require.NoError(t, err)
+		assert.Equal(t, schema.GroupVersionKind{Group: "kubevirt.io", Version: "v1", Kind: "VirtualMachine"}, gvk)
+		assert.Equal(t, int32(2), dc.calls.Load())
+	})
+
+	t.Run("discovery error", func(t *testing.T) {
+		dc := &fakeDiscoveryClient{err: assert.AnError}
+		mapper := NewResourceCache(dc).RESTMapper()
+
+		_, err := mapper.RESTMapping(deploymentGVK.GroupKind())
+
+		require.ErrorIs(t, err, assert.AnError)
+	})
+}
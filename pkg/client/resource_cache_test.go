@@ -3,27 +3,59 @@ package client
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 )
 
 type fakeDiscoveryClient struct {
 	resources []*metav1.APIResourceList
 	err       error
 	calls     atomic.Int32
+	// all the served resources (in all versions) returned by ServerGroupsAndResources, the resources are used if not set.
+	// The first version of every group is the preferred one.
+	allResources []*metav1.APIResourceList
+	groupsCalls  atomic.Int32
+	// all resources (including the subresources) per group version
+	groupVersionResources map[string]*metav1.APIResourceList
+	groupVersionCalls     atomic.Int32
 }
 
-func (f *fakeDiscoveryClient) ServerResourcesForGroupVersion(string) (*metav1.APIResourceList, error) {
-	return nil, nil
+func (f *fakeDiscoveryClient) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	f.groupVersionCalls.Add(1)
+	return f.groupVersionResources[groupVersion], nil
 }
 
 func (f *fakeDiscoveryClient) ServerGroupsAndResources() ([]*metav1.APIGroup, []*metav1.APIResourceList, error) {
-	return nil, nil, nil
+	f.groupsCalls.Add(1)
+	resources := f.allResources
+	if resources == nil {
+		resources = f.resources
+	}
+	var groups []*metav1.APIGroup
+	for _, resourceList := range resources {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			return nil, nil, err
+		}
+		version := metav1.GroupVersionForDiscovery{GroupVersion: resourceList.GroupVersion, Version: gv.Version}
+		i := slices.IndexFunc(groups, func(group *metav1.APIGroup) bool {
+			return group.Name == gv.Group
+		})
+		if i < 0 {
+			groups = append(groups, &metav1.APIGroup{Name: gv.Group, PreferredVersion: version})
+			i = len(groups) - 1
+		}
+		groups[i].Versions = append(groups[i].Versions, version)
+	}
+	return groups, resources, f.err
 }
 
 func (f *fakeDiscoveryClient) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
@@ -239,3 +271,357 @@ func TestThreadSafety(t *testing.T) {
 	// then
 	assert.Equal(t, int32(1), cl.calls.Load())
 }
+
+func TestResourceCacheRefresh(t *testing.T) {
+	coreResources := &metav1.APIResourceList{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{
+			{Name: "pods", Kind: "Pod", Namespaced: true},
+		},
+	}
+	vmResources := &metav1.APIResourceList{
+		GroupVersion: "kubevirt.io/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "virtualmachines", Kind: "VirtualMachine", Namespaced: true},
+		},
+	}
+	newCache := func(dc *fakeDiscoveryClient, options ...ResourceCacheOption) (*ResourceCache, *time.Time) {
+		rc := NewResourceCache(dc, options...)
+		now := time.Now()
+		rc.now = func() time.Time {
+			return now
+		}
+		return rc, &now
+	}
+
+	t.Run("refreshed after TTL", func(t *testing.T) {
+		// given
+		dc := &fakeDiscoveryClient{resources: []*metav1.APIResourceList{coreResources}}
+		rc, now := newCache(dc, ResourceCacheTTL(time.Minute))
+		_, _, _, err := rc.GVRForKind("Pod", "v1")
+		require.NoError(t, err)
+
+		t.Run("not refreshed before TTL", func(t *testing.T) {
+			// given
+			*now = now.Add(59 * time.Second)
+
+			// when
+			_, found, _, err := rc.GVRForKind("Pod", "v1")
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, int32(1), dc.calls.Load())
+		})
+
+		t.Run("refreshed when TTL expired", func(t *testing.T) {
+			// given
+			*now = now.Add(time.Second)
+
+			// when
+			_, found, _, err := rc.GVRForKind("Pod", "v1")
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, int32(2), dc.calls.Load())
+		})
+	})
+
+	t.Run("TTL disabled", func(t *testing.T) {
+		// given
+		dc := &fakeDiscoveryClient{resources: []*metav1.APIResourceList{coreResources}}
+		rc, now := newCache(dc, ResourceCacheTTL(0))
+		_, _, _, err := rc.GVRForKind("Pod", "v1")
+		require.NoError(t, err)
+		*now = now.Add(24 * time.Hour)
+
+		// when
+		_, _, _, err = rc.GVRForKind("Pod", "v1")
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), dc.calls.Load())
+	})
+
+	t.Run("refreshed on miss", func(t *testing.T) {
+		// given
+		dc := &fakeDiscoveryClient{resources: []*metav1.APIResourceList{coreResources}}
+		rc, now := newCache(dc, ResourceCacheMinRefreshInterval(time.Minute))
+		_, found, _, err := rc.GVRForKind("VirtualMachine", "kubevirt.io/v1")
+		require.NoError(t, err)
+		require.False(t, found)
+		// the CRD is installed later
+		dc.resources = []*metav1.APIResourceList{coreResources, vmResources}
+
+		t.Run("rate limited", func(t *testing.T) {
+			// given
+			*now = now.Add(30 * time.Second)
+
+			// when
+			_, found, _, err := rc.GVRForKind("VirtualMachine", "kubevirt.io/v1")
+
+			// then
+			require.NoError(t, err)
+			assert.False(t, found)
+			assert.Equal(t, int32(1), dc.calls.Load())
+		})
+
+		t.Run("found after the minimal refresh interval", func(t *testing.T) {
+			// given
+			*now = now.Add(30 * time.Second)
+
+			// when
+			gvr, found, _, err := rc.GVRForKind("VirtualMachine", "kubevirt.io/v1")
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, schema.GroupVersionResource{Group: "kubevirt.io", Version: "v1", Resource: "virtualmachines"}, gvr)
+			assert.Equal(t, int32(2), dc.calls.Load())
+		})
+
+		t.Run("GVKForGR", func(t *testing.T) {
+			// given
+			dc.resources = []*metav1.APIResourceList{coreResources}
+			rc.Invalidate()
+			_, found, err := rc.GVKForGR(schema.GroupResource{Group: "kubevirt.io", Resource: "virtualmachines"})
+			require.NoError(t, err)
+			require.False(t, found)
+			dc.resources = []*metav1.APIResourceList{coreResources, vmResources}
+			*now = now.Add(time.Minute)
+
+			// when
+			gvk, found, err := rc.GVKForGR(schema.GroupResource{Group: "kubevirt.io", Resource: "virtualmachines"})
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, schema.GroupVersionKind{Group: "kubevirt.io", Version: "v1", Kind: "VirtualMachine"}, gvk)
+		})
+	})
+
+	t.Run("invalidate", func(t *testing.T) {
+		// given
+		dc := &fakeDiscoveryClient{resources: []*metav1.APIResourceList{coreResources}}
+		rc, _ := newCache(dc)
+		_, _, _, err := rc.GVRForKind("Pod", "v1")
+		require.NoError(t, err)
+		dc.resources = []*metav1.APIResourceList{coreResources, vmResources}
+
+		// when
+		rc.Invalidate()
+
+		// then
+		_, found, _, err := rc.GVRForKind("VirtualMachine", "kubevirt.io/v1")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, int32(2), dc.calls.Load())
+	})
+
+	t.Run("failed refresh keeps the previous resources", func(t *testing.T) {
+		// given
+		dc := &fakeDiscoveryClient{resources: []*metav1.APIResourceList{coreResources}}
+		rc, now := newCache(dc, ResourceCacheTTL(time.Minute))
+		_, _, _, err := rc.GVRForKind("Pod", "v1")
+		require.NoError(t, err)
+		dc.resources = nil
+		dc.err = errors.New("discovery failed")
+		*now = now.Add(time.Minute)
+
+		// when
+		_, found, _, err := rc.GVRForKind("Pod", "v1")
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, int32(2), dc.calls.Load())
+	})
+
+	t.Run("partial results", func(t *testing.T) {
+		partialErr := &discovery.ErrGroupDiscoveryFailed{
+			Groups: map[schema.GroupVersion]error{
+				{Group: "metrics.k8s.io", Version: "v1beta1"}: errors.New("service unavailable"),
+			},
+		}
+
+		t.Run("thrown away by default", func(t *testing.T) {
+			// given
+			dc := &fakeDiscoveryClient{resources: []*metav1.APIResourceList{coreResources}, err: partialErr}
+			rc, _ := newCache(dc)
+
+			// when
+			_, _, _, err := rc.GVRForKind("Pod", "v1")
+
+			// then
+			require.ErrorContains(t, err, "unable to retrieve the complete list of server APIs")
+		})
+
+		t.Run("kept when configured", func(t *testing.T) {
+			// given
+			dc := &fakeDiscoveryClient{resources: []*metav1.APIResourceList{coreResources}, err: partialErr}
+			rc, _ := newCache(dc, KeepPartialDiscoveryResults(true))
+
+			// when
+			gvr, found, _, err := rc.GVRForKind("Pod", "v1")
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, schema.GroupVersionResource{Version: "v1", Resource: "pods"}, gvr)
+		})
+
+		t.Run("other errors are returned", func(t *testing.T) {
+			// given
+			dc := &fakeDiscoveryClient{resources: []*metav1.APIResourceList{coreResources}, err: errors.New("discovery failed")}
+			rc, _ := newCache(dc, KeepPartialDiscoveryResults(true))
+
+			// when
+			_, _, _, err := rc.GVRForKind("Pod", "v1")
+
+			// then
+			require.EqualError(t, err, "discovery failed")
+		})
+	})
+}
+
+func TestResourceInfo(t *testing.T) {
+	resources := []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", SingularName: "pod", Kind: "Pod", Namespaced: true, ShortNames: []string{"po"}, Verbs: []string{"get", "list", "patch"}},
+				{Name: "namespaces", Kind: "Namespace", ShortNames: []string{"ns"}, Verbs: []string{"get", "list"}},
+			},
+		},
+		{
+			GroupVersion: "apps/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "deployments", SingularName: "deployment", Kind: "Deployment", Namespaced: true, ShortNames: []string{"deploy"}, Verbs: []string{"get", "list", "patch", "update"}},
+			},
+		},
+		{
+			GroupVersion: "toolchain.dev.openshift.com/v1alpha1",
+			APIResources: []metav1.APIResource{
+				{Name: "spaces", SingularName: "space", Kind: "Space", Namespaced: true, Verbs: []string{"get", "list"}},
+			},
+		},
+	}
+	groupVersionResources := map[string]*metav1.APIResourceList{
+		"apps/v1": {
+			GroupVersion: "apps/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "deployments", Kind: "Deployment", Namespaced: true},
+				{Name: "deployments/scale", Kind: "Scale", Group: "autoscaling", Version: "v1", Namespaced: true},
+				{Name: "deployments/status", Kind: "Deployment", Namespaced: true},
+			},
+		},
+	}
+	deploymentInfo := APIResourceInfo{
+		GVR:          schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+		GVK:          schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		Namespaced:   true,
+		SingularName: "deployment",
+		ShortNames:   []string{"deploy"},
+		Verbs:        []string{"get", "list", "patch", "update"},
+	}
+
+	t.Run("ResourceInfoForName", func(t *testing.T) {
+		rc := NewResourceCache(&fakeDiscoveryClient{resources: resources})
+
+		for _, name := range []string{"deployments", "deployment", "deploy", "Deployments", "deploy.apps", "deployments.apps"} {
+			t.Run(name, func(t *testing.T) {
+				info, found, err := rc.ResourceInfoForName(name)
+
+				require.NoError(t, err)
+				assert.True(t, found)
+				assert.Equal(t, deploymentInfo, info)
+			})
+		}
+
+		t.Run("singular name defaults to the lowercase kind", func(t *testing.T) {
+			info, found, err := rc.ResourceInfoForName("namespace")
+
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, info.GVR)
+			assert.Equal(t, "namespace", info.SingularName)
+			assert.False(t, info.Namespaced)
+		})
+
+		t.Run("not found in different group", func(t *testing.T) {
+			_, found, err := rc.ResourceInfoForName("deploy.extensions")
+
+			require.NoError(t, err)
+			assert.False(t, found)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			_, found, err := rc.ResourceInfoForName("virtualmachines")
+
+			require.NoError(t, err)
+			assert.False(t, found)
+		})
+	})
+
+	t.Run("PreferredGVKForGroupKind", func(t *testing.T) {
+		rc := NewResourceCache(&fakeDiscoveryClient{resources: resources})
+
+		t.Run("found", func(t *testing.T) {
+			gvk, found, err := rc.PreferredGVKForGroupKind(schema.GroupKind{Group: "toolchain.dev.openshift.com", Kind: "Space"})
+
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, schema.GroupVersionKind{Group: "toolchain.dev.openshift.com", Version: "v1alpha1", Kind: "Space"}, gvk)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			_, found, err := rc.PreferredGVKForGroupKind(schema.GroupKind{Group: "extensions", Kind: "Deployment"})
+
+			require.NoError(t, err)
+			assert.False(t, found)
+		})
+	})
+
+	t.Run("ResourceInfoForGVK", func(t *testing.T) {
+		dc := &fakeDiscoveryClient{resources: resources, groupVersionResources: groupVersionResources}
+		rc := NewResourceCache(dc)
+
+		t.Run("with subresources", func(t *testing.T) {
+			info, found, err := rc.ResourceInfoForGVK(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+
+			require.NoError(t, err)
+			assert.True(t, found)
+			expected := deploymentInfo
+			expected.Subresources = []string{"scale", "status"}
+			assert.Equal(t, expected, info)
+			assert.True(t, info.HasSubresource("status"))
+			assert.False(t, info.HasSubresource("log"))
+			assert.True(t, info.SupportsVerb("patch"))
+			assert.False(t, info.SupportsVerb("delete"))
+		})
+
+		t.Run("without subresources", func(t *testing.T) {
+			info, found, err := rc.ResourceInfoForGVK(schema.GroupVersionKind{Version: "v1", Kind: "Pod"})
+
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Empty(t, info.Subresources)
+			assert.False(t, info.HasSubresource("status"))
+		})
+
+		t.Run("subresources are cached", func(t *testing.T) {
+			_, _, err := rc.ResourceInfoForGVK(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+
+			require.NoError(t, err)
+			assert.Equal(t, int32(2), dc.groupVersionCalls.Load())
+		})
+
+		t.Run("not found in different version", func(t *testing.T) {
+			_, found, err := rc.ResourceInfoForGVK(schema.GroupVersionKind{Group: "apps", Version: "v1beta1", Kind: "Deployment"})
+
+			require.NoError(t, err)
+			assert.False(t, found)
+		})
+	})
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultBatchWorkers is the default number of objects applied in parallel by the BatchApplier
+const DefaultBatchWorkers = 5
+
+// ApplyTier returns the tier in which the object of the given kind should be applied. The objects with a lower tier are applied first.
+type ApplyTier func(gk schema.GroupKind) int
+
+// applyTiers contains the kinds that need to be applied before the others. All the other kinds are applied in the last tier.
+var applyTiers = map[schema.GroupKind]int{
+	// namespaces and definitions of the custom resources go first
+	{Group: "", Kind: "Namespace"}:                                    0,
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}: 0,
+	// then the identities, permissions and configuration used by the workloads
+	{Group: "", Kind: "ServiceAccount"}:                              1,
+	{Group: "rbac.authorization.k8s.io", Kind: "Role"}:               1,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:        1,
+	{Group: "", Kind: "ConfigMap"}:                                   1,
+	{Group: "", Kind: "Secret"}:                                      1,
+	{Group: "", Kind: "LimitRange"}:                                  1,
+	{Group: "", Kind: "ResourceQuota"}:                               1,
+	{Group: "scheduling.k8s.io", Kind: "PriorityClass"}:              1,
+	{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"}:        2,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}: 2,
+}
+
+// DefaultApplyTier orders the kinds by their dependencies: Namespaces and CRDs first, then ServiceAccounts, Roles and configuration,
+// then RoleBindings and finally the workloads and all the other kinds.
+func DefaultApplyTier(gk schema.GroupKind) int {
+	if tier, ok := applyTiers[gk]; ok {
+		return tier
+	}
+	return 3
+}
+
+// BatchApplier applies a set of objects using the SSAApplyClient. Unlike ApplyAll, the objects are ordered by the dependencies between their kinds
+// and the objects within the same tier are applied in parallel. The BatchApplier doesn't stop at the first failure - all the objects are applied
+// and all the errors are returned as a single aggregated error.
+type BatchApplier struct {
+	Client *SSAApplyClient
+
+	// Workers is the maximum number of objects applied in parallel. DefaultBatchWorkers is used when not set.
+	Workers int
+
+	// Tier determines the order in which the objects are applied. DefaultApplyTier is used when not set.
+	Tier ApplyTier
+}
+
+// NewBatchApplier creates a new BatchApplier that uses the provided SSAApplyClient, the default number of workers and the default ordering.
+func NewBatchApplier(cl *SSAApplyClient) *BatchApplier {
+	return &BatchApplier{
+		Client:  cl,
+		Workers: DefaultBatchWorkers,
+		Tier:    DefaultApplyTier,
+	}
+}
+
+// Apply applies all the objects tier by tier. All the objects of one tier are applied before the objects of the next tier are applied,
+// even when some of them failed. The returned error aggregates the errors of all the failed objects.
+//
+// If the Prune option is used, then the orphaned objects are deleted only when all the objects were successfully applied.
+func (b *BatchApplier) Apply(ctx context.Context, toolchainObjects []client.Object, opts ...SSAApplyObjectOption) error {
+	tiers, err := b.splitToTiers(toolchainObjects)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, tier := range tiers {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+		errs = append(errs, b.applyTier(ctx, tier, opts)...)
+	}
+	if len(errs) > 0 {
+		return utilerrors.NewAggregate(errs)
+	}
+
+	_, err = b.Client.prune(ctx, toolchainObjects, newSSAApplyObjectConfiguration(opts...))
+	return err
+}
+
+func (b *BatchApplier) splitToTiers(toolchainObjects []client.Object) ([][]client.Object, error) {
+	tierOf := b.Tier
+	if tierOf == nil {
+		tierOf = DefaultApplyTier
+	}
+	byTier := map[int][]client.Object{}
+	for _, obj := range toolchainObjects {
+		// the GVK is needed for the ordering
+		if err := EnsureGVK(obj, b.Client.Client.Scheme()); err != nil {
+			return nil, composeError(obj, err)
+		}
+		tier := tierOf(obj.GetObjectKind().GroupVersionKind().GroupKind())
+		byTier[tier] = append(byTier[tier], obj)
+	}
+	tierNumbers := make([]int, 0, len(byTier))
+	for tier := range byTier {
+		tierNumbers = append(tierNumbers, tier)
+	}
+	sort.Ints(tierNumbers)
+	tiers := make([][]client.Object, 0, len(tierNumbers))
+	for _, tier := range tierNumbers {
+		tiers = append(tiers, byTier[tier])
+	}
+	return tiers, nil
+}
+
+func (b *BatchApplier) applyTier(ctx context.Context, toolchainObjects []client.Object, opts []SSAApplyObjectOption) []error {
+	workers := b.Workers
+	if workers <= 0 {
+		workers = DefaultBatchWorkers
+	}
+	semaphore := make(chan struct{}, workers)
+	errs := make([]error, len(toolchainObjects))
+	var wg sync.WaitGroup
+	for i, obj := range toolchainObjects {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int, obj client.Object) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			errs[i] = b.Client.ApplyObject(ctx, obj, opts...)
+		}(i, obj)
+	}
+	wg.Wait()
+
+	// keep the order of the errors the same as the order of the objects
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,204 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestDefaultApplyTier(t *testing.T) {
+	for gk, expected := range map[schema.GroupKind]int{
+		{Group: "", Kind: "Namespace"}:                                    0,
+		{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}: 0,
+		{Group: "", Kind: "ServiceAccount"}:                               1,
+		{Group: "rbac.authorization.k8s.io", Kind: "Role"}:                1,
+		{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"}:         2,
+		{Group: "apps", Kind: "Deployment"}:                               3,
+		{Group: "toolchain.dev.openshift.com", Kind: "Space"}:             3,
+	} {
+		t.Run(gk.String(), func(t *testing.T) {
+			assert.Equal(t, expected, client.DefaultApplyTier(gk))
+		})
+	}
+}
+
+func TestBatchApplier(t *testing.T) {
+	newObjects := func() []runtimeclient.Object {
+		return []runtimeclient.Object{
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "deployment", Namespace: "ns"}},
+			&rbac.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "rolebinding", Namespace: "ns"}},
+			&rbac.Role{ObjectMeta: metav1.ObjectMeta{Name: "role", Namespace: "ns"}},
+			&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "sa", Namespace: "ns"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}},
+		}
+	}
+
+	t.Run("applies objects ordered by kind dependencies", func(t *testing.T) {
+		// given
+		cl, acl := NewTestSsaApplyClient(t)
+		var lock sync.Mutex
+		var applied []string
+		cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+			lock.Lock()
+			applied = append(applied, obj.GetObjectKind().GroupVersionKind().Kind)
+			lock.Unlock()
+			return test.Patch(ctx, cl, obj, patch, opts...)
+		}
+
+		// when
+		err := client.NewBatchApplier(acl).Apply(context.TODO(), newObjects())
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, applied, 5)
+		assert.Equal(t, "Namespace", applied[0])
+		assert.ElementsMatch(t, []string{"ServiceAccount", "Role"}, applied[1:3])
+		assert.Equal(t, "RoleBinding", applied[3])
+		assert.Equal(t, "Deployment", applied[4])
+		require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKey{Namespace: "ns", Name: "deployment"}, &appsv1.Deployment{}))
+	})
+
+	t.Run("uses custom tiers", func(t *testing.T) {
+		// given
+		cl, acl := NewTestSsaApplyClient(t)
+		var applied []string
+		cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+			applied = append(applied, obj.GetName())
+			return test.Patch(ctx, cl, obj, patch, opts...)
+		}
+		applier := client.NewBatchApplier(acl)
+		applier.Workers = 1
+		applier.Tier = func(gk schema.GroupKind) int {
+			if gk.Kind == "Deployment" {
+				return -1
+			}
+			return 0
+		}
+
+		// when
+		err := applier.Apply(context.TODO(), newObjects())
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"deployment", "rolebinding", "role", "sa", "ns"}, applied)
+	})
+
+	t.Run("limits the number of parallel workers", func(t *testing.T) {
+		// given
+		cl, acl := NewTestSsaApplyClient(t)
+		var running, maxRunning atomic.Int32
+		cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+			current := running.Add(1)
+			defer running.Add(-1)
+			for {
+				observed := maxRunning.Load()
+				if current <= observed || maxRunning.CompareAndSwap(observed, current) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			return test.Patch(ctx, cl, obj, patch, opts...)
+		}
+		var objs []runtimeclient.Object
+		for i := 0; i < 10; i++ {
+			objs = append(objs, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("cm-%d", i), Namespace: "ns"}})
+		}
+		applier := client.NewBatchApplier(acl)
+		applier.Workers = 3
+
+		// when
+		err := applier.Apply(context.TODO(), objs)
+
+		// then
+		require.NoError(t, err)
+		assert.LessOrEqual(t, maxRunning.Load(), int32(3))
+		assert.Greater(t, maxRunning.Load(), int32(1))
+		list := &corev1.ConfigMapList{}
+		require.NoError(t, cl.List(context.TODO(), list))
+		assert.Len(t, list.Items, 10)
+	})
+
+	t.Run("collects all errors", func(t *testing.T) {
+		// given
+		cl, acl := NewTestSsaApplyClient(t)
+		cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+			if obj.GetName() == "ns" || obj.GetName() == "role" {
+				return fmt.Errorf("boom")
+			}
+			return test.Patch(ctx, cl, obj, patch, opts...)
+		}
+
+		// when
+		err := client.NewBatchApplier(acl).Apply(context.TODO(), newObjects())
+
+		// then
+		require.EqualError(t, err, "[unable to patch '/v1, Kind=Namespace' called 'ns' in namespace '': boom, "+
+			"unable to patch 'rbac.authorization.k8s.io/v1, Kind=Role' called 'role' in namespace 'ns': boom]")
+		// the other objects are applied
+		require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKey{Namespace: "ns", Name: "sa"}, &corev1.ServiceAccount{}))
+		require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKey{Namespace: "ns", Name: "deployment"}, &appsv1.Deployment{}))
+	})
+
+	t.Run("prunes only when all objects were applied", func(t *testing.T) {
+		pruneLabels := map[string]string{"provider": "test"}
+		orphaned := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "orphaned", Namespace: "ns", Labels: pruneLabels}}
+		prune := client.Prune(pruneLabels, corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+
+		t.Run("success", func(t *testing.T) {
+			// given
+			cl, acl := NewTestSsaApplyClient(t, orphaned.DeepCopy())
+
+			// when
+			err := client.NewBatchApplier(acl).Apply(context.TODO(), newObjects(), prune)
+
+			// then
+			require.NoError(t, err)
+			err = cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(orphaned), &corev1.ConfigMap{})
+			assert.True(t, errors.IsNotFound(err))
+		})
+
+		t.Run("failure", func(t *testing.T) {
+			// given
+			cl, acl := NewTestSsaApplyClient(t, orphaned.DeepCopy())
+			cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+				return fmt.Errorf("boom")
+			}
+
+			// when
+			err := client.NewBatchApplier(acl).Apply(context.TODO(), newObjects(), prune)
+
+			// then
+			require.Error(t, err)
+			require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(orphaned), &corev1.ConfigMap{}))
+		})
+	})
+
+	t.Run("fails when the GVK cannot be determined", func(t *testing.T) {
+		// given
+		_, acl := NewTestSsaApplyClient(t)
+		obj := &unstructured.Unstructured{}
+		obj.SetName("unknown")
+
+		// when
+		err := client.NewBatchApplier(acl).Apply(context.TODO(), []runtimeclient.Object{obj})
+
+		// then
+		require.ErrorContains(t, err, "unable to patch '*unstructured.Unstructured' called 'unknown' in namespace ''")
+	})
+}
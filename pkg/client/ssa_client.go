@@ -82,6 +82,12 @@ type ssaApplyObjectConfiguration struct {
 	newLabels  map[string]string
 	skipIf     func(client.Object) bool
 	migrateSSA migrateSSA
+	dryRun     bool
+	prune      *pruneConfiguration
+	// pruneNamespaces limits the pruning to the objects in these namespaces, see PruneInNamespaces
+	pruneNamespaces []string
+	// pruneLegacyLabels identify the objects labelled before the labels of the Prune option were extended, see PruneLegacyLabels
+	pruneLegacyLabels map[string]string
 }
 
 func newSSAApplyObjectConfiguration(options ...SSAApplyObjectOption) ssaApplyObjectConfiguration {
@@ -132,6 +138,15 @@ func MigrateSSA(value bool) SSAApplyObjectOption {
 	}
 }
 
+// ServerSideDryRun sends the SSA patch with the server-side dry-run (default: `false`), so the object
+// is processed by the API server (including the admission and defaulting), but nothing is persisted in the cluster.
+// The SSA managed fields migration is never done in the dry-run mode, because it would update the object.
+func ServerSideDryRun(value bool) SSAApplyObjectOption {
+	return func(config *ssaApplyObjectConfiguration) {
+		config.dryRun = value
+	}
+}
+
 // Configure sets the owner reference and merges the labels. Other options modify the logic
 // of apply function and therefore need to be checked manually.
 func (c *ssaApplyObjectConfiguration) Configure(obj client.Object, s *runtime.Scheme) error {
@@ -141,36 +156,94 @@ func (c *ssaApplyObjectConfiguration) Configure(obj client.Object, s *runtime.Sc
 		}
 	}
 	MergeLabels(obj, c.newLabels)
+	if c.prune != nil {
+		MergeLabels(obj, c.prune.labels)
+	}
 
 	return nil
 }
 
 // ApplyObject creates the object if is missing or update it if it already exists using an SSA patch.
 func (c *SSAApplyClient) ApplyObject(ctx context.Context, obj client.Object, options ...SSAApplyObjectOption) error {
+	_, err := c.applyObject(ctx, obj, false, options...)
+	return err
+}
+
+// ApplyObjectWithResult does the same as ApplyObject, but it also reports what happened with the object. The action in the returned result is
+// either create, update (with the changed fields in the diff), no-op or skip (when the object was skipped because of the SkipIf option).
+//
+// To compute the changed fields, the object is fetched from the cluster before the patch is sent. The returned diff then contains
+// the differences between the fetched object and the one returned by the server as the result of the patch.
+// Use the ServerSideDryRun option to get the report without persisting the changes.
+func (c *SSAApplyClient) ApplyObjectWithResult(ctx context.Context, obj client.Object, options ...SSAApplyObjectOption) (*ApplyResult, error) {
+	return c.applyObject(ctx, obj, true, options...)
+}
+
+func (c *SSAApplyClient) applyObject(ctx context.Context, obj client.Object, withResult bool, options ...SSAApplyObjectOption) (*ApplyResult, error) {
 	config := newSSAApplyObjectConfiguration(options...)
 	if err := config.Configure(obj, c.Client.Scheme()); err != nil {
-		return composeError(obj, fmt.Errorf("failed to configure the apply function: %w", err))
+		return nil, composeError(obj, fmt.Errorf("failed to configure the apply function: %w", err))
 	}
 
 	if err := prepareForSSA(obj, c.Client.Scheme()); err != nil {
-		return composeError(obj, fmt.Errorf("failed to prepare the object for SSA: %w", err))
+		return nil, composeError(obj, fmt.Errorf("failed to prepare the object for SSA: %w", err))
+	}
+
+	result := &ApplyResult{
+		DryRun:    config.dryRun,
+		GVK:       obj.GetObjectKind().GroupVersionKind(),
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
 	}
 
-	if config.migrateSSA == migrateSSAYes || (config.migrateSSA == migrateSSANotSpecified && c.MigrateSSAByDefault) {
+	if !config.dryRun && (config.migrateSSA == migrateSSAYes || (config.migrateSSA == migrateSSANotSpecified && c.MigrateSSAByDefault)) {
 		if err := c.migrateSSA(ctx, obj); err != nil {
-			return composeError(obj, err)
+			return nil, composeError(obj, err)
 		}
 	}
 
 	if config.skipIf != nil && config.skipIf(obj) {
-		return nil
+		result.Action = ApplyActionSkip
+		return result, nil
 	}
 
-	if err := c.Client.Patch(ctx, obj, client.Apply, client.FieldOwner(c.FieldOwner), client.ForceOwnership); err != nil {
-		return composeError(obj, err)
+	var existing client.Object
+	if withResult {
+		existing = obj.DeepCopyObject().(client.Object)
+		if err := c.Client.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return nil, composeError(obj, fmt.Errorf("failed to get the object from the cluster: %w", err))
+			}
+			existing = nil
+		}
 	}
 
-	return nil
+	patchOptions := []client.PatchOption{client.FieldOwner(c.FieldOwner), client.ForceOwnership}
+	if config.dryRun {
+		patchOptions = append(patchOptions, client.DryRunAll)
+	}
+	if err := c.Client.Patch(ctx, obj, client.Apply, patchOptions...); err != nil {
+		return nil, composeError(obj, err)
+	}
+
+	if !withResult {
+		return result, nil
+	}
+	if existing == nil {
+		result.Action = ApplyActionCreate
+		return result, nil
+	}
+	diff, err := diffAllFields(existing, obj)
+	if err != nil {
+		return nil, composeError(obj, fmt.Errorf("failed to compute the changed fields: %w", err))
+	}
+	result.Diff = diff
+	if len(diff) == 0 {
+		result.Action = ApplyActionNoOp
+	} else {
+		result.Action = ApplyActionUpdate
+	}
+	return result, nil
 }
 
 func (c *SSAApplyClient) migrateSSA(ctx context.Context, obj client.Object) error {
@@ -242,13 +315,46 @@ func (c *SSAApplyClient) Apply(ctx context.Context, toolchainObjects []client.Ob
 }
 
 // ApplyAll is a generic version of c.Apply that can accept a slice of anything that implements client.Object.
+// If the Prune option is used, then the orphaned objects are deleted once all the objects are successfully applied.
 func ApplyAll[T client.Object](ctx context.Context, cl *SSAApplyClient, toolchainObjects []T, opts ...SSAApplyObjectOption) error {
+	_, err := ApplyAllAndPrune(ctx, cl, toolchainObjects, opts...)
+	return err
+}
+
+// ApplyAllAndPrune does the same as ApplyAll, but it also returns the results of the orphaned objects deleted by the Prune option.
+// Unlike ApplyAllWithResults, the applied objects are not fetched from the cluster, so there are no results for them.
+func ApplyAllAndPrune[T client.Object](ctx context.Context, cl *SSAApplyClient, toolchainObjects []T, opts ...SSAApplyObjectOption) ([]*ApplyResult, error) {
+	applied := make([]client.Object, 0, len(toolchainObjects))
 	for _, toolchainObject := range toolchainObjects {
 		if err := cl.ApplyObject(ctx, toolchainObject, opts...); err != nil {
-			return err
+			return nil, err
 		}
+		applied = append(applied, toolchainObject)
 	}
-	return nil
+	return cl.prune(ctx, applied, newSSAApplyObjectConfiguration(opts...))
+}
+
+// ApplyWithResults is a utility function that just calls `ApplyObjectWithResult` in a loop on all the supplied objects.
+func (c *SSAApplyClient) ApplyWithResults(ctx context.Context, toolchainObjects []client.Object, opts ...SSAApplyObjectOption) ([]*ApplyResult, error) {
+	return ApplyAllWithResults(ctx, c, toolchainObjects, opts...)
+}
+
+// ApplyAllWithResults is a generic version of c.ApplyWithResults that can accept a slice of anything that implements client.Object.
+// It stops at the first error and returns the results of the objects that were applied before the failure, together with the error.
+// If the Prune option is used, then the results of the deleted orphaned objects are appended to the results of the applied objects.
+func ApplyAllWithResults[T client.Object](ctx context.Context, cl *SSAApplyClient, toolchainObjects []T, opts ...SSAApplyObjectOption) ([]*ApplyResult, error) {
+	results := make([]*ApplyResult, 0, len(toolchainObjects))
+	applied := make([]client.Object, 0, len(toolchainObjects))
+	for _, toolchainObject := range toolchainObjects {
+		result, err := cl.ApplyObjectWithResult(ctx, toolchainObject, opts...)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+		applied = append(applied, toolchainObject)
+	}
+	pruned, err := cl.prune(ctx, applied, newSSAApplyObjectConfiguration(opts...))
+	return append(results, pruned...), err
 }
 
 func isSsaMigrationNeeded(obj client.Object, expectedOwner string) bool {
@@ -17,6 +17,7 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
 	"k8s.io/utils/ptr"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
@@ -386,6 +387,197 @@ func TestSsaClient(t *testing.T) {
 	})
 }
 
+func TestSsaClientWithResult(t *testing.T) {
+	newConfigMap := func(data map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "obj",
+				Namespace: "default",
+			},
+			Data: data,
+		}
+	}
+
+	t.Run("ApplyObjectWithResult", func(t *testing.T) {
+		t.Run("creates", func(t *testing.T) {
+			// given
+			cl, acl := NewTestSsaApplyClient(t)
+			obj := newConfigMap(map[string]string{"a": "b"})
+
+			// when
+			result, err := acl.ApplyObjectWithResult(context.TODO(), obj)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyActionCreate, result.Action)
+			assert.False(t, result.DryRun)
+			assert.Equal(t, schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, result.GVK)
+			assert.Equal(t, "obj", result.Name)
+			assert.Equal(t, "default", result.Namespace)
+			require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(obj), &corev1.ConfigMap{}))
+		})
+		t.Run("updates and reports the changed fields", func(t *testing.T) {
+			// given
+			cl, acl := NewTestSsaApplyClient(t, newConfigMap(map[string]string{"a": "b"}))
+			updated := newConfigMap(map[string]string{"a": "c"})
+
+			// when
+			result, err := acl.ApplyObjectWithResult(context.TODO(), updated)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyActionUpdate, result.Action)
+			assert.Equal(t, []client.FieldDiff{{Path: "data.a", Live: "b", Desired: "c"}}, result.Diff)
+			inCluster := &corev1.ConfigMap{}
+			require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(updated), inCluster))
+			assert.Equal(t, "c", inCluster.Data["a"])
+		})
+		t.Run("reports unchanged", func(t *testing.T) {
+			// given
+			_, acl := NewTestSsaApplyClient(t, newConfigMap(map[string]string{"a": "b"}))
+
+			// when
+			result, err := acl.ApplyObjectWithResult(context.TODO(), newConfigMap(map[string]string{"a": "b"}))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyActionNoOp, result.Action)
+			assert.Empty(t, result.Diff)
+		})
+		t.Run("reports skipped", func(t *testing.T) {
+			// given
+			cl, acl := NewTestSsaApplyClient(t)
+			obj := newConfigMap(nil)
+
+			// when
+			result, err := acl.ApplyObjectWithResult(context.TODO(), obj, client.SkipIf(func(runtimeclient.Object) bool {
+				return true
+			}))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyActionSkip, result.Action)
+			assert.False(t, result.Changed())
+			require.True(t, errors.IsNotFound(cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(obj), &corev1.ConfigMap{})))
+		})
+		t.Run("fails when the object cannot be retrieved", func(t *testing.T) {
+			// given
+			cl, acl := NewTestSsaApplyClient(t)
+			cl.MockGet = func(ctx context.Context, key runtimeclient.ObjectKey, obj runtimeclient.Object, opts ...runtimeclient.GetOption) error {
+				return fmt.Errorf("boom")
+			}
+
+			// when
+			_, err := acl.ApplyObjectWithResult(context.TODO(), newConfigMap(nil))
+
+			// then
+			require.EqualError(t, err, "unable to patch '/v1, Kind=ConfigMap' called 'obj' in namespace 'default': failed to get the object from the cluster: boom")
+		})
+		t.Run("with server-side dry-run", func(t *testing.T) {
+			t.Run("reports create without creating", func(t *testing.T) {
+				// given
+				cl, acl := NewTestSsaApplyClient(t)
+				obj := newConfigMap(map[string]string{"a": "b"})
+
+				// when
+				result, err := acl.ApplyObjectWithResult(context.TODO(), obj, client.ServerSideDryRun(true))
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, client.ApplyActionCreate, result.Action)
+				assert.True(t, result.DryRun)
+				require.True(t, errors.IsNotFound(cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(obj), &corev1.ConfigMap{})))
+			})
+			t.Run("reports the changed fields without updating", func(t *testing.T) {
+				// given
+				cl, acl := NewTestSsaApplyClient(t, newConfigMap(map[string]string{"a": "b"}))
+				var dryRun []string
+				cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+					patchOptions := &runtimeclient.PatchOptions{}
+					patchOptions.ApplyOptions(opts)
+					dryRun = patchOptions.DryRun
+					return test.Patch(ctx, cl, obj, patch, opts...)
+				}
+
+				// when
+				result, err := acl.ApplyObjectWithResult(context.TODO(), newConfigMap(map[string]string{"a": "c", "d": "e"}), client.ServerSideDryRun(true))
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, []string{metav1.DryRunAll}, dryRun)
+				assert.Equal(t, client.ApplyActionUpdate, result.Action)
+				assert.Equal(t, []client.FieldDiff{
+					{Path: "data.a", Live: "b", Desired: "c"},
+					{Path: "data.d", Desired: "e"},
+				}, result.Diff)
+				inCluster := &corev1.ConfigMap{}
+				require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "obj"}, inCluster))
+				assert.Equal(t, map[string]string{"a": "b"}, inCluster.Data)
+			})
+			t.Run("doesn't migrate the managed fields", func(t *testing.T) {
+				// given
+				obj := newConfigMap(map[string]string{"a": "b"})
+				obj.ManagedFields = []metav1.ManagedFieldsEntry{
+					{
+						FieldsType: "FieldsV1",
+						FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:data": {"f:a": {}}}`)},
+						Manager:    strings.Split(rest.DefaultKubernetesUserAgent(), "/")[0],
+						Operation:  metav1.ManagedFieldsOperationUpdate,
+					},
+				}
+				cl, acl := NewTestSsaApplyClient(t, obj)
+				acl.MigrateSSAByDefault = true
+				cl.MockUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+					return fmt.Errorf("should not be called")
+				}
+
+				// when
+				_, err := acl.ApplyObjectWithResult(context.TODO(), newConfigMap(map[string]string{"a": "b"}), client.ServerSideDryRun(true))
+
+				// then
+				require.NoError(t, err)
+			})
+		})
+	})
+	t.Run("ApplyWithResults", func(t *testing.T) {
+		t.Run("reports all objects", func(t *testing.T) {
+			// given
+			_, acl := NewTestSsaApplyClient(t, newConfigMap(map[string]string{"a": "b"}))
+			obj2 := newConfigMap(nil)
+			obj2.Name = "obj2"
+
+			// when
+			results, err := acl.ApplyWithResults(context.TODO(), []runtimeclient.Object{newConfigMap(map[string]string{"a": "b"}), obj2})
+
+			// then
+			require.NoError(t, err)
+			require.Len(t, results, 2)
+			assert.Equal(t, client.ApplyActionNoOp, results[0].Action)
+			assert.Equal(t, client.ApplyActionCreate, results[1].Action)
+		})
+		t.Run("returns results collected before the failure", func(t *testing.T) {
+			// given
+			cl, acl := NewTestSsaApplyClient(t)
+			obj2 := newConfigMap(nil)
+			obj2.Name = "obj2"
+			cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+				if obj.GetName() == "obj2" {
+					return fmt.Errorf("boom")
+				}
+				return test.Patch(ctx, cl, obj, patch, opts...)
+			}
+
+			// when
+			results, err := client.ApplyAllWithResults(context.TODO(), acl, []*corev1.ConfigMap{newConfigMap(nil), obj2})
+
+			// then
+			require.EqualError(t, err, "unable to patch '/v1, Kind=ConfigMap' called 'obj2' in namespace 'default': boom")
+			require.Len(t, results, 1)
+			assert.Equal(t, client.ApplyActionCreate, results[0].Action)
+		})
+	})
+}
+
 func TestEnsureGVK(t *testing.T) {
 	emptyScheme := runtime.NewScheme()
 
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PruneProtectionAnnotationKey opts an object out of the pruning - the objects with the annotation set to "true"
+// are never deleted by the Prune option, even if they are no longer applied
+const PruneProtectionAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "prune-protected"
+
+type pruneConfiguration struct {
+	labels map[string]string
+	gvks   []schema.GroupVersionKind
+}
+
+// Prune enables the pruning of the orphaned objects in Apply, ApplyAll and their variants returning the results.
+//
+// The provided labels identify the set of the applied objects - they are ensured on every applied object
+// (the same way as EnsureLabels does). Once all the objects are applied, the objects of the allowed GVKs that have all these labels
+// but that were not part of the current apply are deleted. If the SetOwnerReference option is used as well, then only the objects
+// controlled by the same owner are deleted. The objects with the PruneProtectionAnnotationKey annotation are never deleted.
+//
+// Only the objects of the provided GVKs are looked up, so nothing is deleted if no GVK is provided. When used together with
+// the ServerSideDryRun option, the deletion is done in the server-side dry-run mode, so nothing is removed from the cluster.
+//
+// Use the PruneInNamespaces option to limit the pruning to the given namespaces and the PruneLegacyLabels option to prune
+// also the objects labelled by the previous versions with a smaller set of labels.
+//
+// The ApplyObject and ApplyObjectWithResult methods only ensure the labels.
+func Prune(labels map[string]string, allowedGVKs ...schema.GroupVersionKind) SSAApplyObjectOption {
+	return func(config *ssaApplyObjectConfiguration) {
+		config.prune = &pruneConfiguration{
+			labels: labels,
+			gvks:   allowedGVKs,
+		}
+	}
+}
+
+// PruneInNamespaces limits the pruning done by the Prune option to the objects in the given namespaces. The cluster-scoped objects
+// of the allowed GVKs are still pruned. The objects in all the namespaces are pruned if not used.
+func PruneInNamespaces(namespaces ...string) SSAApplyObjectOption {
+	return func(config *ssaApplyObjectConfiguration) {
+		config.pruneNamespaces = namespaces
+	}
+}
+
+// PruneLegacyLabels extends the pruning done by the Prune option to the objects that have the given legacy labels but not all
+// the labels of the Prune option - ie. the objects applied before the set of the labels identifying the applied objects was extended.
+// Only the namespaced objects are pruned this way (in the namespaces given by PruneInNamespaces, if used), the cluster-scoped
+// objects with the legacy labels only can't be attributed to the applied set. The objects that have all the label keys of the Prune
+// option, but with different values, belong to a different set and are never pruned this way.
+func PruneLegacyLabels(labels map[string]string) SSAApplyObjectOption {
+	return func(config *ssaApplyObjectConfiguration) {
+		config.pruneLegacyLabels = labels
+	}
+}
+
+type appliedObjectKey struct {
+	groupKind schema.GroupKind
+	namespace string
+	name      string
+}
+
+func newAppliedObjectKey(gvk schema.GroupVersionKind, namespace, name string) appliedObjectKey {
+	return appliedObjectKey{
+		groupKind: gvk.GroupKind(),
+		namespace: namespace,
+		name:      name,
+	}
+}
+
+// prune deletes the objects that match the prune configuration but were not applied. The applied objects are expected to have the GVK set.
+func (c *SSAApplyClient) prune(ctx context.Context, applied []client.Object, config ssaApplyObjectConfiguration) ([]*ApplyResult, error) {
+	if config.prune == nil || len(config.prune.labels) == 0 {
+		return nil, nil
+	}
+
+	appliedKeys := sets.New[appliedObjectKey]()
+	for _, obj := range applied {
+		appliedKeys.Insert(newAppliedObjectKey(obj.GetObjectKind().GroupVersionKind(), obj.GetNamespace(), obj.GetName()))
+	}
+
+	var results []*ApplyResult
+	var errs []error
+	for _, gvk := range config.prune.gvks {
+		candidates, err := c.pruneCandidates(ctx, gvk, config)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for i := range candidates {
+			candidate := &candidates[i]
+			if appliedKeys.Has(newAppliedObjectKey(gvk, candidate.GetNamespace(), candidate.GetName())) ||
+				candidate.GetDeletionTimestamp() != nil ||
+				(config.owner != nil && !metav1.IsControlledBy(candidate, config.owner)) ||
+				(len(config.pruneNamespaces) > 0 && candidate.GetNamespace() != "" && !slices.Contains(config.pruneNamespaces, candidate.GetNamespace())) {
+				continue
+			}
+			if candidate.GetAnnotations()[PruneProtectionAnnotationKey] == "true" {
+				log.Info("kept the object that is no longer applied because it's protected from pruning", "gvk", gvk, "namespace", candidate.GetNamespace(), "name", candidate.GetName())
+				continue
+			}
+			var deleteOptions []client.DeleteOption
+			if config.dryRun {
+				deleteOptions = append(deleteOptions, client.DryRunAll)
+			}
+			if err := c.Client.Delete(ctx, candidate, deleteOptions...); err != nil && !apierrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("unable to prune '%s' called '%s' in namespace '%s': %w", gvk, candidate.GetName(), candidate.GetNamespace(), err))
+				continue
+			}
+			log.Info("pruned the object that is no longer applied", "gvk", gvk, "namespace", candidate.GetNamespace(), "name", candidate.GetName(), "dryRun", config.dryRun)
+			results = append(results, &ApplyResult{
+				Action:    ApplyActionDelete,
+				DryRun:    config.dryRun,
+				GVK:       gvk,
+				Namespace: candidate.GetNamespace(),
+				Name:      candidate.GetName(),
+			})
+		}
+	}
+	return results, utilerrors.NewAggregate(errs)
+}
+
+// pruneCandidates lists the objects of the given GVK with the labels of the Prune option and, if the PruneLegacyLabels option is used,
+// the namespaced objects with the legacy labels that don't have all the label keys of the Prune option
+func (c *SSAApplyClient) pruneCandidates(ctx context.Context, gvk schema.GroupVersionKind, config ssaApplyObjectConfiguration) ([]unstructured.Unstructured, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err := c.Client.List(ctx, list, client.MatchingLabels(config.prune.labels)); err != nil {
+		return nil, fmt.Errorf("unable to list the objects of kind '%s' to prune: %w", gvk, err)
+	}
+	candidates := list.Items
+	if len(config.pruneLegacyLabels) == 0 {
+		return candidates, nil
+	}
+	legacyList := &unstructured.UnstructuredList{}
+	legacyList.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err := c.Client.List(ctx, legacyList, client.MatchingLabels(config.pruneLegacyLabels)); err != nil {
+		return nil, fmt.Errorf("unable to list the objects of kind '%s' with the legacy labels to prune: %w", gvk, err)
+	}
+	for _, legacy := range legacyList.Items {
+		if legacy.GetNamespace() != "" && !hasLabelKeys(legacy.GetLabels(), config.prune.labels) {
+			candidates = append(candidates, legacy)
+		}
+	}
+	return candidates, nil
+}
+
+func hasLabelKeys(labels, expected map[string]string) bool {
+	for key := range expected {
+		if _, found := labels[key]; !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,295 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestSsaClientPrune(t *testing.T) {
+	pruneLabels := map[string]string{"toolchain.dev.openshift.com/provider": "test"}
+	configMapGVK := corev1.SchemeGroupVersion.WithKind("ConfigMap")
+	roleGVK := rbac.SchemeGroupVersion.WithKind("Role")
+
+	newConfigMap := func(name string, labels map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    labels,
+			},
+		}
+	}
+	newRole := func(name string, labels map[string]string) *rbac.Role {
+		return &rbac.Role{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    labels,
+			},
+		}
+	}
+
+	assertExists := func(t *testing.T, cl runtimeclient.Client, obj runtimeclient.Object) {
+		t.Helper()
+		require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(obj), obj.DeepCopyObject().(runtimeclient.Object)))
+	}
+	assertDeleted := func(t *testing.T, cl runtimeclient.Client, obj runtimeclient.Object) {
+		t.Helper()
+		err := cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(obj), obj.DeepCopyObject().(runtimeclient.Object))
+		require.True(t, errors.IsNotFound(err), "expected not found error, got: %v", err)
+	}
+
+	t.Run("deletes the orphaned objects of the allowed kinds", func(t *testing.T) {
+		// given
+		orphanedCm := newConfigMap("orphaned", pruneLabels)
+		orphanedRole := newRole("orphaned", pruneLabels)
+		notAllowedKind := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "default", Labels: pruneLabels}}
+		notLabeled := newConfigMap("not-labeled", nil)
+		cl, acl := NewTestSsaApplyClient(t, orphanedCm, orphanedRole, notAllowedKind, notLabeled)
+
+		// when
+		err := acl.Apply(context.TODO(), []runtimeclient.Object{newConfigMap("applied", nil), newRole("applied", nil)},
+			client.Prune(pruneLabels, configMapGVK, roleGVK))
+
+		// then
+		require.NoError(t, err)
+		assertDeleted(t, cl, orphanedCm)
+		assertDeleted(t, cl, orphanedRole)
+		assertExists(t, cl, notAllowedKind)
+		assertExists(t, cl, notLabeled)
+		applied := &corev1.ConfigMap{}
+		require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKey{Namespace: "default", Name: "applied"}, applied))
+		assert.Equal(t, pruneLabels, applied.Labels) // the labels are ensured on the applied objects
+		assertExists(t, cl, newRole("applied", nil))
+	})
+
+	t.Run("doesn't delete skipped objects", func(t *testing.T) {
+		// given
+		existing := newConfigMap("existing", pruneLabels)
+		cl, acl := NewTestSsaApplyClient(t, existing)
+
+		// when
+		err := acl.Apply(context.TODO(), []runtimeclient.Object{newConfigMap("existing", nil)},
+			client.Prune(pruneLabels, configMapGVK),
+			client.SkipIf(func(runtimeclient.Object) bool {
+				return true
+			}))
+
+		// then
+		require.NoError(t, err)
+		assertExists(t, cl, existing)
+	})
+
+	t.Run("doesn't delete protected objects", func(t *testing.T) {
+		// given
+		protected := newConfigMap("protected", pruneLabels)
+		protected.Annotations = map[string]string{client.PruneProtectionAnnotationKey: "true"}
+		notProtected := newConfigMap("not-protected", pruneLabels)
+		notProtected.Annotations = map[string]string{client.PruneProtectionAnnotationKey: "false"}
+		cl, acl := NewTestSsaApplyClient(t, protected, notProtected)
+
+		// when
+		results, err := acl.ApplyWithResults(context.TODO(), []runtimeclient.Object{newConfigMap("applied", nil)},
+			client.Prune(pruneLabels, configMapGVK))
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, "not-protected", results[1].Name)
+		assertExists(t, cl, protected)
+		assertDeleted(t, cl, notProtected)
+	})
+
+	t.Run("deletes only objects in the given namespaces", func(t *testing.T) {
+		// given
+		inNamespace := newConfigMap("in-namespace", pruneLabels)
+		inOtherNamespace := newConfigMap("in-other-namespace", pruneLabels)
+		inOtherNamespace.Namespace = "other"
+		clusterRole := &rbac.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "cluster-scoped", Labels: pruneLabels}}
+		cl, acl := NewTestSsaApplyClient(t, inNamespace, inOtherNamespace, clusterRole)
+
+		// when
+		err := acl.Apply(context.TODO(), []runtimeclient.Object{newConfigMap("applied", nil)},
+			client.Prune(pruneLabels, configMapGVK, rbac.SchemeGroupVersion.WithKind("ClusterRole")),
+			client.PruneInNamespaces("default"))
+
+		// then
+		require.NoError(t, err)
+		assertDeleted(t, cl, inNamespace)
+		assertExists(t, cl, inOtherNamespace)
+		assertDeleted(t, cl, clusterRole) // the cluster-scoped objects are not limited by the namespaces
+	})
+
+	t.Run("deletes the orphaned objects with the legacy labels", func(t *testing.T) {
+		// given
+		legacyLabels := map[string]string{"toolchain.dev.openshift.com/provider": "test"}
+		extendedLabels := map[string]string{"toolchain.dev.openshift.com/provider": "test", "toolchain.dev.openshift.com/owner": "me"}
+		legacy := newConfigMap("legacy", legacyLabels)
+		legacyInOtherNamespace := newConfigMap("legacy-in-other-namespace", legacyLabels)
+		legacyInOtherNamespace.Namespace = "other"
+		legacyClusterScoped := &rbac.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "legacy", Labels: legacyLabels}}
+		ofOtherSet := newConfigMap("of-other-set", map[string]string{"toolchain.dev.openshift.com/provider": "test", "toolchain.dev.openshift.com/owner": "someone-else"})
+		legacyApplied := newConfigMap("applied", legacyLabels)
+		cl, acl := NewTestSsaApplyClient(t, legacy, legacyInOtherNamespace, legacyClusterScoped, ofOtherSet, legacyApplied)
+
+		// when
+		results, err := client.ApplyAllAndPrune(context.TODO(), acl, []runtimeclient.Object{newConfigMap("applied", nil)},
+			client.Prune(extendedLabels, configMapGVK, rbac.SchemeGroupVersion.WithKind("ClusterRole")),
+			client.PruneInNamespaces("default"),
+			client.PruneLegacyLabels(legacyLabels))
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "legacy", results[0].Name)
+		assertDeleted(t, cl, legacy)
+		assertExists(t, cl, legacyInOtherNamespace)
+		assertExists(t, cl, legacyClusterScoped)
+		assertExists(t, cl, ofOtherSet)
+		applied := &corev1.ConfigMap{}
+		require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(legacyApplied), applied))
+		assert.Equal(t, extendedLabels, applied.Labels)
+	})
+
+	t.Run("deletes only objects controlled by the same owner", func(t *testing.T) {
+		// given
+		owner := newConfigMap("owner", nil)
+		owner.UID = "owner-uid"
+		otherOwnerRef := metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: "other", UID: "other-uid", Controller: ptr.To(true)}
+		ownerRef := metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: "owner", UID: "owner-uid", Controller: ptr.To(true)}
+		ownedOrphan := newConfigMap("owned", pruneLabels)
+		ownedOrphan.OwnerReferences = []metav1.OwnerReference{ownerRef}
+		otherOrphan := newConfigMap("other", pruneLabels)
+		otherOrphan.OwnerReferences = []metav1.OwnerReference{otherOwnerRef}
+		cl, acl := NewTestSsaApplyClient(t, owner, ownedOrphan, otherOrphan)
+
+		// when
+		err := acl.Apply(context.TODO(), []runtimeclient.Object{newConfigMap("applied", nil)},
+			client.Prune(pruneLabels, configMapGVK), client.SetOwnerReference(owner))
+
+		// then
+		require.NoError(t, err)
+		assertDeleted(t, cl, ownedOrphan)
+		assertExists(t, cl, otherOrphan)
+	})
+
+	t.Run("reports the deleted objects", func(t *testing.T) {
+		// given
+		orphaned := newConfigMap("orphaned", pruneLabels)
+		cl, acl := NewTestSsaApplyClient(t, orphaned)
+
+		// when
+		results, err := acl.ApplyWithResults(context.TODO(), []runtimeclient.Object{newConfigMap("applied", nil)},
+			client.Prune(pruneLabels, configMapGVK))
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, client.ApplyActionCreate, results[0].Action)
+		assert.Equal(t, &client.ApplyResult{
+			Action:    client.ApplyActionDelete,
+			GVK:       configMapGVK,
+			Namespace: "default",
+			Name:      "orphaned",
+		}, results[1])
+		assertDeleted(t, cl, orphaned)
+	})
+
+	t.Run("ApplyAllAndPrune reports only the deleted objects", func(t *testing.T) {
+		// given
+		orphaned := newConfigMap("orphaned", pruneLabels)
+		cl, acl := NewTestSsaApplyClient(t, orphaned)
+
+		// when
+		pruned, err := client.ApplyAllAndPrune(context.TODO(), acl, []*corev1.ConfigMap{newConfigMap("applied", nil)},
+			client.Prune(pruneLabels, configMapGVK))
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, pruned, 1)
+		assert.Equal(t, client.ApplyActionDelete, pruned[0].Action)
+		assert.Equal(t, "orphaned", pruned[0].Name)
+		assertDeleted(t, cl, orphaned)
+		assertExists(t, cl, newConfigMap("applied", nil))
+	})
+
+	t.Run("doesn't delete in dry-run mode", func(t *testing.T) {
+		// given
+		orphaned := newConfigMap("orphaned", pruneLabels)
+		cl, acl := NewTestSsaApplyClient(t, orphaned)
+
+		// when
+		results, err := acl.ApplyWithResults(context.TODO(), []runtimeclient.Object{newConfigMap("applied", nil)},
+			client.Prune(pruneLabels, configMapGVK), client.ServerSideDryRun(true))
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, client.ApplyActionDelete, results[1].Action)
+		assert.True(t, results[1].DryRun)
+		assertExists(t, cl, orphaned)
+	})
+
+	t.Run("doesn't prune when apply fails", func(t *testing.T) {
+		// given
+		orphaned := newConfigMap("orphaned", pruneLabels)
+		cl, acl := NewTestSsaApplyClient(t, orphaned)
+		cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+			return fmt.Errorf("boom")
+		}
+
+		// when
+		err := acl.Apply(context.TODO(), []runtimeclient.Object{newConfigMap("applied", nil)}, client.Prune(pruneLabels, configMapGVK))
+
+		// then
+		require.Error(t, err)
+		assertExists(t, cl, orphaned)
+	})
+
+	t.Run("collects the deletion errors", func(t *testing.T) {
+		// given
+		orphaned1 := newConfigMap("orphaned1", pruneLabels)
+		orphaned2 := newConfigMap("orphaned2", pruneLabels)
+		cl, acl := NewTestSsaApplyClient(t, orphaned1, orphaned2)
+		cl.MockDelete = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.DeleteOption) error {
+			if obj.GetName() == "orphaned1" {
+				return fmt.Errorf("boom")
+			}
+			return cl.Client.Delete(ctx, obj, opts...)
+		}
+
+		// when
+		err := acl.Apply(context.TODO(), []runtimeclient.Object{newConfigMap("applied", nil)}, client.Prune(pruneLabels, configMapGVK))
+
+		// then
+		require.EqualError(t, err, "unable to prune '/v1, Kind=ConfigMap' called 'orphaned1' in namespace 'default': boom")
+		assertExists(t, cl, orphaned1)
+		assertDeleted(t, cl, orphaned2)
+	})
+
+	t.Run("ApplyObject only ensures the labels", func(t *testing.T) {
+		// given
+		orphaned := newConfigMap("orphaned", pruneLabels)
+		cl, acl := NewTestSsaApplyClient(t, orphaned)
+		obj := newConfigMap("applied", nil)
+
+		// when
+		err := acl.ApplyObject(context.TODO(), obj, client.Prune(pruneLabels, configMapGVK))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, pruneLabels, obj.Labels)
+		assertExists(t, cl, orphaned)
+	})
+}
@@ -2,18 +2,41 @@ package cluster
 
 import (
 	"sync"
+	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-var clusterCache = toolchainClusterClients{clusters: map[string]*CachedToolchainCluster{}}
+// clusterCache is the default instance used by the package-level functions
+var clusterCache = NewClusterCache()
 
-type toolchainClusterClients struct {
-	sync.RWMutex
+// ClusterCache keeps the CachedToolchainClusters. The cache is filled by a ToolchainClusterService, which is also
+// used for refreshing the cache when a cluster is not found. Multiple instances can be used in one process,
+// eg. when both host and member code run in the same test binary.
+type ClusterCache struct {
+	lock         sync.RWMutex
 	clusters     map[string]*CachedToolchainCluster
 	refreshCache func()
+	refreshing   cacheRefreshing
+	events       clusterSubscribers
+}
+
+// NewClusterCache creates a new empty ClusterCache
+func NewClusterCache(options ...ClusterCacheOption) *ClusterCache {
+	cache := &ClusterCache{
+		clusters:   map[string]*CachedToolchainCluster{},
+		refreshing: cacheRefreshing{now: time.Now},
+	}
+	cache.Configure(options...)
+	return cache
+}
+
+// DefaultClusterCache returns the cache used by the package-level functions (eg. GetCachedToolchainCluster)
+// and by the ToolchainClusterServices created without a cache
+func DefaultClusterCache() *ClusterCache {
+	return clusterCache
 }
 
 type Config struct {
@@ -31,6 +54,9 @@ type Config struct {
 	// then the OwnerClusterName has a name of the member - it has to be same name as the name
 	// that is used for identifying the member in a Host cluster
 	OwnerClusterName string
+	// ProxyURL is the URL of the proxy the RestConfig connects through, it's kept here because the proxy
+	// in the RestConfig is a function which cannot be compared
+	ProxyURL string
 
 	// Labels contains all the labels of the corresponding ToolchainCluster.
 	// They will be used for filtering ToolchainCluster's based on a given list of cluster-role labels.
@@ -44,30 +70,51 @@ type CachedToolchainCluster struct {
 	Client client.Client
 	// ClusterStatus is the cluster result as of the last health check probe.
 	ClusterStatus *toolchainv1alpha1.ToolchainClusterStatus
+	// clients are the lazily created clients of the cluster (eg. the Clientset), they are shared by the versions
+	// of the cached cluster with the same rest config
+	clients *clusterClients
 }
 
-func (c *toolchainClusterClients) addCachedToolchainCluster(cluster *CachedToolchainCluster) {
-	c.Lock()
-	defer c.Unlock()
+func (c *ClusterCache) addCachedToolchainCluster(cluster *CachedToolchainCluster) {
+	c.refreshing.forgetMissing(cluster.Name)
+	c.lock.Lock()
+	old := c.clusters[cluster.Name]
+	if old != nil && old.clients != cluster.clients {
+		old.clients.invalidate()
+	}
+	events := clusterEvents(old, cluster)
 	c.clusters[cluster.Name] = cluster
+	c.events.dispatchLock.Lock()
+	defer c.events.dispatchLock.Unlock()
+	c.lock.Unlock()
+	c.events.dispatch(events)
 }
 
-func (c *toolchainClusterClients) deleteCachedToolchainCluster(name string) {
-	c.Lock()
-	defer c.Unlock()
+func (c *ClusterCache) deleteCachedToolchainCluster(name string) {
+	c.lock.Lock()
+	old := c.clusters[name]
+	if old != nil {
+		old.clients.invalidate()
+	}
+	events := clusterEvents(old, nil)
 	delete(c.clusters, name)
+	c.events.dispatchLock.Lock()
+	defer c.events.dispatchLock.Unlock()
+	c.lock.Unlock()
+	c.events.dispatch(events)
 }
 
-func (c *toolchainClusterClients) getCachedToolchainCluster(name string, canRefreshCache bool) (*CachedToolchainCluster, bool) {
-	c.RLock()
-	defer c.RUnlock()
-	_, ok := c.clusters[name]
-	if !ok && canRefreshCache && c.refreshCache != nil {
-		c.RUnlock()
-		c.refreshCache()
-		c.RLock()
-	}
+func (c *ClusterCache) getCachedToolchainCluster(name string, canRefreshCache bool) (*CachedToolchainCluster, bool) {
+	c.lock.RLock()
 	cluster, ok := c.clusters[name]
+	c.lock.RUnlock()
+	if ok || !canRefreshCache {
+		return cluster, ok
+	}
+	c.refresh(name)
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	cluster, ok = c.clusters[name]
 	return cluster, ok
 }
 
@@ -79,11 +126,13 @@ var Ready Condition = func(cluster *CachedToolchainCluster) bool {
 	return IsReady(cluster.ClusterStatus)
 }
 
-func (c *toolchainClusterClients) getCachedToolchainClusters(conditions ...Condition) []*CachedToolchainCluster {
-	c.RLock()
-	defer c.RUnlock()
+func (c *ClusterCache) getCachedToolchainClusters(conditions ...Condition) []*CachedToolchainCluster {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
 	return Filter(c.clusters, conditions...)
 }
+
+// Filter returns the clusters matching all the given conditions, sorted by name
 func Filter(clusters map[string]*CachedToolchainCluster, conditions ...Condition) []*CachedToolchainCluster {
 	filteredClusters := make([]*CachedToolchainCluster, 0, len(clusters))
 clusters:
@@ -95,12 +144,26 @@ clusters:
 		}
 		filteredClusters = append(filteredClusters, cluster)
 	}
+	SortClusters(filteredClusters)
 	return filteredClusters
 }
 
 // GetCachedToolchainCluster returns a kube client for the cluster (with the given name) and info if the client exists
 func GetCachedToolchainCluster(name string) (*CachedToolchainCluster, bool) {
-	return clusterCache.getCachedToolchainCluster(name, true)
+	return clusterCache.GetCachedToolchainCluster(name)
+}
+
+// GetCachedToolchainCluster returns a kube client for the cluster (with the given name) and info if the client exists.
+// The cache is refreshed if the cluster is not found.
+func (c *ClusterCache) GetCachedToolchainCluster(name string) (*CachedToolchainCluster, bool) {
+	return c.getCachedToolchainCluster(name, true)
+}
+
+// GetClusterFunc returns a function retrieving the cluster with the given name from the cache (eg. for the status helpers)
+func (c *ClusterCache) GetClusterFunc(name string) func() (*CachedToolchainCluster, bool) {
+	return func() (*CachedToolchainCluster, bool) {
+		return c.GetCachedToolchainCluster(name)
+	}
 }
 
 // GetHostClusterFunc a func that returns the Host cluster from the cache,
@@ -113,15 +176,20 @@ var HostCluster GetHostClusterFunc = GetHostCluster
 // GetHostCluster returns the kube client for the host cluster from the cache of the clusters
 // and info if such a client exists
 func GetHostCluster() (*CachedToolchainCluster, bool) {
-	clusters := clusterCache.getCachedToolchainClusters()
+	return clusterCache.GetHostCluster()
+}
+
+// GetHostCluster returns the kube client for the host cluster from the cache and info if such a client exists.
+// The host cluster is selected by SelectHostCluster. If the selection fails (eg. when there are multiple clusters without
+// the Host and HostStandBy role labels), then the first cluster in the cache (ordered by name) is returned, so the caches
+// without the role labels keep working.
+func (c *ClusterCache) GetHostCluster() (*CachedToolchainCluster, bool) {
+	if host, err := c.SelectHostCluster(); err == nil {
+		return host, true
+	}
+	clusters := c.getCachedToolchainClusters()
 	if len(clusters) == 0 {
-		if clusterCache.refreshCache != nil {
-			clusterCache.refreshCache()
-		}
-		clusters = clusterCache.getCachedToolchainClusters()
-		if len(clusters) == 0 {
-			return nil, false
-		}
+		return nil, false
 	}
 	return clusters[0], true
 }
@@ -134,12 +202,16 @@ var MemberClusters GetMemberClustersFunc = GetMemberClusters
 
 // GetMemberClusters returns the kube clients for the host clusters from the cache of the clusters
 func GetMemberClusters(conditions ...Condition) []*CachedToolchainCluster {
-	clusters := clusterCache.getCachedToolchainClusters(conditions...)
+	return clusterCache.GetMemberClusters(conditions...)
+}
+
+// GetMemberClusters returns the kube clients for the member clusters from the cache matching all the given conditions.
+// The clusters are sorted by name, use SortClusters for a different order.
+func (c *ClusterCache) GetMemberClusters(conditions ...Condition) []*CachedToolchainCluster {
+	clusters := c.getCachedToolchainClusters(conditions...)
 	if len(clusters) == 0 {
-		if clusterCache.refreshCache != nil {
-			clusterCache.refreshCache()
-		}
-		clusters = clusterCache.getCachedToolchainClusters(conditions...)
+		c.refresh("")
+		clusters = c.getCachedToolchainClusters(conditions...)
 	}
 	return clusters
 }
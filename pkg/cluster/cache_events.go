@@ -0,0 +1,147 @@
+package cluster
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// ClusterEventType is the type of the change of a cached cluster
+type ClusterEventType string
+
+const (
+	// ClusterAdded is emitted when a cluster is added to the cache
+	ClusterAdded ClusterEventType = "Added"
+	// ClusterUpdated is emitted when a cached cluster is replaced with a new version (eg. with a new status or rest config)
+	ClusterUpdated ClusterEventType = "Updated"
+	// ClusterRemoved is emitted when a cluster is removed from the cache
+	ClusterRemoved ClusterEventType = "Removed"
+	// ClusterReadinessChanged is emitted (together with ClusterUpdated) when the Ready condition of a cached cluster changed
+	ClusterReadinessChanged ClusterEventType = "ReadinessChanged"
+)
+
+// ClusterEvent describes a change of a cached cluster. The Old and New fields are snapshots of the cluster
+// before and after the change - Old is nil for ClusterAdded and New is nil for ClusterRemoved.
+type ClusterEvent struct {
+	Type ClusterEventType
+	Old  *CachedToolchainCluster
+	New  *CachedToolchainCluster
+}
+
+// ClusterEventHandler handles the events of the cluster cache. The handlers are called synchronously, in the order of
+// the changes of the cache, so they should return quickly. The handlers must not modify the cache - not even indirectly,
+// by looking up an unknown cluster, which triggers a refresh of the cache.
+type ClusterEventHandler func(event ClusterEvent)
+
+type clusterSubscriber struct {
+	handler ClusterEventHandler
+	types   map[ClusterEventType]bool
+}
+
+type clusterSubscribers struct {
+	sync.RWMutex
+	nextID      int
+	subscribers map[int]clusterSubscriber
+	// makes sure that the events are delivered in the same order as the changes were done
+	dispatchLock sync.Mutex
+}
+
+// Subscribe registers the handler for the events of the given types emitted by the default cluster cache. If no type is given,
+// the handler receives all the events. The returned function unsubscribes the handler.
+func Subscribe(handler ClusterEventHandler, types ...ClusterEventType) func() {
+	return clusterCache.Subscribe(handler, types...)
+}
+
+// Subscribe registers the handler for the events of the given types emitted by this cache. If no type is given,
+// the handler receives all the events. The returned function unsubscribes the handler.
+func (c *ClusterCache) Subscribe(handler ClusterEventHandler, types ...ClusterEventType) func() {
+	c.events.Lock()
+	defer c.events.Unlock()
+	if c.events.subscribers == nil {
+		c.events.subscribers = map[int]clusterSubscriber{}
+	}
+	subscriber := clusterSubscriber{handler: handler}
+	if len(types) > 0 {
+		subscriber.types = map[ClusterEventType]bool{}
+		for _, eventType := range types {
+			subscriber.types[eventType] = true
+		}
+	}
+	id := c.events.nextID
+	c.events.nextID++
+	c.events.subscribers[id] = subscriber
+	return func() {
+		c.events.Lock()
+		defer c.events.Unlock()
+		delete(c.events.subscribers, id)
+	}
+}
+
+// clusterEvents returns the events describing the change from the old to the new version of the cluster.
+// No event is returned if the cluster didn't change.
+func clusterEvents(old, new *CachedToolchainCluster) []ClusterEvent {
+	switch {
+	case old == nil && new == nil:
+		return nil
+	case old == nil:
+		return []ClusterEvent{{Type: ClusterAdded, New: new.snapshot()}}
+	case new == nil:
+		return []ClusterEvent{{Type: ClusterRemoved, Old: old.snapshot()}}
+	}
+	if sameConfig(old.Config, new.Config) && reflect.DeepEqual(old.ClusterStatus, new.ClusterStatus) {
+		// the client is replaced only when the config changes, so there is nothing new
+		return nil
+	}
+	oldSnapshot, newSnapshot := old.snapshot(), new.snapshot()
+	events := []ClusterEvent{{Type: ClusterUpdated, Old: oldSnapshot, New: newSnapshot}}
+	if IsReady(oldSnapshot.ClusterStatus) != IsReady(newSnapshot.ClusterStatus) {
+		events = append(events, ClusterEvent{Type: ClusterReadinessChanged, Old: oldSnapshot, New: newSnapshot})
+	}
+	return events
+}
+
+// dispatch delivers the events to the subscribers. It's expected to be called with the dispatchLock acquired.
+func (s *clusterSubscribers) dispatch(events []ClusterEvent) {
+	if len(events) == 0 {
+		return
+	}
+	s.RLock()
+	ids := make([]int, 0, len(s.subscribers))
+	for id := range s.subscribers {
+		ids = append(ids, id)
+	}
+	// in the order of the subscription
+	sort.Ints(ids)
+	subscribers := make([]clusterSubscriber, 0, len(ids))
+	for _, id := range ids {
+		subscribers = append(subscribers, s.subscribers[id])
+	}
+	s.RUnlock()
+
+	for _, event := range events {
+		for _, subscriber := range subscribers {
+			if subscriber.types == nil || subscriber.types[event.Type] {
+				subscriber.handler(event)
+			}
+		}
+	}
+}
+
+// snapshot returns a copy of the cached cluster, so the handlers see the state at the time of the change
+func (c *CachedToolchainCluster) snapshot() *CachedToolchainCluster {
+	snapshot := *c
+	if c.Config != nil {
+		config := *c.Config
+		if c.Config.Labels != nil {
+			config.Labels = make(map[string]string, len(c.Config.Labels))
+			for key, value := range c.Config.Labels {
+				config.Labels[key] = value
+			}
+		}
+		snapshot.Config = &config
+	}
+	if c.ClusterStatus != nil {
+		snapshot.ClusterStatus = c.ClusterStatus.DeepCopy()
+	}
+	return &snapshot
+}
@@ -0,0 +1,204 @@
+package cluster
+
+import (
+	"sync"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+type eventRecorder struct {
+	sync.Mutex
+	events []ClusterEvent
+}
+
+func (r *eventRecorder) handle(event ClusterEvent) {
+	r.Lock()
+	defer r.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *eventRecorder) types() []ClusterEventType {
+	r.Lock()
+	defer r.Unlock()
+	types := make([]ClusterEventType, 0, len(r.events))
+	for _, event := range r.events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestSubscribe(t *testing.T) {
+	t.Run("all events", func(t *testing.T) {
+		// given
+		defer resetClusterCache()
+		recorder := &eventRecorder{}
+		Subscribe(recorder.handle)
+		notReadyCluster := newTestCachedToolchainCluster(t, "member", notReady)
+		readyCluster := newTestCachedToolchainCluster(t, "member", ready)
+		relabeledCluster := newTestCachedToolchainCluster(t, "member", ready, func(c *CachedToolchainCluster) {
+			c.Labels = map[string]string{"cluster-role.toolchain.dev.openshift.com/tenant": ""}
+		})
+
+		// when
+		clusterCache.addCachedToolchainCluster(notReadyCluster)
+		clusterCache.addCachedToolchainCluster(readyCluster)
+		clusterCache.addCachedToolchainCluster(relabeledCluster)
+		clusterCache.deleteCachedToolchainCluster("member")
+
+		// then
+		assert.Equal(t, []ClusterEventType{ClusterAdded, ClusterUpdated, ClusterReadinessChanged, ClusterUpdated, ClusterRemoved}, recorder.types())
+		added := recorder.events[0]
+		assert.Nil(t, added.Old)
+		assert.Equal(t, "member", added.New.Name)
+		assert.False(t, IsReady(added.New.ClusterStatus))
+		readinessChanged := recorder.events[2]
+		assert.False(t, IsReady(readinessChanged.Old.ClusterStatus))
+		assert.True(t, IsReady(readinessChanged.New.ClusterStatus))
+		relabeled := recorder.events[3]
+		assert.Empty(t, relabeled.Old.Labels)
+		assert.Equal(t, map[string]string{"cluster-role.toolchain.dev.openshift.com/tenant": ""}, relabeled.New.Labels)
+		removed := recorder.events[4]
+		assert.Equal(t, "member", removed.Old.Name)
+		assert.Nil(t, removed.New)
+	})
+
+	t.Run("unchanged cluster and unknown cluster don't emit any event", func(t *testing.T) {
+		// given
+		defer resetClusterCache()
+		recorder := &eventRecorder{}
+		clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member", ready))
+		Subscribe(recorder.handle)
+
+		// when
+		clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member", ready))
+		clusterCache.deleteCachedToolchainCluster("unknown")
+
+		// then
+		assert.Empty(t, recorder.types())
+	})
+
+	t.Run("only the selected types", func(t *testing.T) {
+		// given
+		defer resetClusterCache()
+		recorder := &eventRecorder{}
+		Subscribe(recorder.handle, ClusterAdded, ClusterReadinessChanged)
+
+		// when
+		clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member", ready))
+		clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member", notReady))
+		clusterCache.deleteCachedToolchainCluster("member")
+
+		// then
+		assert.Equal(t, []ClusterEventType{ClusterAdded, ClusterReadinessChanged}, recorder.types())
+	})
+
+	t.Run("snapshots are not affected by later changes", func(t *testing.T) {
+		// given
+		defer resetClusterCache()
+		recorder := &eventRecorder{}
+		Subscribe(recorder.handle)
+		cachedCluster := newTestCachedToolchainCluster(t, "member", ready)
+		clusterCache.addCachedToolchainCluster(cachedCluster)
+
+		// when
+		cachedCluster.ClusterStatus.Conditions[0].Status = v1.ConditionFalse
+
+		// then
+		require.Len(t, recorder.events, 1)
+		assert.True(t, IsReady(recorder.events[0].New.ClusterStatus))
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		// given
+		defer resetClusterCache()
+		first, second := &eventRecorder{}, &eventRecorder{}
+		unsubscribe := Subscribe(first.handle)
+		Subscribe(second.handle)
+		clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member", ready))
+
+		// when
+		unsubscribe()
+		clusterCache.deleteCachedToolchainCluster("member")
+
+		// then
+		assert.Equal(t, []ClusterEventType{ClusterAdded}, first.types())
+		assert.Equal(t, []ClusterEventType{ClusterAdded, ClusterRemoved}, second.types())
+	})
+
+	t.Run("handler can read the cache", func(t *testing.T) {
+		// given
+		defer resetClusterCache()
+		var found bool
+		Subscribe(func(event ClusterEvent) {
+			_, found = clusterCache.getCachedToolchainCluster(event.New.Name, false)
+		}, ClusterAdded)
+
+		// when
+		clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member", ready))
+
+		// then
+		assert.True(t, found)
+	})
+
+	t.Run("events are delivered in order when modified in parallel", func(t *testing.T) {
+		// given
+		defer resetClusterCache()
+		var lock sync.Mutex
+		present := map[string]bool{}
+		var inconsistent []string
+		Subscribe(func(event ClusterEvent) {
+			lock.Lock()
+			defer lock.Unlock()
+			switch event.Type {
+			case ClusterAdded:
+				if present[event.New.Name] {
+					inconsistent = append(inconsistent, "added twice: "+event.New.Name)
+				}
+				present[event.New.Name] = true
+			case ClusterRemoved:
+				if !present[event.Old.Name] {
+					inconsistent = append(inconsistent, "removed before added: "+event.Old.Name)
+				}
+				present[event.Old.Name] = false
+			}
+		})
+		var wg sync.WaitGroup
+		cachedCluster := newTestCachedToolchainCluster(t, "member", ready)
+
+		// when
+		for i := 0; i < 50; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				clusterCache.addCachedToolchainCluster(cachedCluster)
+			}()
+			go func() {
+				defer wg.Done()
+				clusterCache.deleteCachedToolchainCluster("member")
+			}()
+		}
+		wg.Wait()
+
+		// then
+		assert.Empty(t, inconsistent)
+	})
+}
+
+func TestClusterEventsOfStatusWithoutReadyCondition(t *testing.T) {
+	// given
+	old := newTestCachedToolchainCluster(t, "member")
+	updated := newTestCachedToolchainCluster(t, "member", func(c *CachedToolchainCluster) {
+		c.ClusterStatus.Conditions = []toolchainv1alpha1.Condition{{Type: "Other", Status: v1.ConditionTrue}}
+	})
+
+	// when
+	events := clusterEvents(old, updated)
+
+	// then
+	require.Len(t, events, 1)
+	assert.Equal(t, ClusterUpdated, events[0].Type)
+}
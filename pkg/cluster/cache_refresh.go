@@ -0,0 +1,153 @@
+package cluster
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	refreshSkippedInFlight    = "in_flight"
+	refreshSkippedMinInterval = "min_interval"
+	refreshSkippedMissing     = "known_missing"
+)
+
+var (
+	// ClusterCacheRefreshes counts the refreshes of the cluster caches, ie. the listings of all the ToolchainClusters
+	ClusterCacheRefreshes = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sandbox_cluster_cache_refreshes_total",
+		Help: "Number of refreshes of the cluster cache",
+	})
+	// ClusterCacheRefreshesSkipped counts the refreshes of the cluster caches that were skipped, by the reason
+	// (in_flight, min_interval or known_missing)
+	ClusterCacheRefreshesSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sandbox_cluster_cache_refreshes_skipped_total",
+		Help: "Number of refreshes of the cluster cache that were skipped, by the reason",
+	}, []string{"reason"})
+	// ClusterCacheRefreshDuration observes the duration of the refreshes of the cluster caches
+	ClusterCacheRefreshDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "sandbox_cluster_cache_refresh_duration_seconds",
+		Help:    "Duration of the refreshes of the cluster cache",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+// RegisterMetrics registers the metrics of the cluster caches in the given registry (eg. the controller-runtime metrics.Registry)
+func RegisterMetrics(registry prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{ClusterCacheRefreshes, ClusterCacheRefreshesSkipped, ClusterCacheRefreshDuration} {
+		if err := registry.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClusterCacheOption configures the ClusterCache
+type ClusterCacheOption func(cache *ClusterCache)
+
+// Configure applies the options to the cache, eg. to the DefaultClusterCache when the operator starts
+func (c *ClusterCache) Configure(options ...ClusterCacheOption) {
+	c.refreshing.lock.Lock()
+	defer c.refreshing.lock.Unlock()
+	for _, apply := range options {
+		apply(c)
+	}
+}
+
+// RefreshMinInterval sets the minimal duration between two refreshes of the cache. The lookups that miss the cache
+// within the interval after the last refresh don't trigger a new one. The limit is disabled by default, so a cluster
+// that is created right after a failed lookup is found by the next lookup.
+func RefreshMinInterval(interval time.Duration) ClusterCacheOption {
+	return func(cache *ClusterCache) {
+		cache.refreshing.minInterval = interval
+	}
+}
+
+// MissingClusterTTL sets the duration for which a cluster that was not found by a refresh is considered missing. The lookups
+// of such a cluster don't trigger any refresh until the duration elapses or until the cluster is added to the cache.
+// The negative caching is disabled by default.
+func MissingClusterTTL(ttl time.Duration) ClusterCacheOption {
+	return func(cache *ClusterCache) {
+		cache.refreshing.missingTTL = ttl
+	}
+}
+
+// cacheRefreshing protects the API server from the storms of the refreshes triggered by the lookups missing the cache.
+// The concurrent refreshes are always deduplicated, the other protections have to be enabled by the options.
+type cacheRefreshing struct {
+	lock        sync.Mutex
+	minInterval time.Duration
+	missingTTL  time.Duration
+	// inFlight is closed when the running refresh finishes, it's nil when no refresh is running
+	inFlight    chan struct{}
+	lastRefresh time.Time
+	// missing contains the names of the clusters that were not found by a refresh and the time of the refresh
+	missing map[string]time.Time
+	now     func() time.Time
+}
+
+// refresh refreshes the cache unless the refresh is not needed - the concurrent calls wait for a single refresh,
+// the refreshes are not done more often than the minimal interval and the clusters that are known to be missing
+// don't trigger any refresh. The name is the name of the looked up cluster, empty if any cluster is looked up.
+func (c *ClusterCache) refresh(name string) {
+	if c.refreshCache == nil {
+		return
+	}
+	r := &c.refreshing
+	r.lock.Lock()
+	now := r.now()
+	if missingSince, found := r.missing[name]; found && name != "" && now.Sub(missingSince) < r.missingTTL {
+		r.lock.Unlock()
+		ClusterCacheRefreshesSkipped.WithLabelValues(refreshSkippedMissing).Inc()
+		return
+	}
+	if inFlight := r.inFlight; inFlight != nil {
+		r.lock.Unlock()
+		ClusterCacheRefreshesSkipped.WithLabelValues(refreshSkippedInFlight).Inc()
+		<-inFlight
+		return
+	}
+	if !r.lastRefresh.IsZero() && now.Sub(r.lastRefresh) < r.minInterval {
+		r.lock.Unlock()
+		ClusterCacheRefreshesSkipped.WithLabelValues(refreshSkippedMinInterval).Inc()
+		return
+	}
+	done := make(chan struct{})
+	r.inFlight = done
+	r.lock.Unlock()
+	// the waiting lookups are released and the next refresh is allowed even if the refresh panics
+	defer func() {
+		r.lock.Lock()
+		r.inFlight = nil
+		r.lock.Unlock()
+		close(done)
+	}()
+
+	ClusterCacheRefreshes.Inc()
+	c.refreshCache()
+	ClusterCacheRefreshDuration.Observe(r.now().Sub(now).Seconds())
+
+	_, found := c.getCachedToolchainCluster(name, false)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.lastRefresh = r.now()
+	for missingName, missingSince := range r.missing {
+		if r.lastRefresh.Sub(missingSince) >= r.missingTTL {
+			delete(r.missing, missingName)
+		}
+	}
+	if name != "" && !found && r.missingTTL > 0 {
+		if r.missing == nil {
+			r.missing = map[string]time.Time{}
+		}
+		r.missing[name] = r.lastRefresh
+	}
+}
+
+// forgetMissing removes the cluster from the clusters known to be missing
+func (r *cacheRefreshing) forgetMissing(name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.missing, name)
+}
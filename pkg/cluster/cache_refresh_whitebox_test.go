@@ -0,0 +1,276 @@
+package cluster
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/test/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	promclientgo "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheRefreshing(t *testing.T) {
+	newCache := func(now *time.Time, options ...ClusterCacheOption) (*ClusterCache, *atomic.Int32) {
+		cache := NewClusterCache(options...)
+		cache.refreshing.now = func() time.Time {
+			return *now
+		}
+		calls := &atomic.Int32{}
+		cache.refreshCache = func() {
+			calls.Add(1)
+		}
+		return cache, calls
+	}
+
+	t.Run("concurrent refreshes are deduplicated", func(t *testing.T) {
+		// given
+		cache := NewClusterCache()
+		release := make(chan struct{})
+		calls := &atomic.Int32{}
+		cache.refreshCache = func() {
+			calls.Add(1)
+			<-release
+		}
+		waiting := metrics.GetCounterInt(ClusterCacheRefreshesSkipped.WithLabelValues(refreshSkippedInFlight))
+		var wg sync.WaitGroup
+
+		// when
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.GetCachedToolchainCluster("deleted")
+		}()
+		require.Eventually(t, func() bool {
+			return calls.Load() == 1
+		}, 5*time.Second, time.Millisecond)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				cache.GetCachedToolchainCluster("deleted")
+			}()
+		}
+		require.Eventually(t, func() bool {
+			return metrics.GetCounterInt(ClusterCacheRefreshesSkipped.WithLabelValues(refreshSkippedInFlight)) == waiting+10
+		}, 5*time.Second, time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		// then
+		assert.Equal(t, int32(1), calls.Load())
+
+		t.Run("next lookup refreshes the cache again", func(t *testing.T) {
+			// when
+			cache.GetCachedToolchainCluster("deleted")
+
+			// then
+			assert.Equal(t, int32(2), calls.Load())
+		})
+	})
+
+	t.Run("panicking refresh releases the waiting lookups", func(t *testing.T) {
+		// given
+		cache := NewClusterCache()
+		release := make(chan struct{})
+		calls := &atomic.Int32{}
+		cache.refreshCache = func() {
+			if calls.Add(1) == 1 {
+				<-release
+				panic("refresh failed")
+			}
+		}
+		waiting := metrics.GetCounterInt(ClusterCacheRefreshesSkipped.WithLabelValues(refreshSkippedInFlight))
+		panicked := make(chan any, 1)
+		go func() {
+			defer func() {
+				panicked <- recover()
+			}()
+			cache.GetCachedToolchainCluster("deleted")
+		}()
+		require.Eventually(t, func() bool {
+			return calls.Load() == 1
+		}, 5*time.Second, time.Millisecond)
+		waited := make(chan struct{})
+		go func() {
+			defer close(waited)
+			cache.GetCachedToolchainCluster("deleted")
+		}()
+		require.Eventually(t, func() bool {
+			return metrics.GetCounterInt(ClusterCacheRefreshesSkipped.WithLabelValues(refreshSkippedInFlight)) == waiting+1
+		}, 5*time.Second, time.Millisecond)
+
+		// when
+		close(release)
+
+		// then
+		assert.Equal(t, "refresh failed", <-panicked)
+		select {
+		case <-waited:
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "the waiting lookup was not released")
+		}
+		assert.Nil(t, cache.refreshing.inFlight)
+
+		t.Run("next lookup refreshes the cache again", func(t *testing.T) {
+			// when
+			cache.GetCachedToolchainCluster("deleted")
+
+			// then
+			assert.Equal(t, int32(2), calls.Load())
+		})
+	})
+
+	t.Run("refreshes are not done more often than the min interval", func(t *testing.T) {
+		// given
+		now := time.Now()
+		cache, calls := newCache(&now, RefreshMinInterval(time.Minute))
+		refreshes := metrics.GetCounterInt(ClusterCacheRefreshes)
+		skipped := metrics.GetCounterInt(ClusterCacheRefreshesSkipped.WithLabelValues(refreshSkippedMinInterval))
+
+		// when
+		cache.GetCachedToolchainCluster("first")
+		cache.GetCachedToolchainCluster("second")
+		cache.GetMemberClusters()
+		_, err := cache.SelectHostCluster()
+
+		// then
+		require.ErrorIs(t, err, ErrHostClusterNotFound)
+		assert.Equal(t, int32(1), calls.Load())
+		metrics.AssertCounterEqualsInt(t, refreshes+1, ClusterCacheRefreshes)
+		metrics.AssertCounterEqualsInt(t, skipped+3, ClusterCacheRefreshesSkipped.WithLabelValues(refreshSkippedMinInterval))
+
+		t.Run("refreshed after the interval", func(t *testing.T) {
+			// given
+			now = now.Add(time.Minute)
+
+			// when
+			cache.GetCachedToolchainCluster("second")
+
+			// then
+			assert.Equal(t, int32(2), calls.Load())
+		})
+	})
+
+	t.Run("duration of the refresh is measured by the clock of the cache", func(t *testing.T) {
+		// given
+		now := time.Now()
+		cache, _ := newCache(&now)
+		cache.refreshCache = func() {
+			now = now.Add(3 * time.Second)
+		}
+		below := cumulativeCount(t, ClusterCacheRefreshDuration, 2.5)
+		within := cumulativeCount(t, ClusterCacheRefreshDuration, 5)
+
+		// when
+		cache.GetCachedToolchainCluster("first")
+
+		// then
+		metrics.AssertHistogramBucketEquals(t, float64(below), 2.5, ClusterCacheRefreshDuration)
+		metrics.AssertHistogramBucketEquals(t, float64(within+1), 5, ClusterCacheRefreshDuration)
+	})
+
+	t.Run("missing cluster doesn't trigger refresh until the TTL elapses", func(t *testing.T) {
+		// given
+		now := time.Now()
+		cache, calls := newCache(&now, MissingClusterTTL(time.Minute))
+		skipped := metrics.GetCounterInt(ClusterCacheRefreshesSkipped.WithLabelValues(refreshSkippedMissing))
+
+		// when
+		_, found := cache.GetCachedToolchainCluster("deleted")
+		_, foundAgain := cache.GetCachedToolchainCluster("deleted")
+
+		// then
+		assert.False(t, found)
+		assert.False(t, foundAgain)
+		assert.Equal(t, int32(1), calls.Load())
+		metrics.AssertCounterEqualsInt(t, skipped+1, ClusterCacheRefreshesSkipped.WithLabelValues(refreshSkippedMissing))
+
+		t.Run("other clusters trigger refresh", func(t *testing.T) {
+			// when
+			cache.GetCachedToolchainCluster("other")
+
+			// then
+			assert.Equal(t, int32(2), calls.Load())
+		})
+
+		t.Run("refreshed after the TTL", func(t *testing.T) {
+			// given
+			now = now.Add(time.Minute)
+
+			// when
+			cache.GetCachedToolchainCluster("deleted")
+
+			// then
+			assert.Equal(t, int32(3), calls.Load())
+		})
+
+		t.Run("added cluster is found", func(t *testing.T) {
+			// given
+			cache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "deleted", ready))
+
+			// when
+			_, found := cache.GetCachedToolchainCluster("deleted")
+
+			// then
+			assert.True(t, found)
+			assert.Empty(t, cache.refreshing.missing)
+		})
+
+		t.Run("expired missing clusters are removed", func(t *testing.T) {
+			// given
+			cache.GetCachedToolchainCluster("gone")
+			require.Contains(t, cache.refreshing.missing, "gone")
+			now = now.Add(time.Minute)
+
+			// when
+			cache.GetCachedToolchainCluster("another")
+
+			// then
+			assert.NotContains(t, cache.refreshing.missing, "gone")
+			assert.Contains(t, cache.refreshing.missing, "another")
+		})
+	})
+
+	t.Run("configure the cache", func(t *testing.T) {
+		// given
+		now := time.Now()
+		cache, calls := newCache(&now)
+
+		// when
+		cache.Configure(RefreshMinInterval(time.Minute), MissingClusterTTL(time.Hour))
+
+		// then
+		cache.GetCachedToolchainCluster("deleted")
+		cache.GetCachedToolchainCluster("other")
+		assert.Equal(t, int32(1), calls.Load())
+		assert.Equal(t, time.Hour, cache.refreshing.missingTTL)
+	})
+}
+
+func TestRegisterClusterCacheMetrics(t *testing.T) {
+	// given
+	registry := prometheus.NewRegistry()
+
+	// when
+	err := RegisterMetrics(registry)
+
+	// then
+	require.NoError(t, err)
+	require.Error(t, RegisterMetrics(registry))
+}
+
+func cumulativeCount(t *testing.T, h prometheus.Histogram, bucket float64) uint64 {
+	metric := promclientgo.Metric{}
+	require.NoError(t, h.Write(&metric))
+	for _, buck := range metric.GetHistogram().GetBucket() {
+		if buck.GetUpperBound() == bucket {
+			return buck.GetCumulativeCount()
+		}
+	}
+	require.Failf(t, "bucket not found", "the bucket with the upper limit '%v' wasn't found", bucket)
+	return 0
+}
@@ -0,0 +1,89 @@
+package cluster_test
+
+import (
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestClusterCacheInstances(t *testing.T) {
+	// given
+	defer gock.Off()
+	status := test.NewClusterStatus(toolchainv1alpha1.ConditionReady, corev1.ConditionTrue)
+	member, memberSecret := test.NewToolchainCluster(t, "member", test.HostOperatorNs, test.MemberOperatorNs, "member-secret", status, false)
+	host, hostSecret := test.NewToolchainCluster(t, "host", test.MemberOperatorNs, test.HostOperatorNs, "host-secret", status, false)
+	hostClient := test.NewFakeClient(t, member, memberSecret)
+	memberClient := test.NewFakeClient(t, host, hostSecret)
+	newClient := func(_ *rest.Config, _ client.Options) (client.Client, error) {
+		return test.NewFakeClient(t), nil
+	}
+	hostCache, memberCache := cluster.NewClusterCache(), cluster.NewClusterCache()
+	// the cache of the host operator contains the members and the cache of the member operator contains the host
+	hostService := cluster.NewToolchainClusterServiceWithClient(hostClient, logf.Log, test.HostOperatorNs, 0, newClient, cluster.WithClusterCache(hostCache))
+	memberService := cluster.NewToolchainClusterServiceWithClient(memberClient, logf.Log, test.MemberOperatorNs, 0, newClient, cluster.WithClusterCache(memberCache))
+
+	t.Run("clusters are added only to the cache of the service", func(t *testing.T) {
+		// when
+		require.NoError(t, hostService.AddOrUpdateToolchainCluster(member))
+		require.NoError(t, memberService.AddOrUpdateToolchainCluster(host))
+
+		// then
+		assert.Same(t, hostCache, hostService.Cache())
+		memberClusters := hostCache.GetMemberClusters()
+		require.Len(t, memberClusters, 1)
+		assert.Equal(t, "member", memberClusters[0].Name)
+		hostCluster, found := memberCache.GetHostCluster()
+		require.True(t, found)
+		assert.Equal(t, "host", hostCluster.Name)
+		_, found = hostCache.GetClusterFunc("host")()
+		assert.False(t, found)
+		_, found = cluster.GetCachedToolchainCluster("member")
+		assert.False(t, found)
+	})
+
+	t.Run("each cache is refreshed by its own service", func(t *testing.T) {
+		// given
+		hostService.DeleteToolchainCluster("member")
+		memberService.DeleteToolchainCluster("host")
+
+		// when
+		memberCluster, foundMember := hostCache.GetCachedToolchainCluster("member")
+		_, foundHostInHostCache := hostCache.GetCachedToolchainCluster("host")
+		hostCluster, foundHost := memberCache.GetCachedToolchainCluster("host")
+
+		// then
+		require.True(t, foundMember)
+		assert.Equal(t, test.MemberOperatorNs, memberCluster.OperatorNamespace)
+		assert.False(t, foundHostInHostCache)
+		require.True(t, foundHost)
+		assert.Equal(t, test.HostOperatorNs, hostCluster.OperatorNamespace)
+	})
+
+	t.Run("events are emitted only by the changed cache", func(t *testing.T) {
+		// given
+		var hostEvents, memberEvents []cluster.ClusterEvent
+		defer hostCache.Subscribe(func(event cluster.ClusterEvent) {
+			hostEvents = append(hostEvents, event)
+		})()
+		defer memberCache.Subscribe(func(event cluster.ClusterEvent) {
+			memberEvents = append(memberEvents, event)
+		})()
+
+		// when
+		hostService.DeleteToolchainCluster("member")
+
+		// then
+		require.Len(t, hostEvents, 1)
+		assert.Equal(t, cluster.ClusterRemoved, hostEvents[0].Type)
+		assert.Empty(t, memberEvents)
+	})
+}
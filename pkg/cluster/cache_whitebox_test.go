@@ -429,5 +429,5 @@ func newTestCachedToolchainCluster(t *testing.T, name string, options ...cluster
 }
 
 func resetClusterCache() {
-	clusterCache = toolchainClusterClients{clusters: map[string]*CachedToolchainCluster{}}
+	clusterCache = NewClusterCache()
 }
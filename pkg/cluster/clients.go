@@ -0,0 +1,181 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/apis"
+	commonclient "github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// informersSyncTimeout is the maximum duration of waiting for the informers of the cache-backed client to sync
+const informersSyncTimeout = time.Minute
+
+// clusterClients lazily creates and keeps the clients of a cluster. All the clients are created for the same rest config,
+// so when the rest config of the cluster changes, then a new instance is used and all the clients are recreated.
+type clusterClients struct {
+	lock       sync.Mutex
+	restConfig *rest.Config
+
+	clientset        *kubeclientset.Clientset
+	dynamicClient    dynamic.Interface
+	resourceCache    *commonclient.ResourceCache
+	cacheBacked      client.Client
+	stopInformers    context.CancelFunc
+	informersStopped bool
+}
+
+func newClusterClients(restConfig *rest.Config) *clusterClients {
+	return &clusterClients{restConfig: restConfig}
+}
+
+// clusterClients returns the clients of the cluster. The clusters that weren't created by the ToolchainClusterService
+// (eg. in tests) don't have the clients cached, so a new instance is returned for every call.
+func (c *CachedToolchainCluster) clusterClients() (*clusterClients, error) {
+	if c.clients != nil {
+		return c.clients, nil
+	}
+	if c.Config == nil || c.RestConfig == nil {
+		return nil, fmt.Errorf("the rest config of the cluster is not set")
+	}
+	return newClusterClients(c.RestConfig), nil
+}
+
+// Clientset returns the typed kube clientset of the cluster. The clientset is created on the first call
+// and then reused until the rest config of the cluster changes.
+func (c *CachedToolchainCluster) Clientset() (*kubeclientset.Clientset, error) {
+	clients, err := c.clusterClients()
+	if err != nil {
+		return nil, err
+	}
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+	if clients.clientset == nil {
+		clientset, err := kubeclientset.NewForConfig(clients.restConfig)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create the clientset for the cluster %s: %w", c.Name, err)
+		}
+		clients.clientset = clientset
+	}
+	return clients.clientset, nil
+}
+
+// DynamicClient returns the dynamic client of the cluster. The client is created on the first call
+// and then reused until the rest config of the cluster changes.
+func (c *CachedToolchainCluster) DynamicClient() (dynamic.Interface, error) {
+	clients, err := c.clusterClients()
+	if err != nil {
+		return nil, err
+	}
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+	if clients.dynamicClient == nil {
+		dynamicClient, err := dynamic.NewForConfig(clients.restConfig)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create the dynamic client for the cluster %s: %w", c.Name, err)
+		}
+		clients.dynamicClient = dynamicClient
+	}
+	return clients.dynamicClient, nil
+}
+
+// ResourceCache returns the cache of the API resources available in the cluster, which is backed by the discovery API.
+// The cache is created on the first call and then reused until the rest config of the cluster changes.
+func (c *CachedToolchainCluster) ResourceCache(options ...commonclient.ResourceCacheOption) (*commonclient.ResourceCache, error) {
+	clients, err := c.clusterClients()
+	if err != nil {
+		return nil, err
+	}
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+	if clients.resourceCache == nil {
+		discoveryClient, err := discovery.NewDiscoveryClientForConfig(clients.restConfig)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create the discovery client for the cluster %s: %w", c.Name, err)
+		}
+		// the options are applied only when the cache is created
+		clients.resourceCache = commonclient.NewResourceCache(discoveryClient, options...)
+	}
+	return clients.resourceCache, nil
+}
+
+// CacheBackedClient returns a client that reads the objects from informers instead of calling the API server.
+// The informers are started (and synced) on the first call and watch all the objects of the kinds that have been read
+// through the client, so the client should be used only for kinds with a reasonable number of objects.
+// The informers are stopped when the rest config of the cluster changes or when the cluster is removed from the cache.
+// The client is not available for the clusters that weren't created by the ToolchainClusterService.
+func (c *CachedToolchainCluster) CacheBackedClient() (client.Client, error) {
+	if c.clients == nil {
+		return nil, fmt.Errorf("the cache-backed client is not available for the cluster %s which is not managed by the ToolchainClusterService", c.Name)
+	}
+	clients := c.clients
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+	if clients.informersStopped {
+		return nil, fmt.Errorf("the cache-backed client of the cluster %s was invalidated", c.Name)
+	}
+	if clients.cacheBacked != nil {
+		return clients.cacheBacked, nil
+	}
+
+	scheme := runtime.NewScheme()
+	if err := apis.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	informers, err := cache.New(clients.restConfig, cache.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("cannot create the informer cache for the cluster %s: %w", c.Name, err)
+	}
+	cacheBacked, err := client.New(clients.restConfig, client.Options{
+		Scheme: scheme,
+		Cache:  &client.CacheOptions{Reader: informers},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot create the cache-backed client for the cluster %s: %w", c.Name, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	syncCtx, cancelSync := context.WithTimeout(ctx, informersSyncTimeout)
+	defer cancelSync()
+	started := make(chan error, 1)
+	go func() {
+		// Start blocks until the informers are stopped, so it returns early only when the informers cannot be started
+		started <- informers.Start(ctx)
+		cancelSync()
+	}()
+	if !informers.WaitForCacheSync(syncCtx) {
+		cancel()
+		select {
+		case err := <-started:
+			if err != nil {
+				return nil, fmt.Errorf("cannot start the informers of the cluster %s: %w", c.Name, err)
+			}
+		default:
+		}
+		return nil, fmt.Errorf("the informers of the cluster %s were not synced within %s", c.Name, informersSyncTimeout)
+	}
+	clients.cacheBacked = cacheBacked
+	clients.stopInformers = cancel
+	return cacheBacked, nil
+}
+
+// invalidate stops the informers of the cache-backed client (if started)
+func (c *clusterClients) invalidate() {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.stopInformers != nil {
+		c.stopInformers()
+	}
+	c.informersStopped = true
+}
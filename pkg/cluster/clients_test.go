@@ -0,0 +1,149 @@
+package cluster_test
+
+import (
+	"context"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	commonclient "github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/dynamic"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestClusterClients(t *testing.T) {
+	// given
+	status := test.NewClusterStatus(toolchainv1alpha1.ConditionReady, corev1.ConditionTrue)
+	toolchainCluster, secret := test.NewToolchainClusterWithEndpoint(t, "east", test.HostOperatorNs, test.MemberOperatorNs, "secret", "https://east.com", status, false)
+	cl := test.NewFakeClient(t, toolchainCluster, secret)
+	newClient := func(_ *rest.Config, _ client.Options) (client.Client, error) {
+		return test.NewFakeClient(t), nil
+	}
+	cache := cluster.NewClusterCache()
+	service := cluster.NewToolchainClusterServiceWithClient(cl, logf.Log, test.HostOperatorNs, 0, newClient, cluster.WithClusterCache(cache))
+	require.NoError(t, service.AddOrUpdateToolchainCluster(toolchainCluster))
+	cachedCluster, found := cache.GetCachedToolchainCluster("east")
+	require.True(t, found)
+	clientset, dynamicClient, resourceCache, cacheBacked := getClients(t, cachedCluster)
+
+	t.Run("clients are created only once", func(t *testing.T) {
+		// when
+		sameClientset, sameDynamicClient, sameResourceCache, sameCacheBacked := getClients(t, cachedCluster)
+
+		// then
+		assert.Equal(t, "east.com", clientset.RESTClient().Get().URL().Host)
+		assert.Same(t, clientset, sameClientset)
+		assert.Same(t, dynamicClient, sameDynamicClient)
+		assert.Same(t, resourceCache, sameResourceCache)
+		assert.Same(t, cacheBacked, sameCacheBacked)
+	})
+
+	t.Run("clients are reused when the rest config doesn't change", func(t *testing.T) {
+		// given
+		toolchainCluster.Status.Conditions = nil
+
+		// when
+		require.NoError(t, service.AddOrUpdateToolchainCluster(toolchainCluster))
+
+		// then
+		updatedCluster, found := cache.GetCachedToolchainCluster("east")
+		require.True(t, found)
+		assert.NotSame(t, cachedCluster, updatedCluster)
+		sameClientset, sameDynamicClient, sameResourceCache, sameCacheBacked := getClients(t, updatedCluster)
+		assert.Same(t, clientset, sameClientset)
+		assert.Same(t, dynamicClient, sameDynamicClient)
+		assert.Same(t, resourceCache, sameResourceCache)
+		assert.Same(t, cacheBacked, sameCacheBacked)
+	})
+
+	t.Run("clients are recreated when the rest config changes", func(t *testing.T) {
+		// given
+		test.SetKubeConfigToken(t, secret, "new-token")
+		require.NoError(t, cl.Update(context.TODO(), secret))
+
+		// when
+		require.NoError(t, service.AddOrUpdateToolchainCluster(toolchainCluster))
+
+		// then
+		updatedCluster, found := cache.GetCachedToolchainCluster("east")
+		require.True(t, found)
+		newClientset, newDynamicClient, newResourceCache, newCacheBacked := getClients(t, updatedCluster)
+		assert.NotSame(t, clientset, newClientset)
+		assert.NotSame(t, dynamicClient, newDynamicClient)
+		assert.NotSame(t, resourceCache, newResourceCache)
+		assert.NotSame(t, cacheBacked, newCacheBacked)
+
+		t.Run("cache-backed client of the previous version is invalidated", func(t *testing.T) {
+			// when
+			_, err := cachedCluster.CacheBackedClient()
+
+			// then
+			require.EqualError(t, err, "the cache-backed client of the cluster east was invalidated")
+		})
+
+		t.Run("cache-backed client is invalidated when the cluster is removed", func(t *testing.T) {
+			// when
+			service.DeleteToolchainCluster("east")
+
+			// then
+			_, err := updatedCluster.CacheBackedClient()
+			require.EqualError(t, err, "the cache-backed client of the cluster east was invalidated")
+		})
+	})
+}
+
+func TestClientsOfClusterNotManagedByService(t *testing.T) {
+	t.Run("clients are created from the rest config", func(t *testing.T) {
+		// given
+		cachedCluster := &cluster.CachedToolchainCluster{
+			Config: &cluster.Config{
+				Name:       "east",
+				RestConfig: &rest.Config{Host: "https://east.com"},
+			},
+		}
+
+		// when
+		clientset, err := cachedCluster.Clientset()
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "east.com", clientset.RESTClient().Get().URL().Host)
+		_, err = cachedCluster.DynamicClient()
+		require.NoError(t, err)
+		_, err = cachedCluster.ResourceCache()
+		require.NoError(t, err)
+		_, err = cachedCluster.CacheBackedClient()
+		require.EqualError(t, err, "the cache-backed client is not available for the cluster east which is not managed by the ToolchainClusterService")
+	})
+
+	t.Run("fails without rest config", func(t *testing.T) {
+		// given
+		cachedCluster := &cluster.CachedToolchainCluster{Config: &cluster.Config{Name: "east"}}
+
+		// when
+		_, err := cachedCluster.Clientset()
+
+		// then
+		require.EqualError(t, err, "the rest config of the cluster is not set")
+	})
+}
+
+func getClients(t *testing.T, cachedCluster *cluster.CachedToolchainCluster) (*kubeclientset.Clientset, dynamic.Interface, *commonclient.ResourceCache, client.Client) {
+	t.Helper()
+	clientset, err := cachedCluster.Clientset()
+	require.NoError(t, err)
+	dynamicClient, err := cachedCluster.DynamicClient()
+	require.NoError(t, err)
+	resourceCache, err := cachedCluster.ResourceCache()
+	require.NoError(t, err)
+	cacheBacked, err := cachedCluster.CacheBackedClient()
+	require.NoError(t, err)
+	return clientset, dynamicClient, resourceCache, cacheBacked
+}
@@ -0,0 +1,153 @@
+package cluster
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// WithRole checks that the cluster has the given role (ie. the RoleLabel(role) label)
+func WithRole(role Role) Condition {
+	return func(cluster *CachedToolchainCluster) bool {
+		return hasRole(cluster, role)
+	}
+}
+
+// MatchingLabels checks that the labels of the cluster match the given selector
+func MatchingLabels(selector labels.Selector) Condition {
+	return func(cluster *CachedToolchainCluster) bool {
+		if cluster.Config == nil {
+			return selector.Empty()
+		}
+		return selector.Matches(labels.Set(cluster.Labels))
+	}
+}
+
+// OwnedBy checks that the ToolchainCluster of the cluster is owned by the cluster with the given name
+func OwnedBy(ownerClusterName string) Condition {
+	return func(cluster *CachedToolchainCluster) bool {
+		return cluster.Config != nil && cluster.OwnerClusterName == ownerClusterName
+	}
+}
+
+// ReadyWithin checks that the cluster is ready and that its Ready condition was updated within the given duration,
+// ie. that the last health check of the cluster is not older than the duration
+func ReadyWithin(duration time.Duration) Condition {
+	return func(cluster *CachedToolchainCluster) bool {
+		ready, found := readyCondition(cluster)
+		if !found || ready.Status != v1.ConditionTrue || ready.LastUpdatedTime == nil {
+			return false
+		}
+		return time.Since(ready.LastUpdatedTime.Time) <= duration
+	}
+}
+
+// WithAPIEndpointHost checks that the API endpoint of the cluster points to the given host (the scheme and port are ignored)
+func WithAPIEndpointHost(host string) Condition {
+	return func(cluster *CachedToolchainCluster) bool {
+		return cluster.Config != nil && strings.EqualFold(apiEndpointHost(cluster.APIEndpoint), host)
+	}
+}
+
+// Not negates the given condition
+func Not(condition Condition) Condition {
+	return func(cluster *CachedToolchainCluster) bool {
+		return !condition(cluster)
+	}
+}
+
+// And checks that the cluster matches all the given conditions
+func And(conditions ...Condition) Condition {
+	return func(cluster *CachedToolchainCluster) bool {
+		for _, match := range conditions {
+			if !match(cluster) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or checks that the cluster matches at least one of the given conditions
+func Or(conditions ...Condition) Condition {
+	return func(cluster *CachedToolchainCluster) bool {
+		for _, match := range conditions {
+			if match(cluster) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ClusterOrder reports whether the first cluster should be sorted before the second one
+type ClusterOrder func(first, second *CachedToolchainCluster) bool
+
+// ByName sorts the clusters by their names
+var ByName ClusterOrder = func(first, second *CachedToolchainCluster) bool {
+	return first.Name < second.Name
+}
+
+// ByReadinessAge sorts the clusters by the time they are ready for - the clusters that have been ready for the longest
+// time come first, the clusters that are not ready come last
+var ByReadinessAge ClusterOrder = func(first, second *CachedToolchainCluster) bool {
+	firstReadySince, firstReady := readySince(first)
+	secondReadySince, secondReady := readySince(second)
+	if firstReady != secondReady {
+		return firstReady
+	}
+	return firstReadySince.Before(secondReadySince)
+}
+
+// SortClusters sorts the given clusters by the given orders - the next order is used only when the clusters are equal
+// in the previous one. The clusters that are equal in all the orders are sorted by name.
+func SortClusters(clusters []*CachedToolchainCluster, orders ...ClusterOrder) {
+	orders = append(orders, ByName)
+	sort.SliceStable(clusters, func(i, j int) bool {
+		for _, less := range orders {
+			if less(clusters[i], clusters[j]) {
+				return true
+			}
+			if less(clusters[j], clusters[i]) {
+				return false
+			}
+		}
+		return false
+	})
+}
+
+func readyCondition(cluster *CachedToolchainCluster) (toolchainv1alpha1.Condition, bool) {
+	if cluster.ClusterStatus == nil {
+		return toolchainv1alpha1.Condition{}, false
+	}
+	return condition.FindConditionByType(cluster.ClusterStatus.Conditions, toolchainv1alpha1.ConditionReady)
+}
+
+// readySince returns the time of the last transition of the Ready condition and true, if the cluster is ready
+func readySince(cluster *CachedToolchainCluster) (time.Time, bool) {
+	ready, found := readyCondition(cluster)
+	if !found || ready.Status != v1.ConditionTrue {
+		return time.Time{}, false
+	}
+	return ready.LastTransitionTime.Time, true
+}
+
+// apiEndpointHost returns the host of the API endpoint which can be a URL, hostname, hostname:port, IP or IP:port
+func apiEndpointHost(apiEndpoint string) string {
+	if strings.Contains(apiEndpoint, "://") {
+		if endpoint, err := url.Parse(apiEndpoint); err == nil {
+			return endpoint.Hostname()
+		}
+	}
+	if host, _, err := net.SplitHostPort(apiEndpoint); err == nil {
+		return host
+	}
+	return apiEndpoint
+}
@@ -0,0 +1,190 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func withLabels(clusterLabels map[string]string) clusterOption {
+	return func(c *CachedToolchainCluster) {
+		c.Labels = clusterLabels
+	}
+}
+
+func withAPIEndpoint(apiEndpoint string) clusterOption {
+	return func(c *CachedToolchainCluster) {
+		c.APIEndpoint = apiEndpoint
+	}
+}
+
+// readyAt an option to state the cluster as "ready" since the given transition time, last updated at the given time
+func readyAt(transitionTime, updatedTime time.Time) clusterOption {
+	return func(c *CachedToolchainCluster) {
+		c.ClusterStatus.Conditions = append(c.ClusterStatus.Conditions, toolchainv1alpha1.Condition{
+			Type:               toolchainv1alpha1.ConditionReady,
+			Status:             v1.ConditionTrue,
+			LastTransitionTime: metav1.NewTime(transitionTime),
+			LastUpdatedTime:    &metav1.Time{Time: updatedTime},
+		})
+	}
+}
+
+func TestConditions(t *testing.T) {
+	tenant := newTestCachedToolchainCluster(t, "tenant", ready, withRole(Tenant))
+	labeled := newTestCachedToolchainCluster(t, "labeled", notReady, withLabels(map[string]string{"env": "prod"}))
+	owned := newTestCachedToolchainCluster(t, "owned", ready, ownedBy("member-1"))
+	noConfig := &CachedToolchainCluster{ClusterStatus: &toolchainv1alpha1.ToolchainClusterStatus{}}
+
+	t.Run("with role", func(t *testing.T) {
+		assert.True(t, WithRole(Tenant)(tenant))
+		assert.False(t, WithRole(Tenant)(labeled))
+		assert.False(t, WithRole(Tenant)(noConfig))
+	})
+
+	t.Run("matching labels", func(t *testing.T) {
+		// given
+		prod, err := labels.Parse("env=prod")
+		require.NoError(t, err)
+		notDev, err := labels.Parse("env!=dev")
+		require.NoError(t, err)
+
+		// then
+		assert.True(t, MatchingLabels(prod)(labeled))
+		assert.False(t, MatchingLabels(prod)(tenant))
+		assert.True(t, MatchingLabels(notDev)(tenant))
+		assert.True(t, MatchingLabels(labels.Everything())(noConfig))
+		assert.False(t, MatchingLabels(prod)(noConfig))
+	})
+
+	t.Run("owned by", func(t *testing.T) {
+		assert.True(t, OwnedBy("member-1")(owned))
+		assert.False(t, OwnedBy("member-2")(owned))
+		assert.False(t, OwnedBy("member-1")(tenant))
+		assert.False(t, OwnedBy("")(noConfig))
+	})
+
+	t.Run("ready within", func(t *testing.T) {
+		// given
+		now := time.Now()
+		recent := newTestCachedToolchainCluster(t, "recent", readyAt(now.Add(-time.Hour), now.Add(-10*time.Second)))
+		stale := newTestCachedToolchainCluster(t, "stale", readyAt(now.Add(-time.Hour), now.Add(-10*time.Minute)))
+		// the clusters marked by the ready option don't have the last updated time
+		unknown := newTestCachedToolchainCluster(t, "unknown", ready)
+
+		// then
+		assert.True(t, ReadyWithin(time.Minute)(recent))
+		assert.False(t, ReadyWithin(time.Minute)(stale))
+		assert.True(t, ReadyWithin(time.Hour)(stale))
+		assert.False(t, ReadyWithin(time.Hour)(unknown))
+		assert.False(t, ReadyWithin(time.Hour)(labeled))
+		assert.False(t, ReadyWithin(time.Hour)(noConfig))
+	})
+
+	t.Run("with API endpoint host", func(t *testing.T) {
+		for _, apiEndpoint := range []string{
+			"https://api.cluster.example.com:6443",
+			"https://api.cluster.example.com",
+			"api.cluster.example.com:6443",
+			"api.cluster.example.com",
+		} {
+			t.Run(apiEndpoint, func(t *testing.T) {
+				// given
+				cluster := newTestCachedToolchainCluster(t, "cluster", withAPIEndpoint(apiEndpoint))
+
+				// then
+				assert.True(t, WithAPIEndpointHost("api.cluster.example.com")(cluster))
+				assert.True(t, WithAPIEndpointHost("API.cluster.example.com")(cluster))
+				assert.False(t, WithAPIEndpointHost("cluster.example.com")(cluster))
+			})
+		}
+
+		t.Run("IP", func(t *testing.T) {
+			assert.True(t, WithAPIEndpointHost("10.0.0.1")(newTestCachedToolchainCluster(t, "cluster", withAPIEndpoint("10.0.0.1:6443"))))
+			assert.True(t, WithAPIEndpointHost("::1")(newTestCachedToolchainCluster(t, "cluster", withAPIEndpoint("https://[::1]:6443"))))
+			assert.False(t, WithAPIEndpointHost("")(noConfig))
+		})
+	})
+
+	t.Run("combinators", func(t *testing.T) {
+		assert.True(t, Not(Ready)(labeled))
+		assert.False(t, Not(Ready)(tenant))
+
+		assert.True(t, And(Ready, WithRole(Tenant))(tenant))
+		assert.False(t, And(Ready, WithRole(Tenant))(owned))
+		assert.True(t, And()(tenant))
+
+		assert.True(t, Or(WithRole(Tenant), OwnedBy("member-1"))(owned))
+		assert.False(t, Or(WithRole(Tenant), OwnedBy("member-1"))(labeled))
+		assert.False(t, Or()(tenant))
+
+		assert.True(t, Or(And(Ready, Not(WithRole(Tenant))), OwnedBy("nobody"))(owned))
+	})
+}
+
+func TestSortClusters(t *testing.T) {
+	// given
+	now := time.Now()
+	oldest := newTestCachedToolchainCluster(t, "c-oldest", readyAt(now.Add(-2*time.Hour), now))
+	newest := newTestCachedToolchainCluster(t, "a-newest", readyAt(now.Add(-time.Minute), now))
+	sameAge := newTestCachedToolchainCluster(t, "b-same-age", readyAt(now.Add(-time.Minute), now))
+	notReadyCluster := newTestCachedToolchainCluster(t, "0-not-ready", notReady)
+
+	t.Run("by name", func(t *testing.T) {
+		// given
+		clusters := []*CachedToolchainCluster{oldest, sameAge, notReadyCluster, newest}
+
+		// when
+		SortClusters(clusters, ByName)
+
+		// then
+		assert.Equal(t, []*CachedToolchainCluster{notReadyCluster, newest, sameAge, oldest}, clusters)
+	})
+
+	t.Run("by readiness age", func(t *testing.T) {
+		// given
+		clusters := []*CachedToolchainCluster{notReadyCluster, sameAge, newest, oldest}
+
+		// when
+		SortClusters(clusters, ByReadinessAge)
+
+		// then
+		// the clusters with the same age are sorted by name
+		assert.Equal(t, []*CachedToolchainCluster{oldest, newest, sameAge, notReadyCluster}, clusters)
+	})
+
+	t.Run("by name if no order is given", func(t *testing.T) {
+		// given
+		clusters := []*CachedToolchainCluster{oldest, newest}
+
+		// when
+		SortClusters(clusters)
+
+		// then
+		assert.Equal(t, []*CachedToolchainCluster{newest, oldest}, clusters)
+	})
+}
+
+func TestGetMemberClustersIsSortedByName(t *testing.T) {
+	// given
+	defer resetClusterCache()
+	for _, name := range []string{"member-3", "member-1", "member-4", "member-2"} {
+		clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, name, ready))
+	}
+
+	// when
+	clusters := GetMemberClusters(Not(WithAPIEndpointHost("unknown")))
+
+	// then
+	names := make([]string, 0, len(clusters))
+	for _, cluster := range clusters {
+		names = append(names, cluster.Name)
+	}
+	assert.Equal(t, []string{"member-1", "member-2", "member-3", "member-4"}, names)
+}
@@ -0,0 +1,110 @@
+package cluster
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+// The overrides of the connection to the cluster which are not part of the kubeconfig. The proxy URL and the TLS server name
+// can be set either by the annotations of the ToolchainCluster or by the keys of its secret - the annotations take precedence.
+// The extra CA bundle can be set only in the secret.
+const (
+	// ProxyURLAnnotationKey is the annotation of the ToolchainCluster with the URL of the proxy used for connecting to the cluster
+	ProxyURLAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "proxy-url"
+	// TLSServerNameAnnotationKey is the annotation of the ToolchainCluster with the server name used for the SNI and for
+	// the verification of the certificate of the API server (eg. when the cluster is reached through a load balancer)
+	TLSServerNameAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "tls-server-name"
+
+	// ProxyURLSecretKey is the key of the URL of the proxy used for connecting to the cluster
+	ProxyURLSecretKey = "proxy-url"
+	// TLSServerNameSecretKey is the key of the server name used for the SNI and for the verification of the certificate of the API server
+	TLSServerNameSecretKey = "tls-server-name"
+	// ExtraCACertSecretKey is the key of the PEM-encoded CA certificates trusted in addition to the CA from the kubeconfig or the `ca.crt` key
+	ExtraCACertSecretKey = "extra-ca.crt"
+)
+
+// applyConnectionOverrides sets the proxy, the TLS server name and the extra CA bundle in the rest config
+func applyConnectionOverrides(toolchainCluster *toolchainv1alpha1.ToolchainCluster, secret *v1.Secret, config *Config) error {
+	restCfg := config.RestConfig
+	if proxyURL := overrideValue(toolchainCluster, secret, ProxyURLAnnotationKey, ProxyURLSecretKey); proxyURL != "" {
+		proxy, err := url.Parse(proxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy URL of the cluster %s: %w", toolchainCluster.Name, err)
+		}
+		switch proxy.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("invalid proxy URL of the cluster %s: unsupported scheme '%s'", toolchainCluster.Name, proxy.Scheme)
+		}
+		restCfg.Proxy = http.ProxyURL(proxy)
+		config.ProxyURL = proxyURL
+	}
+	if serverName := overrideValue(toolchainCluster, secret, TLSServerNameAnnotationKey, TLSServerNameSecretKey); serverName != "" {
+		restCfg.ServerName = serverName
+	}
+	if extraCA := secret.Data[ExtraCACertSecretKey]; len(extraCA) > 0 {
+		caData := restCfg.CAData
+		if len(caData) == 0 && restCfg.CAFile != "" {
+			// both bundles have to be in the same field
+			fileData, err := os.ReadFile(restCfg.CAFile)
+			if err != nil {
+				return fmt.Errorf("unable to read the CA file of the cluster %s: %w", toolchainCluster.Name, err)
+			}
+			caData = fileData
+			restCfg.CAFile = ""
+		}
+		if caData = bytes.TrimSpace(caData); len(caData) > 0 {
+			restCfg.CAData = slices.Concat(caData, []byte("\n"), extraCA)
+		} else {
+			restCfg.CAData = extraCA
+		}
+	}
+	return nil
+}
+
+func overrideValue(toolchainCluster *toolchainv1alpha1.ToolchainCluster, secret *v1.Secret, annotationKey, secretKey string) string {
+	if value := toolchainCluster.Annotations[annotationKey]; value != "" {
+		return value
+	}
+	return string(secret.Data[secretKey])
+}
+
+// sameConfig returns true if both configs are equal, see sameRestConfig for the comparison of the rest configs
+func sameConfig(config, other *Config) bool {
+	if config == nil || other == nil {
+		return config == other
+	}
+	withoutRestConfig, otherWithoutRestConfig := *config, *other
+	withoutRestConfig.RestConfig, otherWithoutRestConfig.RestConfig = nil, nil
+	return reflect.DeepEqual(withoutRestConfig, otherWithoutRestConfig) && sameRestConfig(config, other)
+}
+
+// sameRestConfig returns true if both configs result in the same connection to the cluster. The rest configs can't be compared
+// directly, because the proxy is a function, so the proxy URLs are compared instead.
+func sameRestConfig(config, other *Config) bool {
+	if config == nil || other == nil {
+		return config == other
+	}
+	if config.ProxyURL != other.ProxyURL {
+		return false
+	}
+	return reflect.DeepEqual(withoutProxy(config.RestConfig), withoutProxy(other.RestConfig))
+}
+
+func withoutProxy(restConfig *rest.Config) *rest.Config {
+	if restConfig == nil || restConfig.Proxy == nil {
+		return restConfig
+	}
+	restConfig = rest.CopyConfig(restConfig)
+	restConfig.Proxy = nil
+	return restConfig
+}
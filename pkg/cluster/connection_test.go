@@ -0,0 +1,215 @@
+package cluster_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestConnectionOverrides(t *testing.T) {
+	newToolchainCluster := func(annotations map[string]string) *toolchainv1alpha1.ToolchainCluster {
+		return &toolchainv1alpha1.ToolchainCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "tc",
+				Namespace:   "ns",
+				Annotations: annotations,
+			},
+			Spec: toolchainv1alpha1.ToolchainClusterSpec{
+				SecretRef: toolchainv1alpha1.LocalSecretReference{
+					Name: "secret",
+				},
+			},
+		}
+	}
+	newSecret := func(data map[string]string) *corev1.Secret {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "ns",
+			},
+			Data: map[string][]byte{
+				cluster.APIURLSecretKey:    []byte("https://over.the.rainbow"),
+				cluster.TokenSecretKey:     []byte("token"),
+				cluster.NamespaceSecretKey: []byte("operatorns"),
+			},
+		}
+		for key, value := range data {
+			secret.Data[key] = []byte(value)
+		}
+		return secret
+	}
+	proxyOf := func(t *testing.T, restConfig *rest.Config) string {
+		t.Helper()
+		require.NotNil(t, restConfig.Proxy)
+		req, err := http.NewRequest(http.MethodGet, restConfig.Host, nil)
+		require.NoError(t, err)
+		proxy, err := restConfig.Proxy(req)
+		require.NoError(t, err)
+		return proxy.String()
+	}
+
+	t.Run("no overrides", func(t *testing.T) {
+		// given
+		tc := newToolchainCluster(nil)
+		cl := test.NewFakeClient(t, tc, newSecret(nil))
+
+		// when
+		cfg, err := cluster.NewClusterConfig(cl, tc, time.Second)
+
+		// then
+		require.NoError(t, err)
+		assert.Nil(t, cfg.RestConfig.Proxy)
+		assert.Empty(t, cfg.ProxyURL)
+		assert.Empty(t, cfg.RestConfig.ServerName)
+		assert.Empty(t, cfg.RestConfig.CAData)
+	})
+
+	t.Run("from secret", func(t *testing.T) {
+		// given
+		tc := newToolchainCluster(nil)
+		cl := test.NewFakeClient(t, tc, newSecret(map[string]string{
+			cluster.ProxyURLSecretKey:      "http://proxy.corp:3128",
+			cluster.TLSServerNameSecretKey: "api.cluster.internal",
+			cluster.CACertSecretKey:        "ca-data\n",
+			cluster.ExtraCACertSecretKey:   "extra-ca-data",
+		}))
+
+		// when
+		cfg, err := cluster.NewClusterConfig(cl, tc, time.Second)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "http://proxy.corp:3128", cfg.ProxyURL)
+		assert.Equal(t, "http://proxy.corp:3128", proxyOf(t, cfg.RestConfig))
+		assert.Equal(t, "api.cluster.internal", cfg.RestConfig.ServerName)
+		assert.Equal(t, "ca-data\nextra-ca-data", string(cfg.RestConfig.CAData))
+	})
+
+	t.Run("annotations take precedence", func(t *testing.T) {
+		// given
+		tc := newToolchainCluster(map[string]string{
+			cluster.ProxyURLAnnotationKey:      "socks5://proxy.corp:1080",
+			cluster.TLSServerNameAnnotationKey: "lb.cluster.internal",
+		})
+		cl := test.NewFakeClient(t, tc, newSecret(map[string]string{
+			cluster.ProxyURLSecretKey:      "http://proxy.corp:3128",
+			cluster.TLSServerNameSecretKey: "api.cluster.internal",
+		}))
+
+		// when
+		cfg, err := cluster.NewClusterConfig(cl, tc, time.Second)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "socks5://proxy.corp:1080", proxyOf(t, cfg.RestConfig))
+		assert.Equal(t, "lb.cluster.internal", cfg.RestConfig.ServerName)
+	})
+
+	t.Run("extra CA is merged with the CA file from kubeconfig", func(t *testing.T) {
+		// given
+		caFile := filepath.Join(t.TempDir(), "ca.crt")
+		require.NoError(t, os.WriteFile(caFile, []byte("ca-file-data"), 0600))
+		kubeconfig, err := clientcmd.Write(clientcmdapi.Config{
+			Clusters:       map[string]*clientcmdapi.Cluster{"cluster": {Server: "https://over.the.rainbow", CertificateAuthority: caFile}},
+			Contexts:       map[string]*clientcmdapi.Context{"ctx": {Cluster: "cluster", AuthInfo: "auth", Namespace: "operatorns"}},
+			AuthInfos:      map[string]*clientcmdapi.AuthInfo{"auth": {Token: "token"}},
+			CurrentContext: "ctx",
+		})
+		require.NoError(t, err)
+		tc := newToolchainCluster(nil)
+		secret := newSecret(nil)
+		secret.Data = map[string][]byte{
+			cluster.KubeConfigSecretKey:  kubeconfig,
+			cluster.ExtraCACertSecretKey: []byte("extra-ca-data"),
+		}
+		cl := test.NewFakeClient(t, tc, secret)
+
+		// when
+		cfg, err := cluster.NewClusterConfig(cl, tc, time.Second)
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, cfg.RestConfig.CAFile)
+		assert.Equal(t, "ca-file-data\nextra-ca-data", string(cfg.RestConfig.CAData))
+	})
+
+	t.Run("invalid proxy URL", func(t *testing.T) {
+		for proxyURL, expectedErr := range map[string]string{
+			"ftp://proxy.corp":   "invalid proxy URL of the cluster tc: unsupported scheme 'ftp'",
+			"proxy.corp:3128":    "invalid proxy URL of the cluster tc: unsupported scheme 'proxy.corp'",
+			"http://proxy corp/": `invalid proxy URL of the cluster tc: parse "http://proxy corp/": invalid character " " in host name`,
+		} {
+			t.Run(proxyURL, func(t *testing.T) {
+				// given
+				tc := newToolchainCluster(map[string]string{cluster.ProxyURLAnnotationKey: proxyURL})
+				cl := test.NewFakeClient(t, tc, newSecret(nil))
+
+				// when
+				_, err := cluster.NewClusterConfig(cl, tc, time.Second)
+
+				// then
+				require.EqualError(t, err, expectedErr)
+			})
+		}
+	})
+
+	t.Run("client is reused when the overrides don't change", func(t *testing.T) {
+		// given
+		tc := newToolchainCluster(map[string]string{cluster.ProxyURLAnnotationKey: "http://proxy.corp:3128"})
+		tc.Namespace = test.HostOperatorNs
+		secret := newSecret(nil)
+		secret.Namespace = test.HostOperatorNs
+		cl := test.NewFakeClient(t, tc, secret)
+		newClient := func(_ *rest.Config, _ client.Options) (client.Client, error) {
+			return test.NewFakeClient(t), nil
+		}
+		cache := cluster.NewClusterCache()
+		service := cluster.NewToolchainClusterServiceWithClient(cl, logf.Log, test.HostOperatorNs, 0, newClient, cluster.WithClusterCache(cache))
+		require.NoError(t, service.AddOrUpdateToolchainCluster(tc))
+		cachedCluster, found := cache.GetCachedToolchainCluster("tc")
+		require.True(t, found)
+		var events []cluster.ClusterEvent
+		defer cache.Subscribe(func(event cluster.ClusterEvent) {
+			events = append(events, event)
+		})()
+
+		// when
+		require.NoError(t, service.AddOrUpdateToolchainCluster(tc))
+
+		// then
+		updatedCluster, found := cache.GetCachedToolchainCluster("tc")
+		require.True(t, found)
+		assert.Same(t, cachedCluster.Client, updatedCluster.Client)
+		assert.Empty(t, events)
+
+		t.Run("client is recreated when the proxy changes", func(t *testing.T) {
+			// given
+			tc.Annotations[cluster.ProxyURLAnnotationKey] = "http://other-proxy.corp:3128"
+
+			// when
+			require.NoError(t, service.AddOrUpdateToolchainCluster(tc))
+
+			// then
+			updatedCluster, found := cache.GetCachedToolchainCluster("tc")
+			require.True(t, found)
+			assert.NotSame(t, cachedCluster.Client, updatedCluster.Client)
+			require.Len(t, events, 1)
+			assert.Equal(t, cluster.ClusterUpdated, events[0].Type)
+		})
+	})
+}
@@ -0,0 +1,108 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+)
+
+// ConnectivityAnnotationKey is the annotation of the ToolchainCluster with the JSON-encoded ConnectivityReport of the last connectivity
+// diagnostics of the remote cluster. The ToolchainClusterStatus has no field for it, so the report is published in the annotation instead.
+const ConnectivityAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "connectivity"
+
+// DiagnosticsStage is a stage of the connection to a remote cluster checked by the connectivity diagnostics
+type DiagnosticsStage string
+
+// The stages of the connection, in the order they are checked
+const (
+	DNSStage     DiagnosticsStage = "DNS"
+	TCPStage     DiagnosticsStage = "TCP"
+	TLSStage     DiagnosticsStage = "TLS"
+	AuthStage    DiagnosticsStage = "Auth"
+	HealthzStage DiagnosticsStage = "Healthz"
+)
+
+// StageResult is the result of a single stage of the connectivity diagnostics
+type StageResult struct {
+	Stage  DiagnosticsStage `json:"stage"`
+	Passed bool             `json:"passed"`
+	// Reason is the reason of the failure in the CamelCase format, empty if the stage passed
+	Reason string `json:"reason,omitempty"`
+	// Details describe what was checked and what failed
+	Details  string        `json:"details,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Summary describes the failed stage
+func (s StageResult) Summary() string {
+	return fmt.Sprintf("the %s stage failed: %s", s.Stage, s.Details)
+}
+
+// CertificateInfo describes a certificate presented by the API server
+type CertificateInfo struct {
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	DNSNames    []string  `json:"dnsNames,omitempty"`
+	IPAddresses []string  `json:"ipAddresses,omitempty"`
+	NotBefore   time.Time `json:"notBefore"`
+	NotAfter    time.Time `json:"notAfter"`
+}
+
+// ConnectivityReport is the result of the connectivity diagnostics of a cluster
+type ConnectivityReport struct {
+	// Time when the diagnostics started
+	Time time.Time `json:"time"`
+	// Stages are the checked stages in the order they were checked. The diagnostics stop at the first failed stage.
+	Stages []StageResult `json:"stages"`
+	// Certificates is the certificate chain presented by the API server, the leaf certificate first. It's empty if the TLS
+	// handshake was not done.
+	Certificates []CertificateInfo `json:"certificates,omitempty"`
+}
+
+// Failed returns the failed stage, if any
+func (r ConnectivityReport) Failed() (StageResult, bool) {
+	for _, stage := range r.Stages {
+		if !stage.Passed {
+			return stage, true
+		}
+	}
+	return StageResult{}, false
+}
+
+// GetConnectivityReport returns the connectivity report published in the annotation of the ToolchainCluster, or nil if there is none
+func GetConnectivityReport(toolchainCluster *toolchainv1alpha1.ToolchainCluster) (*ConnectivityReport, error) {
+	value, found := toolchainCluster.Annotations[ConnectivityAnnotationKey]
+	if !found {
+		return nil, nil
+	}
+	report := &ConnectivityReport{}
+	if err := json.Unmarshal([]byte(value), report); err != nil {
+		return nil, fmt.Errorf("invalid connectivity report of the cluster %s: %w", toolchainCluster.Name, err)
+	}
+	return report, nil
+}
+
+// SetConnectivityReport publishes the connectivity report in the annotation of the ToolchainCluster. The annotation is removed
+// if the report is nil. It returns true if the annotation was changed.
+func SetConnectivityReport(toolchainCluster *toolchainv1alpha1.ToolchainCluster, report *ConnectivityReport) (bool, error) {
+	current, found := toolchainCluster.Annotations[ConnectivityAnnotationKey]
+	if report == nil {
+		delete(toolchainCluster.Annotations, ConnectivityAnnotationKey)
+		return found, nil
+	}
+	value, err := json.Marshal(report)
+	if err != nil {
+		return false, fmt.Errorf("unable to encode the connectivity report of the cluster %s: %w", toolchainCluster.Name, err)
+	}
+	if found && bytes.Equal([]byte(current), value) {
+		return false, nil
+	}
+	if toolchainCluster.Annotations == nil {
+		toolchainCluster.Annotations = map[string]string{}
+	}
+	toolchainCluster.Annotations[ConnectivityAnnotationKey] = string(value)
+	return true, nil
+}
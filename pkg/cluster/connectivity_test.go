@@ -0,0 +1,95 @@
+package cluster_test
+
+import (
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConnectivityReport(t *testing.T) {
+	diagnosedAt := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	report := &cluster.ConnectivityReport{
+		Time: diagnosedAt,
+		Stages: []cluster.StageResult{
+			{Stage: cluster.DNSStage, Passed: true, Details: "resolved", Duration: time.Millisecond},
+			{Stage: cluster.TCPStage, Passed: true, Details: "connected", Duration: time.Millisecond},
+			{Stage: cluster.TLSStage, Reason: "CertificateInvalid", Details: "expired", Duration: time.Millisecond},
+		},
+		Certificates: []cluster.CertificateInfo{
+			{Subject: "CN=api", Issuer: "CN=ca", NotAfter: diagnosedAt.Add(-time.Hour)},
+		},
+	}
+
+	t.Run("no report", func(t *testing.T) {
+		// given
+		tc := &toolchainv1alpha1.ToolchainCluster{}
+
+		// when
+		published, err := cluster.GetConnectivityReport(tc)
+
+		// then
+		require.NoError(t, err)
+		assert.Nil(t, published)
+	})
+
+	t.Run("invalid report", func(t *testing.T) {
+		// given
+		tc := &toolchainv1alpha1.ToolchainCluster{ObjectMeta: metav1.ObjectMeta{
+			Name:        "member",
+			Annotations: map[string]string{cluster.ConnectivityAnnotationKey: "{"},
+		}}
+
+		// when
+		_, err := cluster.GetConnectivityReport(tc)
+
+		// then
+		require.EqualError(t, err, "invalid connectivity report of the cluster member: unexpected end of JSON input")
+	})
+
+	t.Run("set and get report", func(t *testing.T) {
+		// given
+		tc := &toolchainv1alpha1.ToolchainCluster{ObjectMeta: metav1.ObjectMeta{Name: "member"}}
+
+		// when
+		changed, err := cluster.SetConnectivityReport(tc, report)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, changed)
+		published, err := cluster.GetConnectivityReport(tc)
+		require.NoError(t, err)
+		assert.Equal(t, report, published)
+		failed, found := published.Failed()
+		require.True(t, found)
+		assert.Equal(t, cluster.TLSStage, failed.Stage)
+		assert.Equal(t, "the TLS stage failed: expired", failed.Summary())
+
+		t.Run("same report doesn't change the annotation", func(t *testing.T) {
+			// when
+			changed, err := cluster.SetConnectivityReport(tc, report)
+
+			// then
+			require.NoError(t, err)
+			assert.False(t, changed)
+		})
+
+		t.Run("no report removes the annotation", func(t *testing.T) {
+			// when
+			changed, err := cluster.SetConnectivityReport(tc, nil)
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, changed)
+			assert.NotContains(t, tc.Annotations, cluster.ConnectivityAnnotationKey)
+
+			changed, err = cluster.SetConnectivityReport(tc, nil)
+			require.NoError(t, err)
+			assert.False(t, changed)
+		})
+	})
+}
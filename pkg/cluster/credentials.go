@@ -0,0 +1,136 @@
+package cluster
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"k8s.io/client-go/rest"
+)
+
+// the credentials that can be found in the kubeconfig of a ToolchainCluster
+const (
+	TokenCredential             = "token"
+	ClientCertificateCredential = "client-certificate"
+	CACredential                = "certificate-authority"
+)
+
+// CredentialExpiry is the expiration time of one of the credentials used to access a cluster
+type CredentialExpiry struct {
+	// Credential is one of TokenCredential, ClientCertificateCredential or CACredential
+	Credential string
+	Expiry     time.Time
+}
+
+// CredentialsInfo contains the expiration times of the credentials used to access a cluster.
+// An expiration time is nil when it's not known, eg. for a legacy service account token that doesn't expire
+// or when the credential is not used.
+type CredentialsInfo struct {
+	TokenExpiry             *time.Time
+	ClientCertificateExpiry *time.Time
+	CAExpiry                *time.Time
+}
+
+// Expiries returns the known expiration times of the credentials, the earliest one first
+func (i *CredentialsInfo) Expiries() []CredentialExpiry {
+	var expiries []CredentialExpiry
+	for credential, expiry := range map[string]*time.Time{
+		TokenCredential:             i.TokenExpiry,
+		ClientCertificateCredential: i.ClientCertificateExpiry,
+		CACredential:                i.CAExpiry,
+	} {
+		if expiry != nil {
+			expiries = append(expiries, CredentialExpiry{Credential: credential, Expiry: *expiry})
+		}
+	}
+	sort.Slice(expiries, func(a, b int) bool {
+		if expiries[a].Expiry.Equal(expiries[b].Expiry) {
+			return expiries[a].Credential < expiries[b].Credential
+		}
+		return expiries[a].Expiry.Before(expiries[b].Expiry)
+	})
+	return expiries
+}
+
+// InspectCredentials finds the expiration times of the bearer token, the client certificate and the CA certificates
+// in the given config. The token is expected to be a JWT (eg. a service account token) - other tokens are considered
+// to have an unknown expiration time. For the certificates, the earliest expiration time in the chain is returned.
+func InspectCredentials(restConfig *rest.Config) (*CredentialsInfo, error) {
+	info := &CredentialsInfo{}
+
+	token := restConfig.BearerToken
+	if token == "" && restConfig.BearerTokenFile != "" {
+		content, err := os.ReadFile(restConfig.BearerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the token file: %w", err)
+		}
+		token = string(content)
+	}
+	info.TokenExpiry = TokenExpiry(token)
+
+	certData, err := dataOrFile(restConfig.CertData, restConfig.CertFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the client certificate: %w", err)
+	}
+	if info.ClientCertificateExpiry, err = certificatesExpiry(certData); err != nil {
+		return nil, fmt.Errorf("unable to parse the client certificate: %w", err)
+	}
+
+	caData, err := dataOrFile(restConfig.CAData, restConfig.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the certificate authority: %w", err)
+	}
+	if info.CAExpiry, err = certificatesExpiry(caData); err != nil {
+		return nil, fmt.Errorf("unable to parse the certificate authority: %w", err)
+	}
+	return info, nil
+}
+
+// TokenExpiry returns the expiration time from the `exp` claim of the given JWT. The signature is not verified.
+// It returns nil if the token is not a JWT or if it doesn't expire.
+func TokenExpiry(token string) *time.Time {
+	if token == "" {
+		return nil
+	}
+	claims := &jwt.RegisteredClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil || claims.ExpiresAt == nil {
+		return nil
+	}
+	expiry := claims.ExpiresAt.Time
+	return &expiry
+}
+
+func dataOrFile(data []byte, file string) ([]byte, error) {
+	if len(data) > 0 || file == "" {
+		return data, nil
+	}
+	return os.ReadFile(file)
+}
+
+// certificatesExpiry returns the earliest expiration time of the PEM encoded certificates, or nil if there is no certificate
+func certificatesExpiry(pemData []byte) (*time.Time, error) {
+	var earliest *time.Time
+	for len(pemData) > 0 {
+		var block *pem.Block
+		block, pemData = pem.Decode(pemData)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if earliest == nil || cert.NotAfter.Before(*earliest) {
+			notAfter := cert.NotAfter
+			earliest = &notAfter
+		}
+	}
+	return earliest, nil
+}
@@ -0,0 +1,107 @@
+package cluster_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/rest"
+)
+
+func TestInspectCredentials(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	t.Run("token and certificates", func(t *testing.T) {
+		// given
+		caChain := append(test.NewCertificatePEM(t, now.Add(48*time.Hour)), test.NewCertificatePEM(t, now.Add(24*time.Hour))...)
+		restConfig := &rest.Config{
+			BearerToken: test.NewServiceAccountToken(t, now.Add(time.Hour)),
+			TLSClientConfig: rest.TLSClientConfig{
+				CertData: test.NewCertificatePEM(t, now.Add(72*time.Hour)),
+				CAData:   caChain,
+			},
+		}
+
+		// when
+		info, err := cluster.InspectCredentials(restConfig)
+
+		// then
+		require.NoError(t, err)
+		require.NotNil(t, info.TokenExpiry)
+		assert.True(t, now.Add(time.Hour).Equal(*info.TokenExpiry))
+		require.NotNil(t, info.ClientCertificateExpiry)
+		assert.True(t, now.Add(72*time.Hour).Equal(*info.ClientCertificateExpiry))
+		// the earliest certificate of the chain
+		require.NotNil(t, info.CAExpiry)
+		assert.True(t, now.Add(24*time.Hour).Equal(*info.CAExpiry))
+		expiries := info.Expiries()
+		require.Len(t, expiries, 3)
+		assert.Equal(t, cluster.TokenCredential, expiries[0].Credential)
+		assert.Equal(t, cluster.CACredential, expiries[1].Credential)
+		assert.Equal(t, cluster.ClientCertificateCredential, expiries[2].Credential)
+	})
+
+	t.Run("token from file", func(t *testing.T) {
+		// given
+		tokenFile := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(tokenFile, []byte(test.NewServiceAccountToken(t, now.Add(time.Hour))), 0600))
+
+		// when
+		info, err := cluster.InspectCredentials(&rest.Config{BearerTokenFile: tokenFile})
+
+		// then
+		require.NoError(t, err)
+		require.NotNil(t, info.TokenExpiry)
+		assert.True(t, now.Add(time.Hour).Equal(*info.TokenExpiry))
+	})
+
+	t.Run("unknown expiry", func(t *testing.T) {
+		// when
+		info, err := cluster.InspectCredentials(&rest.Config{BearerToken: "mycooltoken"})
+
+		// then
+		require.NoError(t, err)
+		assert.Nil(t, info.TokenExpiry)
+		assert.Nil(t, info.ClientCertificateExpiry)
+		assert.Nil(t, info.CAExpiry)
+		assert.Empty(t, info.Expiries())
+	})
+
+	t.Run("invalid certificate", func(t *testing.T) {
+		// given
+		restConfig := &rest.Config{
+			TLSClientConfig: rest.TLSClientConfig{
+				CAData: []byte("-----BEGIN CERTIFICATE-----\naW52YWxpZA==\n-----END CERTIFICATE-----\n"),
+			},
+		}
+
+		// when
+		_, err := cluster.InspectCredentials(restConfig)
+
+		// then
+		require.ErrorContains(t, err, "unable to parse the certificate authority")
+	})
+
+	t.Run("missing token file", func(t *testing.T) {
+		// when
+		_, err := cluster.InspectCredentials(&rest.Config{BearerTokenFile: filepath.Join(t.TempDir(), "token")})
+
+		// then
+		require.ErrorContains(t, err, "unable to read the token file")
+	})
+}
+
+func TestTokenExpiry(t *testing.T) {
+	// given
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	// when & then
+	assert.True(t, expiry.Equal(*cluster.TokenExpiry(test.NewServiceAccountToken(t, expiry))))
+	assert.Nil(t, cluster.TokenExpiry(""))
+	assert.Nil(t, cluster.TokenExpiry("not.a.jwt"))
+}
@@ -0,0 +1,134 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// Host is the role of the cluster the member operator should talk to. The role is set via the RoleLabel(Host) label
+	// on the ToolchainCluster.
+	Host Role = "host"
+	// HostStandBy is the role of a cluster that is used as the host when the cluster with the Host role is not ready or missing
+	HostStandBy Role = "host-standby"
+)
+
+var (
+	// ErrHostClusterNotFound is returned when there is no cluster that could be selected as the host
+	ErrHostClusterNotFound = errors.New("host cluster not found")
+	// ErrAmbiguousHostCluster is returned when there are multiple clusters that could be selected as the host
+	ErrAmbiguousHostCluster = errors.New("ambiguous host cluster")
+)
+
+type hostSelection struct {
+	name        string
+	apiEndpoint string
+}
+
+// HostSelectionOption configures the selection of the host cluster
+type HostSelectionOption func(selection *hostSelection)
+
+// WithClusterName considers only the cluster whose ToolchainCluster has the given name
+func WithClusterName(name string) HostSelectionOption {
+	return func(selection *hostSelection) {
+		selection.name = name
+	}
+}
+
+// WithAPIEndpoint considers only the clusters with the given API endpoint (a trailing slash is ignored)
+func WithAPIEndpoint(apiEndpoint string) HostSelectionOption {
+	return func(selection *hostSelection) {
+		selection.apiEndpoint = strings.TrimSuffix(apiEndpoint, "/")
+	}
+}
+
+func (s *hostSelection) matches(cluster *CachedToolchainCluster) bool {
+	return (s.name == "" || cluster.Name == s.name) &&
+		(s.apiEndpoint == "" || strings.TrimSuffix(cluster.APIEndpoint, "/") == s.apiEndpoint)
+}
+
+// SelectHostCluster selects the host cluster from the default cache. See ClusterCache.SelectHostCluster for more details.
+func SelectHostCluster(options ...HostSelectionOption) (*CachedToolchainCluster, error) {
+	return clusterCache.SelectHostCluster(options...)
+}
+
+// SelectHostCluster deterministically selects the host cluster from the cache:
+//   - the cluster with the Host role is selected if there is exactly one such cluster, more of them are ambiguous
+//   - if the Host cluster is not ready or if there is no cluster with the Host role, then the first ready cluster
+//     (ordered by name) with the HostStandBy role is selected
+//   - if there is no cluster with any of the roles, then the only cluster in the cache is selected, more of them are ambiguous
+//
+// Only the clusters matching the given options (eg. WithClusterName or WithAPIEndpoint) are considered.
+// Unlike GetHostCluster, there is no fallback to the first cluster in the cache when the selection is ambiguous.
+// The cache is refreshed if it's empty. The returned error wraps ErrHostClusterNotFound or ErrAmbiguousHostCluster.
+func (c *ClusterCache) SelectHostCluster(options ...HostSelectionOption) (*CachedToolchainCluster, error) {
+	selection := &hostSelection{}
+	for _, apply := range options {
+		apply(selection)
+	}
+	// the clusters are sorted by name
+	clusters := c.getCachedToolchainClusters()
+	if len(clusters) == 0 {
+		c.refresh("")
+		clusters = c.getCachedToolchainClusters()
+	}
+
+	var primaries, standBys, others []*CachedToolchainCluster
+	for _, cluster := range clusters {
+		if !selection.matches(cluster) {
+			continue
+		}
+		switch {
+		case hasRole(cluster, Host):
+			primaries = append(primaries, cluster)
+		case hasRole(cluster, HostStandBy):
+			standBys = append(standBys, cluster)
+		default:
+			others = append(others, cluster)
+		}
+	}
+
+	if len(primaries) > 1 {
+		return nil, fmt.Errorf("%w: multiple clusters with the %s role: %s", ErrAmbiguousHostCluster, Host, clusterNames(primaries))
+	}
+	if len(primaries) == 1 && IsReady(primaries[0].ClusterStatus) {
+		return primaries[0], nil
+	}
+	for _, standBy := range standBys {
+		if IsReady(standBy.ClusterStatus) {
+			return standBy, nil
+		}
+	}
+	if len(primaries) == 1 {
+		// none of the stand-by clusters is ready, so let's stay with the primary one
+		return primaries[0], nil
+	}
+	if len(standBys) > 0 {
+		return nil, fmt.Errorf("%w: none of the clusters with the %s role is ready: %s", ErrHostClusterNotFound, HostStandBy, clusterNames(standBys))
+	}
+	switch len(others) {
+	case 0:
+		return nil, ErrHostClusterNotFound
+	case 1:
+		return others[0], nil
+	default:
+		return nil, fmt.Errorf("%w: multiple clusters without the %s role: %s", ErrAmbiguousHostCluster, Host, clusterNames(others))
+	}
+}
+
+func hasRole(cluster *CachedToolchainCluster, role Role) bool {
+	if cluster.Config == nil {
+		return false
+	}
+	_, found := cluster.Labels[RoleLabel(role)]
+	return found
+}
+
+func clusterNames(clusters []*CachedToolchainCluster) string {
+	names := make([]string, 0, len(clusters))
+	for _, cluster := range clusters {
+		names = append(names, cluster.Name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,233 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withRole(role Role) clusterOption {
+	return func(c *CachedToolchainCluster) {
+		if c.Labels == nil {
+			c.Labels = map[string]string{}
+		}
+		c.Labels[RoleLabel(role)] = ""
+	}
+}
+
+func ownedBy(ownerClusterName string) clusterOption {
+	return func(c *CachedToolchainCluster) {
+		c.OwnerClusterName = ownerClusterName
+	}
+}
+
+func TestSelectHostCluster(t *testing.T) {
+
+	t.Run("with host role", func(t *testing.T) {
+
+		t.Run("single host cluster is selected over the unlabeled ones", func(t *testing.T) {
+			// given
+			defer resetClusterCache()
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "a-cluster", ready))
+			host := newTestCachedToolchainCluster(t, "host", ready, withRole(Host))
+			clusterCache.addCachedToolchainCluster(host)
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "z-cluster", ready))
+
+			// when
+			cluster, err := SelectHostCluster()
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, host, cluster)
+		})
+
+		t.Run("multiple host clusters are ambiguous", func(t *testing.T) {
+			// given
+			defer resetClusterCache()
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "host-2", ready, withRole(Host)))
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "host-1", ready, withRole(Host)))
+
+			// when
+			cluster, err := SelectHostCluster()
+
+			// then
+			require.ErrorIs(t, err, ErrAmbiguousHostCluster)
+			assert.EqualError(t, err, "ambiguous host cluster: multiple clusters with the host role: host-1, host-2")
+			assert.Nil(t, cluster)
+		})
+
+		t.Run("ready stand-by cluster is selected when the host is not ready", func(t *testing.T) {
+			// given
+			defer resetClusterCache()
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "host", notReady, withRole(Host)))
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "standby-1", notReady, withRole(HostStandBy)))
+			standBy := newTestCachedToolchainCluster(t, "standby-2", ready, withRole(HostStandBy))
+			clusterCache.addCachedToolchainCluster(standBy)
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "standby-3", ready, withRole(HostStandBy)))
+
+			// when
+			cluster, err := SelectHostCluster()
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, standBy, cluster)
+		})
+
+		t.Run("host cluster is selected when no stand-by cluster is ready", func(t *testing.T) {
+			// given
+			defer resetClusterCache()
+			host := newTestCachedToolchainCluster(t, "host", notReady, withRole(Host))
+			clusterCache.addCachedToolchainCluster(host)
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "standby", notReady, withRole(HostStandBy)))
+
+			// when
+			cluster, err := SelectHostCluster()
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, host, cluster)
+		})
+	})
+
+	t.Run("with stand-by role only", func(t *testing.T) {
+
+		t.Run("first ready stand-by cluster is selected", func(t *testing.T) {
+			// given
+			defer resetClusterCache()
+			standBy := newTestCachedToolchainCluster(t, "standby-1", ready, withRole(HostStandBy))
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "standby-2", ready, withRole(HostStandBy)))
+			clusterCache.addCachedToolchainCluster(standBy)
+
+			// when
+			cluster, err := SelectHostCluster()
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, standBy, cluster)
+		})
+
+		t.Run("not found when no stand-by cluster is ready", func(t *testing.T) {
+			// given
+			defer resetClusterCache()
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "standby", notReady, withRole(HostStandBy)))
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "other", ready))
+
+			// when
+			cluster, err := SelectHostCluster()
+
+			// then
+			require.ErrorIs(t, err, ErrHostClusterNotFound)
+			assert.EqualError(t, err, "host cluster not found: none of the clusters with the host-standby role is ready: standby")
+			assert.Nil(t, cluster)
+		})
+	})
+
+	t.Run("without roles", func(t *testing.T) {
+
+		t.Run("single cluster is selected", func(t *testing.T) {
+			// given
+			defer resetClusterCache()
+			host := newTestCachedToolchainCluster(t, "host", notReady)
+			clusterCache.addCachedToolchainCluster(host)
+
+			// when
+			cluster, err := SelectHostCluster()
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, host, cluster)
+		})
+
+		t.Run("multiple clusters are ambiguous", func(t *testing.T) {
+			// given
+			defer resetClusterCache()
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "host-b", ready))
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "host-a", ready))
+
+			// when
+			cluster, err := SelectHostCluster()
+
+			// then
+			require.ErrorIs(t, err, ErrAmbiguousHostCluster)
+			assert.EqualError(t, err, "ambiguous host cluster: multiple clusters without the host role: host-a, host-b")
+			assert.Nil(t, cluster)
+
+			t.Run("GetHostCluster falls back to the first cluster", func(t *testing.T) {
+				// when
+				cluster, ok := GetHostCluster()
+
+				// then
+				require.True(t, ok)
+				assert.Equal(t, "host-a", cluster.Name)
+			})
+		})
+
+		t.Run("not found in empty cache", func(t *testing.T) {
+			// given
+			defer resetClusterCache()
+
+			// when
+			cluster, err := SelectHostCluster()
+
+			// then
+			require.ErrorIs(t, err, ErrHostClusterNotFound)
+			assert.Nil(t, cluster)
+		})
+	})
+
+	t.Run("with cluster name", func(t *testing.T) {
+		// given
+		defer resetClusterCache()
+		clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "host-1", ready))
+		host := newTestCachedToolchainCluster(t, "host-2", ready)
+		clusterCache.addCachedToolchainCluster(host)
+
+		// when
+		cluster, err := SelectHostCluster(WithClusterName("host-2"))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, host, cluster)
+
+		t.Run("not found for unknown name", func(t *testing.T) {
+			// when
+			cluster, err := SelectHostCluster(WithClusterName("unknown"))
+
+			// then
+			require.ErrorIs(t, err, ErrHostClusterNotFound)
+			assert.Nil(t, cluster)
+		})
+	})
+
+	t.Run("with API endpoint", func(t *testing.T) {
+		// given
+		defer resetClusterCache()
+		clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "host-1", ready, withRole(Host), withAPIEndpoint("https://api.host-1.com:6443")))
+		host := newTestCachedToolchainCluster(t, "host-2", ready, withRole(Host), withAPIEndpoint("https://api.host-2.com:6443"))
+		clusterCache.addCachedToolchainCluster(host)
+
+		// when
+		cluster, err := SelectHostCluster(WithAPIEndpoint("https://api.host-2.com:6443/"))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, host, cluster)
+	})
+
+	t.Run("selected after refreshing the cache", func(t *testing.T) {
+		// given
+		cache := NewClusterCache()
+		host := newTestCachedToolchainCluster(t, "host", ready, withRole(Host))
+		cache.refreshCache = func() {
+			cache.addCachedToolchainCluster(host)
+		}
+
+		// when
+		cluster, err := cache.SelectHostCluster()
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, host, cluster)
+	})
+}
@@ -0,0 +1,99 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// InventoryAnnotationKey is the annotation of the ToolchainCluster with the JSON-encoded Inventory of the remote cluster.
+	// The ToolchainClusterStatus has no field for it, so the inventory is published in the annotation instead.
+	InventoryAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "inventory"
+
+	// NodeRoleLabelPrefix is the prefix of the labels with the roles of the nodes (eg. `node-role.kubernetes.io/worker`)
+	NodeRoleLabelPrefix = "node-role.kubernetes.io/"
+	// NoNodeRole is the role of the nodes without any role label
+	NoNodeRole = "none"
+)
+
+// Inventory contains the basic facts about a remote cluster collected by the ToolchainCluster controller. Only the facts
+// of the configured collectors are set.
+type Inventory struct {
+	// CollectedAt is the time of the collection which found the facts different from the previous ones
+	CollectedAt metav1.Time `json:"collectedAt"`
+	// KubernetesVersion is the git version of the API server (eg. `v1.31.4`)
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+	// OpenShiftVersion is the desired version of the OpenShift cluster, empty if the cluster is not an OpenShift one
+	OpenShiftVersion string `json:"openShiftVersion,omitempty"`
+	// NodeCount is the number of all the nodes
+	NodeCount int `json:"nodeCount,omitempty"`
+	// NodeRoles contains the capacity of the nodes by their roles. A node with several roles is counted in each of them,
+	// the nodes without any role are under the NoNodeRole key.
+	NodeRoles map[string]NodeRoleCapacity `json:"nodeRoles,omitempty"`
+	// CRDs contains the names of the checked CustomResourceDefinitions and whether they are installed
+	CRDs map[string]bool `json:"crds,omitempty"`
+	// Errors contains the errors of the collectors that failed, by the name of the collector
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// NodeRoleCapacity is the capacity of the nodes with the same role
+type NodeRoleCapacity struct {
+	Nodes int `json:"nodes"`
+	// AllocatableCPU is the sum of the allocatable CPU of the nodes
+	AllocatableCPU resource.Quantity `json:"allocatableCPU"`
+	// AllocatableMemory is the sum of the allocatable memory of the nodes
+	AllocatableMemory resource.Quantity `json:"allocatableMemory"`
+}
+
+// GetInventory returns the inventory published in the annotation of the ToolchainCluster, or nil if there is none
+func GetInventory(toolchainCluster *toolchainv1alpha1.ToolchainCluster) (*Inventory, error) {
+	value, found := toolchainCluster.Annotations[InventoryAnnotationKey]
+	if !found {
+		return nil, nil
+	}
+	inventory := &Inventory{}
+	if err := json.Unmarshal([]byte(value), inventory); err != nil {
+		return nil, fmt.Errorf("invalid inventory of the cluster %s: %w", toolchainCluster.Name, err)
+	}
+	return inventory, nil
+}
+
+// SetInventory publishes the inventory in the annotation of the ToolchainCluster and sets its collection time. The annotation
+// is not changed if the facts are the same as the published ones, so the ToolchainCluster doesn't have to be updated after
+// every collection. It returns true if the annotation was changed.
+func SetInventory(toolchainCluster *toolchainv1alpha1.ToolchainCluster, inventory Inventory) (bool, error) {
+	if current, err := GetInventory(toolchainCluster); err == nil && current != nil {
+		inventory.CollectedAt = current.CollectedAt
+		if sameInventory(*current, inventory) {
+			return false, nil
+		}
+	}
+	inventory.CollectedAt = metav1.Now()
+	value, err := json.Marshal(inventory)
+	if err != nil {
+		return false, fmt.Errorf("unable to encode the inventory of the cluster %s: %w", toolchainCluster.Name, err)
+	}
+	if toolchainCluster.Annotations == nil {
+		toolchainCluster.Annotations = map[string]string{}
+	}
+	toolchainCluster.Annotations[InventoryAnnotationKey] = string(value)
+	return true, nil
+}
+
+// sameInventory compares the inventories as they are published - the quantities are compared by their canonical form
+func sameInventory(inventory, other Inventory) bool {
+	value, err := json.Marshal(inventory)
+	if err != nil {
+		return false
+	}
+	otherValue, err := json.Marshal(other)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(value, otherValue)
+}
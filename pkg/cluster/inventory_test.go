@@ -0,0 +1,101 @@
+package cluster_test
+
+import (
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestInventory(t *testing.T) {
+	newInventory := func(cpu string) cluster.Inventory {
+		return cluster.Inventory{
+			KubernetesVersion: "v1.31.4",
+			NodeCount:         3,
+			NodeRoles: map[string]cluster.NodeRoleCapacity{
+				"worker": {Nodes: 3, AllocatableCPU: resource.MustParse(cpu), AllocatableMemory: resource.MustParse("48Gi")},
+			},
+			CRDs: map[string]bool{"virtualmachines.kubevirt.io": false},
+		}
+	}
+
+	t.Run("no inventory", func(t *testing.T) {
+		// given
+		tc := &toolchainv1alpha1.ToolchainCluster{}
+
+		// when
+		inventory, err := cluster.GetInventory(tc)
+
+		// then
+		require.NoError(t, err)
+		assert.Nil(t, inventory)
+	})
+
+	t.Run("invalid inventory", func(t *testing.T) {
+		// given
+		tc := &toolchainv1alpha1.ToolchainCluster{ObjectMeta: metav1.ObjectMeta{
+			Name:        "member",
+			Annotations: map[string]string{cluster.InventoryAnnotationKey: "{"},
+		}}
+
+		// when
+		_, err := cluster.GetInventory(tc)
+
+		// then
+		require.EqualError(t, err, "invalid inventory of the cluster member: unexpected end of JSON input")
+	})
+
+	t.Run("set and get inventory", func(t *testing.T) {
+		// given
+		tc := &toolchainv1alpha1.ToolchainCluster{ObjectMeta: metav1.ObjectMeta{Name: "member"}}
+
+		// when
+		changed, err := cluster.SetInventory(tc, newInventory("12"))
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, changed)
+		inventory, err := cluster.GetInventory(tc)
+		require.NoError(t, err)
+		require.NotNil(t, inventory)
+		assert.False(t, inventory.CollectedAt.IsZero())
+		assert.Equal(t, "v1.31.4", inventory.KubernetesVersion)
+		assert.Equal(t, 3, inventory.NodeCount)
+		assert.True(t, resource.MustParse("12").Equal(inventory.NodeRoles["worker"].AllocatableCPU))
+		assert.Equal(t, map[string]bool{"virtualmachines.kubevirt.io": false}, inventory.CRDs)
+
+		t.Run("same facts don't change the annotation", func(t *testing.T) {
+			// given
+			published := tc.Annotations[cluster.InventoryAnnotationKey]
+
+			// when
+			changed, err := cluster.SetInventory(tc, newInventory("12000m"))
+
+			// then
+			require.NoError(t, err)
+			assert.False(t, changed)
+			assert.Equal(t, published, tc.Annotations[cluster.InventoryAnnotationKey])
+		})
+
+		t.Run("different facts are published with the new collection time", func(t *testing.T) {
+			// given
+			tc.Annotations[cluster.InventoryAnnotationKey] = `{"collectedAt":"2020-01-01T00:00:00Z","kubernetesVersion":"v1.31.4"}`
+
+			// when
+			changed, err := cluster.SetInventory(tc, newInventory("16"))
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, changed)
+			updated, err := cluster.GetInventory(tc)
+			require.NoError(t, err)
+			assert.True(t, updated.CollectedAt.After(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)))
+			assert.True(t, resource.MustParse("16").Equal(updated.NodeRoles["worker"].AllocatableCPU))
+		})
+	})
+}
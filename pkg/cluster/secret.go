@@ -0,0 +1,129 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// The keys of the secret referenced by a ToolchainCluster. The secret contains either a full kubeconfig
+// or the split keys - the API URL, the operator namespace, the token (or the path to a token file) and optionally the CA certificate.
+const (
+	// KubeConfigSecretKey is the key of the kubeconfig. The token can be set either directly or via the `tokenFile` field
+	// pointing to a file that is refreshed on the disk (eg. a projected ServiceAccount token volume).
+	KubeConfigSecretKey = "kubeconfig"
+	// APIURLSecretKey is the key of the API URL of the cluster. The split keys are used only if there is no kubeconfig in the secret.
+	APIURLSecretKey = "api-url"
+	// TokenSecretKey is the key of the bearer token
+	TokenSecretKey = "token"
+	// TokenFileSecretKey is the key of the path to a file containing the bearer token. The file is re-read periodically,
+	// so it can be mounted from a projected ServiceAccount token volume. Ignored if the TokenSecretKey is set.
+	TokenFileSecretKey = "token-file"
+	// CACertSecretKey is the key of the PEM-encoded CA certificate of the API server
+	CACertSecretKey = "ca.crt"
+	// NamespaceSecretKey is the key of the namespace the operator is running in (in the cluster)
+	NamespaceSecretKey = "namespace"
+)
+
+// ErrUnsupportedAuthentication is returned when the credentials in the secret of a ToolchainCluster use an authentication
+// that cannot be resolved by the operator itself, ie. the exec plugins or the auth providers
+var ErrUnsupportedAuthentication = errors.New("unsupported authentication")
+
+func loadConfigFromSecret(toolchainCluster *toolchainv1alpha1.ToolchainCluster, secret *v1.Secret, timeout time.Duration) (*Config, error) {
+	var config *Config
+	var err error
+	switch {
+	case secret.Data[KubeConfigSecretKey] != nil:
+		config, err = loadConfigFromKubeConfig(toolchainCluster, secret, timeout)
+	case secret.Data[APIURLSecretKey] != nil:
+		config, err = loadConfigFromSplitKeys(toolchainCluster, secret, timeout)
+	default:
+		err = fmt.Errorf("the secret %s of the cluster %s contains neither the '%s' nor the '%s' key", secret.Name, toolchainCluster.Name, KubeConfigSecretKey, APIURLSecretKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := applyConnectionOverrides(toolchainCluster, secret, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func loadConfigFromSplitKeys(toolchainCluster *toolchainv1alpha1.ToolchainCluster, secret *v1.Secret, timeout time.Duration) (*Config, error) {
+	restCfg, err := restConfigFromSplitKeys(secret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret %s of the cluster %s: %w", secret.Name, toolchainCluster.Name, err)
+	}
+	restCfg.Timeout = timeout
+
+	return &Config{
+		Name:              toolchainCluster.Name,
+		APIEndpoint:       restCfg.Host,
+		RestConfig:        restCfg,
+		OperatorNamespace: string(secret.Data[NamespaceSecretKey]),
+		OwnerClusterName:  toolchainCluster.Labels[labelOwnerClusterName],
+		Labels:            toolchainCluster.Labels,
+	}, nil
+}
+
+func restConfigFromSplitKeys(secret *v1.Secret) (*rest.Config, error) {
+	restCfg := &rest.Config{
+		Host:            string(secret.Data[APIURLSecretKey]),
+		BearerToken:     string(secret.Data[TokenSecretKey]),
+		BearerTokenFile: string(secret.Data[TokenFileSecretKey]),
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: secret.Data[CACertSecretKey],
+		},
+	}
+	if restCfg.Host == "" {
+		return nil, fmt.Errorf("the '%s' key is empty", APIURLSecretKey)
+	}
+	if restCfg.BearerToken == "" && restCfg.BearerTokenFile == "" {
+		return nil, fmt.Errorf("neither the '%s' nor the '%s' key is set", TokenSecretKey, TokenFileSecretKey)
+	}
+	if restCfg.BearerToken != "" {
+		// the same precedence as in the kubeconfig
+		restCfg.BearerTokenFile = ""
+	}
+	return restCfg, nil
+}
+
+// validateAuthentication rejects the kubeconfigs whose current context uses an exec plugin or an auth provider - they require
+// binaries or credentials that are not available in the operator pod, so the resulting client would fail on every call.
+// The other users of the kubeconfig are never used, so they are not checked.
+func validateAuthentication(kubeConfig *clientcmdapi.Config) error {
+	kubeContext, found := kubeConfig.Contexts[kubeConfig.CurrentContext]
+	if !found || kubeContext == nil {
+		// the missing context is reported when the client config is created
+		return nil
+	}
+	authInfo := kubeConfig.AuthInfos[kubeContext.AuthInfo]
+	if authInfo == nil {
+		return nil
+	}
+	if authInfo.Exec != nil {
+		return fmt.Errorf("%w: the user '%s' uses the exec plugin '%s', use a token or a client certificate instead", ErrUnsupportedAuthentication, kubeContext.AuthInfo, authInfo.Exec.Command)
+	}
+	if authInfo.AuthProvider != nil {
+		return fmt.Errorf("%w: the user '%s' uses the auth provider '%s', use a token or a client certificate instead", ErrUnsupportedAuthentication, kubeContext.AuthInfo, authInfo.AuthProvider.Name)
+	}
+	return nil
+}
+
+// loadKubeConfig loads and validates the kubeconfig stored in the secret
+func loadKubeConfig(secret *v1.Secret) (*clientcmdapi.Config, error) {
+	kubeConfig, err := clientcmd.Load(secret.Data[KubeConfigSecretKey])
+	if err != nil {
+		return nil, err
+	}
+	if err := validateAuthentication(kubeConfig); err != nil {
+		return nil, err
+	}
+	return kubeConfig, nil
+}
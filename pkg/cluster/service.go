@@ -3,7 +3,6 @@ package cluster
 import (
 	"context"
 	"fmt"
-	"reflect"
 	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
@@ -33,30 +32,58 @@ type ToolchainClusterService struct {
 	namespace string
 	timeout   time.Duration
 	newClient NewClient
+	// cache is the cache managed by the service, the default cache is used if nil
+	cache *ClusterCache
 }
 
 type NewClient func(config *rest.Config, options client.Options) (client.Client, error)
 
+// ServiceOption configures the ToolchainClusterService
+type ServiceOption func(service *ToolchainClusterService)
+
+// WithClusterCache sets the cache managed by the ToolchainClusterService. The DefaultClusterCache is used if not set.
+func WithClusterCache(cache *ClusterCache) ServiceOption {
+	return func(service *ToolchainClusterService) {
+		service.cache = cache
+	}
+}
+
 // NewToolchainClusterServiceWithClient creates a new instance of ToolchainClusterService object and assigns the given newClient function to be used for creating a client
-func NewToolchainClusterServiceWithClient(client client.Client, log logr.Logger, namespace string, timeout time.Duration, newClient NewClient) ToolchainClusterService {
-	service := NewToolchainClusterService(client, log, namespace, timeout)
+func NewToolchainClusterServiceWithClient(client client.Client, log logr.Logger, namespace string, timeout time.Duration, newClient NewClient, options ...ServiceOption) ToolchainClusterService {
+	service := NewToolchainClusterService(client, log, namespace, timeout, options...)
 	service.newClient = newClient
-	clusterCache.refreshCache = service.refreshCache
+	service.clusterCache().refreshCache = service.refreshCache
 	return service
 }
 
 // NewToolchainClusterService creates a new instance of ToolchainClusterService object and assigns the refreshCache function to the cache instance
-func NewToolchainClusterService(client client.Client, log logr.Logger, namespace string, timeout time.Duration) ToolchainClusterService {
+func NewToolchainClusterService(client client.Client, log logr.Logger, namespace string, timeout time.Duration, options ...ServiceOption) ToolchainClusterService {
 	service := ToolchainClusterService{
 		client:    client,
 		log:       log,
 		namespace: namespace,
 		timeout:   timeout,
 	}
-	clusterCache.refreshCache = service.refreshCache
+	for _, apply := range options {
+		apply(&service)
+	}
+	service.clusterCache().refreshCache = service.refreshCache
 	return service
 }
 
+// Cache returns the cache managed by the service
+func (s *ToolchainClusterService) Cache() *ClusterCache {
+	return s.clusterCache()
+}
+
+func (s *ToolchainClusterService) clusterCache() *ClusterCache {
+	if s.cache != nil {
+		return s.cache
+	}
+	// resolved on every call, so the service always uses the current default cache
+	return clusterCache
+}
+
 // AddOrUpdateToolchainCluster takes the ToolchainCluster CR object,
 // creates CachedToolchainCluster with a kube client and stores it in a cache
 func (s *ToolchainClusterService) AddOrUpdateToolchainCluster(cluster *toolchainv1alpha1.ToolchainCluster) error {
@@ -83,12 +110,13 @@ func (s *ToolchainClusterService) addToolchainCluster(log logr.Logger, toolchain
 	}
 
 	var cl client.Client
+	var clients *clusterClients
 	// check if there is already a cached ToolchainCluster so we could reuse the client
 	// we cannot allow to refresh the cache, because the refresh function calls this addToolchainCluster method which results in a recursive loop
-	cachedToolchainCluster, exists := clusterCache.getCachedToolchainCluster(toolchainCluster.Name, false)
+	cachedToolchainCluster, exists := s.clusterCache().getCachedToolchainCluster(toolchainCluster.Name, false)
 	if !exists ||
 		cachedToolchainCluster.Client == nil ||
-		!reflect.DeepEqual(clusterConfig.RestConfig, cachedToolchainCluster.RestConfig) {
+		!sameRestConfig(clusterConfig, cachedToolchainCluster.Config) {
 
 		log.Info("creating new client for the cached ToolchainCluster")
 		scheme := runtime.NewScheme()
@@ -107,22 +135,29 @@ func (s *ToolchainClusterService) addToolchainCluster(log logr.Logger, toolchain
 		if err != nil {
 			return errors.Wrap(err, "cannot create ToolchainCluster client")
 		}
+		// all the other clients are recreated for the new rest config as well
+		clients = newClusterClients(clusterConfig.RestConfig)
 	} else {
 		// log.Info("reusing the client for the cached ToolchainCluster")
 		cl = cachedToolchainCluster.Client
+		clients = cachedToolchainCluster.clients
+		if clients == nil {
+			clients = newClusterClients(clusterConfig.RestConfig)
+		}
 	}
 
 	cluster := &CachedToolchainCluster{
 		Config:        clusterConfig,
 		Client:        cl,
 		ClusterStatus: &toolchainCluster.Status,
+		clients:       clients,
 	}
 
 	if cluster.OperatorNamespace == "" {
 		return fmt.Errorf("the operator namespace is not set for the ToolchainCluster CR")
 	}
 
-	clusterCache.addCachedToolchainCluster(cluster)
+	s.clusterCache().addCachedToolchainCluster(cluster)
 	return nil
 }
 
@@ -130,7 +165,7 @@ func (s *ToolchainClusterService) addToolchainCluster(log logr.Logger, toolchain
 // and deletes CachedToolchainCluster instance that has same name from a cache (if exists)
 func (s *ToolchainClusterService) DeleteToolchainCluster(name string) {
 	s.log.WithValues("Request.Name", name).Info("observed a deleted cluster")
-	clusterCache.deleteCachedToolchainCluster(name)
+	s.clusterCache().deleteCachedToolchainCluster(name)
 }
 
 func (s *ToolchainClusterService) refreshCache() {
@@ -169,11 +204,11 @@ func NewClusterConfig(cl client.Client, toolchainCluster *toolchainv1alpha1.Tool
 		return nil, fmt.Errorf("unable to get secret %s for cluster %s: %w", name, toolchainCluster.Name, err)
 	}
 
-	return loadConfigFromKubeConfig(toolchainCluster, secret, timeout)
+	return loadConfigFromSecret(toolchainCluster, secret, timeout)
 }
 
 func loadConfigFromKubeConfig(toolchainCluster *toolchainv1alpha1.ToolchainCluster, secret *v1.Secret, timeout time.Duration) (*Config, error) {
-	cfg, err := clientcmd.Load(secret.Data["kubeconfig"])
+	cfg, err := loadKubeConfig(secret)
 	if err != nil {
 		return nil, err
 	}
@@ -3,6 +3,8 @@ package cluster_test
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -225,4 +227,202 @@ func TestNewClusterConfig(t *testing.T) {
 		assert.Equal(t, "operatorns", cfg.OperatorNamespace)
 		assert.Equal(t, "token", cfg.RestConfig.BearerToken)
 	})
+
+	t.Run("using kubeconfig with token file", func(t *testing.T) {
+		// given
+		tc := tc()
+		secret := kubeconfigSecret(t)
+		// the file is read when the config is loaded
+		tokenFile := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(tokenFile, []byte("projected-token"), 0600))
+		kubeconfig, err := clientcmd.Load(secret.Data["kubeconfig"])
+		require.NoError(t, err)
+		kubeconfig.AuthInfos["auth"] = &clientcmdapi.AuthInfo{TokenFile: tokenFile}
+		secret.Data["kubeconfig"], err = clientcmd.Write(*kubeconfig)
+		require.NoError(t, err)
+		cl := test.NewFakeClient(t, tc, secret)
+
+		// when
+		cfg, err := cluster.NewClusterConfig(cl, tc, 1*time.Second)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, tokenFile, cfg.RestConfig.BearerTokenFile)
+		assert.Equal(t, "projected-token", cfg.RestConfig.BearerToken)
+	})
+
+	t.Run("kubeconfig with unsupported authentication is rejected", func(t *testing.T) {
+		for name, authInfo := range map[string]*clientcmdapi.AuthInfo{
+			"exec plugin": {
+				Exec: &clientcmdapi.ExecConfig{Command: "oc-login", APIVersion: "client.authentication.k8s.io/v1"},
+			},
+			"auth provider": {
+				AuthProvider: &clientcmdapi.AuthProviderConfig{Name: "oidc"},
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				// given
+				tc := tc()
+				secret := kubeconfigSecret(t)
+				kubeconfig, err := clientcmd.Load(secret.Data["kubeconfig"])
+				require.NoError(t, err)
+				kubeconfig.AuthInfos["auth"] = authInfo
+				secret.Data["kubeconfig"], err = clientcmd.Write(*kubeconfig)
+				require.NoError(t, err)
+				cl := test.NewFakeClient(t, tc, secret)
+
+				// when
+				_, err = cluster.NewClusterConfig(cl, tc, 1*time.Second)
+
+				// then
+				require.ErrorIs(t, err, cluster.ErrUnsupportedAuthentication)
+			})
+		}
+
+		t.Run("with a clear message", func(t *testing.T) {
+			// given
+			tc := tc()
+			secret := kubeconfigSecret(t)
+			kubeconfig, err := clientcmd.Load(secret.Data["kubeconfig"])
+			require.NoError(t, err)
+			kubeconfig.AuthInfos["auth"] = &clientcmdapi.AuthInfo{AuthProvider: &clientcmdapi.AuthProviderConfig{Name: "oidc"}}
+			secret.Data["kubeconfig"], err = clientcmd.Write(*kubeconfig)
+			require.NoError(t, err)
+			cl := test.NewFakeClient(t, tc, secret)
+
+			// when
+			_, err = cluster.NewClusterConfig(cl, tc, 1*time.Second)
+
+			// then
+			require.EqualError(t, err, "unsupported authentication: the user 'auth' uses the auth provider 'oidc', use a token or a client certificate instead")
+		})
+	})
+
+	t.Run("unsupported authentication of an unused user is ignored", func(t *testing.T) {
+		// given
+		tc := tc()
+		secret := kubeconfigSecret(t)
+		kubeconfig, err := clientcmd.Load(secret.Data["kubeconfig"])
+		require.NoError(t, err)
+		kubeconfig.AuthInfos["unused"] = &clientcmdapi.AuthInfo{
+			Exec: &clientcmdapi.ExecConfig{Command: "oc-login", APIVersion: "client.authentication.k8s.io/v1"},
+		}
+		secret.Data["kubeconfig"], err = clientcmd.Write(*kubeconfig)
+		require.NoError(t, err)
+		cl := test.NewFakeClient(t, tc, secret)
+
+		// when
+		cfg, err := cluster.NewClusterConfig(cl, tc, 1*time.Second)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "token", cfg.RestConfig.BearerToken)
+	})
+
+	splitKeysSecret := func(data map[string]string) *corev1.Secret {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "ns",
+			},
+			Data: map[string][]byte{},
+		}
+		for key, value := range data {
+			secret.Data[key] = []byte(value)
+		}
+		return secret
+	}
+
+	t.Run("using split keys in secret", func(t *testing.T) {
+		t.Run("with token", func(t *testing.T) {
+			// given
+			tc := tc()
+			secret := splitKeysSecret(map[string]string{
+				cluster.APIURLSecretKey:    "https://over.the.rainbow",
+				cluster.TokenSecretKey:     "token",
+				cluster.TokenFileSecretKey: "/var/run/secrets/tokens/toolchaincluster",
+				cluster.CACertSecretKey:    "ca-data",
+				cluster.NamespaceSecretKey: "operatorns",
+			})
+			cl := test.NewFakeClient(t, tc, secret)
+
+			// when
+			cfg, err := cluster.NewClusterConfig(cl, tc, 1*time.Second)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, "https://over.the.rainbow", cfg.APIEndpoint)
+			assert.Equal(t, "operatorns", cfg.OperatorNamespace)
+			assert.Equal(t, "token", cfg.RestConfig.BearerToken)
+			// the token takes precedence
+			assert.Empty(t, cfg.RestConfig.BearerTokenFile)
+			assert.Equal(t, []byte("ca-data"), cfg.RestConfig.CAData)
+			assert.Equal(t, 1*time.Second, cfg.RestConfig.Timeout)
+		})
+
+		t.Run("with token file", func(t *testing.T) {
+			// given
+			tc := tc()
+			secret := splitKeysSecret(map[string]string{
+				cluster.APIURLSecretKey:    "https://over.the.rainbow",
+				cluster.TokenFileSecretKey: "/var/run/secrets/tokens/toolchaincluster",
+				cluster.NamespaceSecretKey: "operatorns",
+			})
+			cl := test.NewFakeClient(t, tc, secret)
+
+			// when
+			cfg, err := cluster.NewClusterConfig(cl, tc, 1*time.Second)
+
+			// then
+			require.NoError(t, err)
+			assert.Empty(t, cfg.RestConfig.BearerToken)
+			assert.Equal(t, "/var/run/secrets/tokens/toolchaincluster", cfg.RestConfig.BearerTokenFile)
+			assert.Empty(t, cfg.RestConfig.CAData)
+		})
+
+		t.Run("without token", func(t *testing.T) {
+			// given
+			tc := tc()
+			secret := splitKeysSecret(map[string]string{
+				cluster.APIURLSecretKey:    "https://over.the.rainbow",
+				cluster.NamespaceSecretKey: "operatorns",
+			})
+			cl := test.NewFakeClient(t, tc, secret)
+
+			// when
+			_, err := cluster.NewClusterConfig(cl, tc, 1*time.Second)
+
+			// then
+			require.EqualError(t, err, "invalid secret secret of the cluster tc: neither the 'token' nor the 'token-file' key is set")
+		})
+
+		t.Run("with empty API URL", func(t *testing.T) {
+			// given
+			tc := tc()
+			secret := splitKeysSecret(map[string]string{
+				cluster.APIURLSecretKey: "",
+				cluster.TokenSecretKey:  "token",
+			})
+			cl := test.NewFakeClient(t, tc, secret)
+
+			// when
+			_, err := cluster.NewClusterConfig(cl, tc, 1*time.Second)
+
+			// then
+			require.EqualError(t, err, "invalid secret secret of the cluster tc: the 'api-url' key is empty")
+		})
+	})
+
+	t.Run("secret without kubeconfig and API URL", func(t *testing.T) {
+		// given
+		tc := tc()
+		secret := splitKeysSecret(map[string]string{cluster.TokenSecretKey: "token"})
+		cl := test.NewFakeClient(t, tc, secret)
+
+		// when
+		_, err := cluster.NewClusterConfig(cl, tc, 1*time.Second)
+
+		// then
+		require.EqualError(t, err, "the secret secret of the cluster tc contains neither the 'kubeconfig' nor the 'api-url' key")
+	})
 }
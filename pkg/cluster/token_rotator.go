@@ -0,0 +1,235 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	commonclient "github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/golang-jwt/jwt/v5"
+	authv1 "k8s.io/api/authentication/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// TokenExpiryAnnotationKey is set on the kubeconfig secret of a ToolchainCluster when the token is rotated. It contains
+	// the expiration time of the token in the RFC3339 format, so it's known even if the minted token is not a JWT.
+	TokenExpiryAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "token-expiry"
+
+	// DefaultTokenExpiration is the default expiration of the minted tokens
+	DefaultTokenExpiration = 30 * 24 * time.Hour
+	// DefaultTokenRotationThreshold is the default remaining lifetime of a token below which the token is rotated
+	DefaultTokenRotationThreshold = 10 * 24 * time.Hour
+
+	serviceAccountSubjectPrefix = "system:serviceaccount:"
+)
+
+// TokenRotator keeps the tokens in the secrets of the ToolchainClusters fresh. When the token is close to its expiry,
+// a new token is minted for the operator ServiceAccount on the remote cluster via the TokenRequest API, the `kubeconfig`
+// (or the `token`) key of the secret is updated and the cached cluster is refreshed with the new rest config.
+// The tokens read from a file are not rotated.
+type TokenRotator struct {
+	service            *ToolchainClusterService
+	expiration         time.Duration
+	threshold          time.Duration
+	serviceAccountName string
+	now                func() time.Time
+}
+
+// TokenRotatorOption configures the TokenRotator
+type TokenRotatorOption func(rotator *TokenRotator)
+
+// TokenExpiration sets the expiration of the minted tokens
+func TokenExpiration(expiration time.Duration) TokenRotatorOption {
+	return func(rotator *TokenRotator) {
+		rotator.expiration = expiration
+	}
+}
+
+// TokenRotationThreshold sets the remaining lifetime of a token below which the token is rotated
+func TokenRotationThreshold(threshold time.Duration) TokenRotatorOption {
+	return func(rotator *TokenRotator) {
+		rotator.threshold = threshold
+	}
+}
+
+// TokenServiceAccount sets the name of the ServiceAccount in the operator namespace of the remote cluster the tokens are minted for.
+// If not set, the ServiceAccount is taken from the subject of the current token.
+func TokenServiceAccount(name string) TokenRotatorOption {
+	return func(rotator *TokenRotator) {
+		rotator.serviceAccountName = name
+	}
+}
+
+// NewTokenRotator creates a new TokenRotator updating the secrets and the cached clusters via the given service
+func NewTokenRotator(service *ToolchainClusterService, options ...TokenRotatorOption) *TokenRotator {
+	rotator := &TokenRotator{
+		service:    service,
+		expiration: DefaultTokenExpiration,
+		threshold:  DefaultTokenRotationThreshold,
+		now:        time.Now,
+	}
+	for _, apply := range options {
+		apply(rotator)
+	}
+	return rotator
+}
+
+// RotateIfNeeded rotates the token in the secret of the given ToolchainCluster if the token expires within the rotation
+// threshold. The expiry is taken from the `exp` claim of the token or from the TokenExpiryAnnotationKey annotation of the secret.
+// Tokens with an unknown expiry are not rotated. Returns true if the token was rotated.
+func (r *TokenRotator) RotateIfNeeded(ctx context.Context, toolchainCluster *toolchainv1alpha1.ToolchainCluster) (bool, error) {
+	secret := &v1.Secret{}
+	if err := r.service.client.Get(ctx, types.NamespacedName{Namespace: toolchainCluster.Namespace, Name: toolchainCluster.Spec.SecretRef.Name}, secret); err != nil {
+		return false, fmt.Errorf("unable to get the secret of the cluster %s: %w", toolchainCluster.Name, err)
+	}
+	current, err := tokenOf(toolchainCluster, secret)
+	if err != nil {
+		return false, fmt.Errorf("unable to load the token of the cluster %s: %w", toolchainCluster.Name, err)
+	}
+	if current == nil {
+		// nothing to rotate
+		return false, nil
+	}
+
+	expiry := TokenExpiry(current.token)
+	if expiry == nil {
+		if annotation, found := secret.Annotations[TokenExpiryAnnotationKey]; found {
+			if annotated, err := time.Parse(time.RFC3339, annotation); err == nil {
+				expiry = &annotated
+			}
+		}
+	}
+	if expiry == nil || expiry.Sub(r.now()) > r.threshold {
+		return false, nil
+	}
+
+	serviceAccount, err := r.serviceAccount(current.token, current.namespace)
+	if err != nil {
+		return false, fmt.Errorf("unable to rotate the token of the cluster %s: %w", toolchainCluster.Name, err)
+	}
+	restConfig, err := current.restConfig()
+	if err != nil {
+		return false, fmt.Errorf("unable to create the rest config of the cluster %s: %w", toolchainCluster.Name, err)
+	}
+	restClient, err := newTokenRequestRESTClient(restConfig)
+	if err != nil {
+		return false, fmt.Errorf("unable to create the rest client of the cluster %s: %w", toolchainCluster.Name, err)
+	}
+	newExpiry := r.now().Add(r.expiration)
+	token, err := commonclient.CreateTokenRequest(ctx, restClient, serviceAccount, int(r.expiration.Seconds()))
+	if err != nil {
+		return false, fmt.Errorf("unable to create a token for the service account %s in the cluster %s: %w", serviceAccount, toolchainCluster.Name, err)
+	}
+
+	if err := current.set(token); err != nil {
+		return false, fmt.Errorf("unable to set the token of the cluster %s: %w", toolchainCluster.Name, err)
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[TokenExpiryAnnotationKey] = newExpiry.UTC().Format(time.RFC3339)
+	if err := r.service.client.Update(ctx, secret); err != nil {
+		return false, fmt.Errorf("unable to update the secret of the cluster %s: %w", toolchainCluster.Name, err)
+	}
+	r.service.enrichLogger(toolchainCluster).Info("rotated the token of the cluster", "serviceAccount", serviceAccount.String(), "expiry", newExpiry)
+
+	// the cached cluster gets a new client, because the rest config changed
+	if err := r.service.AddOrUpdateToolchainCluster(toolchainCluster); err != nil {
+		return true, fmt.Errorf("the token of the cluster %s was rotated, but the cache was not refreshed: %w", toolchainCluster.Name, err)
+	}
+	return true, nil
+}
+
+// serviceAccount returns the ServiceAccount the new token should be minted for
+func (r *TokenRotator) serviceAccount(token, operatorNamespace string) (types.NamespacedName, error) {
+	if r.serviceAccountName != "" {
+		return types.NamespacedName{Namespace: operatorNamespace, Name: r.serviceAccountName}, nil
+	}
+	claims := &jwt.RegisteredClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err == nil && strings.HasPrefix(claims.Subject, serviceAccountSubjectPrefix) {
+		namespace, name, found := strings.Cut(strings.TrimPrefix(claims.Subject, serviceAccountSubjectPrefix), ":")
+		if found && namespace != "" && name != "" {
+			return types.NamespacedName{Namespace: namespace, Name: name}, nil
+		}
+	}
+	return types.NamespacedName{}, fmt.Errorf("unable to determine the service account from the token and no service account is configured")
+}
+
+// secretToken is the token stored in the secret of a ToolchainCluster, either in the kubeconfig or in the split keys
+type secretToken struct {
+	token string
+	// namespace is the operator namespace
+	namespace  string
+	restConfig func() (*rest.Config, error)
+	// set replaces the token in the secret, the rest of the secret is kept
+	set func(token string) error
+}
+
+// tokenOf returns the token stored in the secret or nil if there is no token that could be rotated
+// (eg. the token is read from a file or a client certificate is used)
+func tokenOf(toolchainCluster *toolchainv1alpha1.ToolchainCluster, secret *v1.Secret) (*secretToken, error) {
+	// the same connection as the one of the cached cluster (eg. including the proxy)
+	restConfig := func() (*rest.Config, error) {
+		config, err := loadConfigFromSecret(toolchainCluster, secret, 0)
+		if err != nil {
+			return nil, err
+		}
+		return config.RestConfig, nil
+	}
+	if _, found := secret.Data[KubeConfigSecretKey]; !found {
+		token := string(secret.Data[TokenSecretKey])
+		if token == "" {
+			return nil, nil
+		}
+		return &secretToken{
+			token:      token,
+			namespace:  string(secret.Data[NamespaceSecretKey]),
+			restConfig: restConfig,
+			set: func(token string) error {
+				secret.Data[TokenSecretKey] = []byte(token)
+				return nil
+			},
+		}, nil
+	}
+
+	kubeConfig, err := loadKubeConfig(secret)
+	if err != nil {
+		return nil, err
+	}
+	kubeContext, found := kubeConfig.Contexts[kubeConfig.CurrentContext]
+	if !found {
+		return nil, fmt.Errorf("the current context '%s' not found in the kubeconfig", kubeConfig.CurrentContext)
+	}
+	authInfo, found := kubeConfig.AuthInfos[kubeContext.AuthInfo]
+	if !found || authInfo.Token == "" {
+		return nil, nil
+	}
+	return &secretToken{
+		token:      authInfo.Token,
+		namespace:  kubeContext.Namespace,
+		restConfig: restConfig,
+		set: func(token string) error {
+			authInfo.Token = token
+			data, err := clientcmd.Write(*kubeConfig)
+			if err != nil {
+				return err
+			}
+			secret.Data[KubeConfigSecretKey] = data
+			return nil
+		},
+	}, nil
+}
+
+func newTokenRequestRESTClient(restConfig *rest.Config) (*rest.RESTClient, error) {
+	config := rest.CopyConfig(restConfig)
+	config.GroupVersion = &authv1.SchemeGroupVersion
+	config.NegotiatedSerializer = scheme.Codecs
+	return rest.RESTClientFor(config)
+}
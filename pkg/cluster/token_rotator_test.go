@@ -0,0 +1,223 @@
+package cluster_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestTokenRotator(t *testing.T) {
+	serviceAccount := types.NamespacedName{Namespace: test.MemberOperatorNs, Name: "toolchaincluster-member"}
+
+	setup := func(t *testing.T, token string, annotations map[string]string) (*test.FakeClient, *toolchainv1alpha1.ToolchainCluster, *cluster.ToolchainClusterService) {
+		t.Cleanup(gock.OffAll)
+		toolchainCluster, secret := test.NewToolchainCluster(t, "east", test.HostOperatorNs, test.MemberOperatorNs, "secret", toolchainv1alpha1.ToolchainClusterStatus{}, false)
+		test.SetKubeConfigToken(t, secret, token)
+		secret.Annotations = annotations
+		cl := test.NewFakeClient(t, toolchainCluster, secret)
+		service := cluster.NewToolchainClusterService(cl, logf.Log, test.HostOperatorNs, 0)
+		require.NoError(t, service.AddOrUpdateToolchainCluster(toolchainCluster))
+		t.Cleanup(func() {
+			service.DeleteToolchainCluster("east")
+		})
+		return cl, toolchainCluster, &service
+	}
+
+	t.Run("token close to expiry is rotated", func(t *testing.T) {
+		// given
+		cl, toolchainCluster, service := setup(t, test.NewServiceAccountToken(t, time.Now().Add(time.Hour)), nil)
+		test.SetupGockForServiceAccounts(t, "https://cluster.com", serviceAccount)
+		rotator := cluster.NewTokenRotator(service, cluster.TokenExpiration(20*24*time.Hour))
+
+		// when
+		rotated, err := rotator.RotateIfNeeded(context.TODO(), toolchainCluster)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, rotated)
+		secret := assertSecretToken(t, cl, "token-secret-for-toolchaincluster-member")
+		expiry, err := time.Parse(time.RFC3339, secret.Annotations[cluster.TokenExpiryAnnotationKey])
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(20*24*time.Hour), expiry, time.Minute)
+		// the rest of the kubeconfig is kept
+		kubeConfig, err := clientcmd.Load(secret.Data["kubeconfig"])
+		require.NoError(t, err)
+		assert.Equal(t, "https://cluster.com", kubeConfig.Clusters[kubeConfig.Contexts[kubeConfig.CurrentContext].Cluster].Server)
+		assert.Equal(t, test.MemberOperatorNs, kubeConfig.Contexts[kubeConfig.CurrentContext].Namespace)
+		// the cache uses the new token
+		cachedCluster, found := cluster.GetCachedToolchainCluster("east")
+		require.True(t, found)
+		assert.Equal(t, "token-secret-for-toolchaincluster-member", cachedCluster.RestConfig.BearerToken)
+
+		t.Run("rotated token is not rotated again", func(t *testing.T) {
+			// when
+			rotated, err := rotator.RotateIfNeeded(context.TODO(), toolchainCluster)
+
+			// then
+			require.NoError(t, err)
+			assert.False(t, rotated)
+		})
+	})
+
+	t.Run("token not close to expiry is kept", func(t *testing.T) {
+		// given
+		token := test.NewServiceAccountToken(t, time.Now().Add(30*24*time.Hour))
+		cl, toolchainCluster, service := setup(t, token, nil)
+		rotator := cluster.NewTokenRotator(service)
+
+		// when
+		rotated, err := rotator.RotateIfNeeded(context.TODO(), toolchainCluster)
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, rotated)
+		assertSecretToken(t, cl, token)
+	})
+
+	t.Run("token with unknown expiry is kept", func(t *testing.T) {
+		// given
+		cl, toolchainCluster, service := setup(t, "mycooltoken", nil)
+		rotator := cluster.NewTokenRotator(service)
+
+		// when
+		rotated, err := rotator.RotateIfNeeded(context.TODO(), toolchainCluster)
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, rotated)
+		assertSecretToken(t, cl, "mycooltoken")
+	})
+
+	t.Run("annotated expiry and configured service account", func(t *testing.T) {
+		// given
+		cl, toolchainCluster, service := setup(t, "mycooltoken", map[string]string{
+			cluster.TokenExpiryAnnotationKey: time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		})
+		test.SetupGockForServiceAccounts(t, "https://cluster.com", types.NamespacedName{Namespace: test.MemberOperatorNs, Name: "member-sa"})
+		rotator := cluster.NewTokenRotator(service, cluster.TokenServiceAccount("member-sa"))
+
+		// when
+		rotated, err := rotator.RotateIfNeeded(context.TODO(), toolchainCluster)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, rotated)
+		assertSecretToken(t, cl, "token-secret-for-member-sa")
+	})
+
+	t.Run("token in split keys is rotated", func(t *testing.T) {
+		// given
+		cl, toolchainCluster, service := setup(t, "mycooltoken", nil)
+		secret := &corev1.Secret{}
+		require.NoError(t, cl.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, "secret"), secret))
+		secret.Data = map[string][]byte{
+			cluster.APIURLSecretKey:    []byte("https://cluster.com"),
+			cluster.TokenSecretKey:     []byte(test.NewServiceAccountToken(t, time.Now().Add(time.Hour))),
+			cluster.NamespaceSecretKey: []byte(test.MemberOperatorNs),
+		}
+		require.NoError(t, cl.Update(context.TODO(), secret))
+		test.SetupGockForServiceAccounts(t, "https://cluster.com", serviceAccount)
+		rotator := cluster.NewTokenRotator(service, cluster.TokenExpiration(20*24*time.Hour))
+
+		// when
+		rotated, err := rotator.RotateIfNeeded(context.TODO(), toolchainCluster)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, rotated)
+		require.NoError(t, cl.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, "secret"), secret))
+		assert.Equal(t, "token-secret-for-toolchaincluster-member", string(secret.Data[cluster.TokenSecretKey]))
+		assert.Equal(t, "https://cluster.com", string(secret.Data[cluster.APIURLSecretKey]))
+		assert.NotContains(t, secret.Data, cluster.KubeConfigSecretKey)
+		cachedCluster, found := cluster.GetCachedToolchainCluster("east")
+		require.True(t, found)
+		assert.Equal(t, "token-secret-for-toolchaincluster-member", cachedCluster.RestConfig.BearerToken)
+
+		t.Run("token file is not rotated", func(t *testing.T) {
+			// given
+			delete(secret.Data, cluster.TokenSecretKey)
+			secret.Data[cluster.TokenFileSecretKey] = []byte("/var/run/secrets/tokens/toolchaincluster")
+			require.NoError(t, cl.Update(context.TODO(), secret))
+
+			// when
+			rotated, err := rotator.RotateIfNeeded(context.TODO(), toolchainCluster)
+
+			// then
+			require.NoError(t, err)
+			assert.False(t, rotated)
+		})
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		t.Run("unknown service account", func(t *testing.T) {
+			// given
+			cl, toolchainCluster, service := setup(t, "mycooltoken", map[string]string{
+				cluster.TokenExpiryAnnotationKey: time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+			})
+			rotator := cluster.NewTokenRotator(service)
+
+			// when
+			rotated, err := rotator.RotateIfNeeded(context.TODO(), toolchainCluster)
+
+			// then
+			require.EqualError(t, err, "unable to rotate the token of the cluster east: unable to determine the service account from the token and no service account is configured")
+			assert.False(t, rotated)
+			assertSecretToken(t, cl, "mycooltoken")
+		})
+
+		t.Run("token request fails", func(t *testing.T) {
+			// given
+			token := test.NewServiceAccountToken(t, time.Now().Add(time.Hour))
+			cl, toolchainCluster, service := setup(t, token, nil)
+			test.SetupGockWithCleanup(t, "https://cluster.com", "api/v1/namespaces/toolchain-member-operator/serviceaccounts/toolchaincluster-member/token", `{"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "Forbidden", "code": 403}`, http.StatusForbidden)
+			rotator := cluster.NewTokenRotator(service)
+
+			// when
+			rotated, err := rotator.RotateIfNeeded(context.TODO(), toolchainCluster)
+
+			// then
+			require.ErrorContains(t, err, "unable to create a token for the service account toolchain-member-operator/toolchaincluster-member in the cluster east")
+			assert.False(t, rotated)
+			assertSecretToken(t, cl, token)
+		})
+
+		t.Run("missing secret", func(t *testing.T) {
+			// given
+			cl, toolchainCluster, service := setup(t, "mycooltoken", nil)
+			require.NoError(t, cl.Delete(context.TODO(), &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: test.HostOperatorNs}}))
+			rotator := cluster.NewTokenRotator(service)
+
+			// when
+			_, err := rotator.RotateIfNeeded(context.TODO(), toolchainCluster)
+
+			// then
+			require.ErrorContains(t, err, "unable to get the secret of the cluster east")
+		})
+	})
+}
+
+func assertSecretToken(t *testing.T, cl client.Client, expectedToken string) *corev1.Secret {
+	t.Helper()
+	secret := &corev1.Secret{}
+	require.NoError(t, cl.Get(context.TODO(), test.NamespacedName(test.HostOperatorNs, "secret"), secret))
+	kubeConfig, err := clientcmd.Load(secret.Data["kubeconfig"])
+	require.NoError(t, err)
+	for _, authInfo := range kubeConfig.AuthInfos {
+		assert.Equal(t, expectedToken, authInfo.Token)
+	}
+	return secret
+}
@@ -0,0 +1,117 @@
+package owners
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	// OwnerCacheHits counts the owner lookups served from the cache of the OwnerFetcher
+	OwnerCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sandbox_owner_fetcher_cache_hits_total",
+		Help: "Number of owner lookups served from the cache of the owner fetcher",
+	})
+	// OwnerCacheMisses counts the owner lookups that were not found in the cache of the OwnerFetcher and were fetched from the cluster
+	OwnerCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sandbox_owner_fetcher_cache_misses_total",
+		Help: "Number of owner lookups fetched from the cluster because they were not in the cache of the owner fetcher",
+	})
+)
+
+// RegisterMetrics registers the metrics of the owner fetcher in the given registry (eg. the controller-runtime metrics.Registry)
+func RegisterMetrics(registry prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{OwnerCacheHits, OwnerCacheMisses} {
+		if err := registry.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type ownerCacheKey struct {
+	gvr       schema.GroupVersionResource
+	namespace string
+	name      string
+}
+
+type ownerCacheEntry struct {
+	owner     *ObjectWithGVR
+	err       error
+	expiresAt time.Time
+}
+
+// ownerCache keeps the fetched owners, as well as the "not found" errors, for the configured TTL
+type ownerCache struct {
+	mutex     sync.RWMutex
+	ttl       time.Duration
+	entries   map[ownerCacheKey]ownerCacheEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func newOwnerCache(ttl time.Duration) *ownerCache {
+	return &ownerCache{
+		ttl:     ttl,
+		entries: map[ownerCacheKey]ownerCacheEntry{},
+		now:     time.Now,
+	}
+}
+
+// get returns a copy of the cached entry, so the callers can't modify the cached owner
+func (c *ownerCache) get(key ownerCacheKey) (ownerCacheEntry, bool) {
+	c.mutex.RLock()
+	entry, found := c.entries[key]
+	c.mutex.RUnlock()
+	if !found || !c.now().Before(entry.expiresAt) {
+		OwnerCacheMisses.Inc()
+		return ownerCacheEntry{}, false
+	}
+	OwnerCacheHits.Inc()
+	if entry.owner != nil {
+		gvr := *entry.owner.GVR
+		entry.owner = &ObjectWithGVR{
+			Object: entry.owner.Object.DeepCopy(),
+			GVR:    &gvr,
+		}
+	}
+	return entry, true
+}
+
+func (c *ownerCache) put(key ownerCacheKey, owner *ObjectWithGVR, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+	entry := ownerCacheEntry{
+		err:       err,
+		expiresAt: now.Add(c.ttl),
+	}
+	if owner != nil {
+		gvr := *owner.GVR
+		entry.owner = &ObjectWithGVR{
+			Object: owner.Object.DeepCopy(),
+			GVR:    &gvr,
+		}
+	}
+	c.entries[key] = entry
+
+	// drop the expired entries (at most once per TTL), so the cache doesn't grow indefinitely
+	if now.Sub(c.lastSweep) >= c.ttl {
+		c.lastSweep = now
+		for k, e := range c.entries {
+			if !now.Before(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+	}
+}
+
+func (c *ownerCache) invalidate() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries = map[ownerCacheKey]ownerCacheEntry{}
+}
@@ -0,0 +1,172 @@
+package owners
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/test/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestCacheOwners(t *testing.T) {
+	newPod := func(t *testing.T, owner metav1.Object) *corev1.Pod {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-namespace"}}
+		require.NoError(t, controllerruntime.SetControllerReference(owner, pod, scheme.Scheme))
+		return pod
+	}
+	replica := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "test-replica", Namespace: "test-namespace"}}
+	newFetcher := func(t *testing.T, objects ...runtime.Object) (*OwnerFetcher, *fakedynamic.FakeDynamicClient, *time.Time) {
+		dynamicClient := fakedynamic.NewSimpleDynamicClient(scheme.Scheme, objects...)
+		fetcher := NewOwnerFetcher(newFakeDiscoveryClient(withVMResourcesList(t)...), dynamicClient, CacheOwners(time.Minute))
+		now := time.Now()
+		fetcher.ownerCache.now = func() time.Time {
+			return now
+		}
+		return fetcher, dynamicClient, &now
+	}
+
+	t.Run("repeated lookups are served from the cache", func(t *testing.T) {
+		// given
+		pod := newPod(t, replica)
+		fetcher, dynamicClient, _ := newFetcher(t, pod, replica.DeepCopy())
+		hits := metrics.GetCounterInt(OwnerCacheHits)
+		misses := metrics.GetCounterInt(OwnerCacheMisses)
+
+		// when
+		first, err := fetcher.GetOwners(context.TODO(), pod)
+		require.NoError(t, err)
+		second, err := fetcher.GetOwners(context.TODO(), pod)
+		require.NoError(t, err)
+
+		// then
+		require.Len(t, first, 1)
+		require.Len(t, second, 1)
+		assert.Equal(t, first[0].Object, second[0].Object)
+		assert.Len(t, dynamicClient.Actions(), 1)
+		metrics.AssertCounterEqualsInt(t, hits+1, OwnerCacheHits)
+		metrics.AssertCounterEqualsInt(t, misses+1, OwnerCacheMisses)
+
+		t.Run("cached owner can't be modified by the caller", func(t *testing.T) {
+			// given
+			second[0].Object.SetLabels(map[string]string{"modified": "true"})
+
+			// when
+			owners, err := fetcher.GetOwners(context.TODO(), pod)
+
+			// then
+			require.NoError(t, err)
+			assert.Empty(t, owners[0].Object.GetLabels())
+		})
+	})
+
+	t.Run("fetched again when expired", func(t *testing.T) {
+		// given
+		pod := newPod(t, replica)
+		fetcher, dynamicClient, now := newFetcher(t, pod, replica.DeepCopy())
+		_, err := fetcher.GetOwners(context.TODO(), pod)
+		require.NoError(t, err)
+		*now = now.Add(time.Minute)
+
+		// when
+		_, err = fetcher.GetOwners(context.TODO(), pod)
+
+		// then
+		require.NoError(t, err)
+		assert.Len(t, dynamicClient.Actions(), 2)
+	})
+
+	t.Run("fetched again when invalidated", func(t *testing.T) {
+		// given
+		pod := newPod(t, replica)
+		fetcher, dynamicClient, _ := newFetcher(t, pod, replica.DeepCopy())
+		_, err := fetcher.GetOwners(context.TODO(), pod)
+		require.NoError(t, err)
+
+		// when
+		fetcher.InvalidateOwnerCache()
+
+		// then
+		_, err = fetcher.GetOwners(context.TODO(), pod)
+		require.NoError(t, err)
+		assert.Len(t, dynamicClient.Actions(), 2)
+	})
+
+	t.Run("deleted owner is cached as not found", func(t *testing.T) {
+		// given
+		pod := newPod(t, replica)
+		fetcher, dynamicClient, _ := newFetcher(t, pod)
+		_, err := fetcher.GetOwners(context.TODO(), pod)
+		require.True(t, IsOwnerNotFound(err))
+
+		// when
+		_, err = fetcher.GetOwners(context.TODO(), pod)
+
+		// then
+		require.EqualError(t, err, "owner object test-namespace/test-replica apps/v1, Resource=replicasets not found")
+		assert.True(t, IsOwnerNotFound(err))
+		assert.True(t, apierrors.IsNotFound(err))
+		assert.Len(t, dynamicClient.Actions(), 1)
+	})
+
+	t.Run("cluster-scoped owner", func(t *testing.T) {
+		// given
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node"}}
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-namespace"}}
+		require.NoError(t, controllerutil.SetOwnerReference(node, pod, scheme.Scheme))
+		fetcher, dynamicClient, _ := newFetcher(t, pod, node)
+
+		// when
+		_, err := fetcher.GetOwners(context.TODO(), pod)
+		require.NoError(t, err)
+		owners, err := fetcher.GetOwners(context.TODO(), pod)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, owners, 1)
+		assert.Equal(t, "test-node", owners[0].Object.GetName())
+		assert.Len(t, dynamicClient.Actions(), 1)
+	})
+}
+
+func TestNamespacedOwnerOfClusterScopedObject(t *testing.T) {
+	// given
+	replica := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "test-replica", Namespace: "test-namespace"}}
+	// controllerutil refuses to set such an owner reference, so it's set directly
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node", OwnerReferences: []metav1.OwnerReference{
+		{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "test-replica"},
+	}}}
+	dynamicClient := fakedynamic.NewSimpleDynamicClient(scheme.Scheme, node, replica)
+	fetcher := NewOwnerFetcher(newFakeDiscoveryClient(withVMResourcesList(t)...), dynamicClient)
+
+	// when
+	owners, err := fetcher.GetOwners(context.TODO(), node)
+
+	// then
+	require.EqualError(t, err, "the cluster-scoped object cannot be owned by the namespaced apps/v1, Resource=replicasets test-replica")
+	assert.Nil(t, owners)
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	// given
+	registry := prometheus.NewRegistry()
+
+	// when
+	err := RegisterMetrics(registry)
+
+	// then
+	require.NoError(t, err)
+	// can't be registered twice
+	require.Error(t, RegisterMetrics(registry))
+}
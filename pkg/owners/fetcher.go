@@ -2,14 +2,21 @@ package owners
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
 )
 
 // OwnerFetcher fetches the owner references of Kubernetes objects by traversing
@@ -17,22 +24,64 @@ import (
 type OwnerFetcher struct {
 	resourceCache *client.ResourceCache
 	dynamicClient dynamic.Interface
+	ownerCache    *ownerCache // nil if the owners are not cached
+	scheme        *runtime.Scheme
 }
 
+// OwnerFetcherOption configures the OwnerFetcher
+type OwnerFetcherOption func(o *OwnerFetcher)
+
+// CacheOwners makes the OwnerFetcher keep the fetched owners (as well as the information that an owner doesn't exist)
+// for the given duration, so the repeated lookups of the same owners don't call the API server.
+// The hits and misses of the cache are counted by the OwnerCacheHits and OwnerCacheMisses metrics.
+func CacheOwners(ttl time.Duration) OwnerFetcherOption {
+	return func(o *OwnerFetcher) {
+		o.ownerCache = newOwnerCache(ttl)
+	}
+}
+
+// WithScheme sets the scheme used to resolve the GroupVersionKind of the typed objects that don't have their TypeMeta set.
+// By default, the scheme contains the Kubernetes built-in types and the toolchain types.
+func WithScheme(s *runtime.Scheme) OwnerFetcherOption {
+	return func(o *OwnerFetcher) {
+		o.scheme = s
+	}
+}
+
+// defaultScheme contains the Kubernetes built-in types and the toolchain types
+var defaultScheme = func() *runtime.Scheme {
+	s := runtime.NewScheme()
+	utilruntime.Must(scheme.AddToScheme(s))
+	utilruntime.Must(toolchainv1alpha1.AddToScheme(s))
+	return s
+}()
+
 // NewOwnerFetcher creates a new OwnerFetcher with the provided discovery and dynamic clients.
 // The discovery client is used to fetch available API resources, and the dynamic client is used
 // to retrieve owner objects from the cluster.
 // NOTE: this is kept for backwards compatibility. Prefer using the NewOwnerFetcherWithCache() function.
-func NewOwnerFetcher(discoveryClient discovery.ServerResourcesInterface, dynamicClient dynamic.Interface) *OwnerFetcher {
-	return NewOwnerFetcherWithCache(dynamicClient, client.NewResourceCache(discoveryClient))
+func NewOwnerFetcher(discoveryClient discovery.ServerResourcesInterface, dynamicClient dynamic.Interface, options ...OwnerFetcherOption) *OwnerFetcher {
+	return NewOwnerFetcherWithCache(dynamicClient, client.NewResourceCache(discoveryClient), options...)
 }
 
 // NewOwnerFetcherWithCache creates a new OwnerFetcher with the provided resourceCache used to look up
 // the GVRs and the dynamicClient for retrieval of the owners from the cluster.
-func NewOwnerFetcherWithCache(dynamicClient dynamic.Interface, resourceCache *client.ResourceCache) *OwnerFetcher {
-	return &OwnerFetcher{
+func NewOwnerFetcherWithCache(dynamicClient dynamic.Interface, resourceCache *client.ResourceCache, options ...OwnerFetcherOption) *OwnerFetcher {
+	fetcher := &OwnerFetcher{
 		resourceCache: resourceCache,
 		dynamicClient: dynamicClient,
+		scheme:        defaultScheme,
+	}
+	for _, apply := range options {
+		apply(fetcher)
+	}
+	return fetcher
+}
+
+// InvalidateOwnerCache drops all the cached owners. It's a no-op if the owners are not cached.
+func (o *OwnerFetcher) InvalidateOwnerCache() {
+	if o.ownerCache != nil {
+		o.ownerCache.invalidate()
 	}
 }
 
@@ -68,6 +117,20 @@ func (o *OwnerFetcher) GetOwners(ctx context.Context, obj metav1.Object) ([]*Obj
 	if ownerReference.Name == "" {
 		return nil, nil // No owner
 	}
+	owner, err := o.fetchOwner(ctx, obj.GetNamespace(), ownerReference)
+	if err != nil {
+		return nil, err
+	}
+	// Recursively try to find the top owner
+	ownerOwners, err := o.GetOwners(ctx, owner.Object)
+	if err != nil || ownerOwners == nil {
+		return append(ownerOwners, owner), err
+	}
+	return append(ownerOwners, owner), nil
+}
+
+// fetchOwner gets the owner referenced by the given owner reference of an object from the given namespace.
+func (o *OwnerFetcher) fetchOwner(ctx context.Context, namespace string, ownerReference metav1.OwnerReference) (*ObjectWithGVR, error) {
 	// Get the GVR for the owner
 	gvr, found, namespaced, err := o.resourceCache.GVRForKind(ownerReference.Kind, ownerReference.APIVersion)
 	if err != nil {
@@ -77,27 +140,78 @@ func (o *OwnerFetcher) GetOwners(ctx context.Context, obj metav1.Object) ([]*Obj
 		return nil, fmt.Errorf("no resource found for kind %s in %s", ownerReference.Kind, ownerReference.APIVersion)
 	}
 
+	if !namespaced {
+		// the owner is cluster-scoped, even if the owned object is namespaced
+		namespace = ""
+	} else if namespace == "" {
+		return nil, fmt.Errorf("the cluster-scoped object cannot be owned by the namespaced %s %s", gvr.String(), ownerReference.Name)
+	}
+	key := ownerCacheKey{gvr: gvr, namespace: namespace, name: ownerReference.Name}
+	if o.ownerCache != nil {
+		if entry, found := o.ownerCache.get(key); found {
+			return entry.owner, entry.err
+		}
+	}
+	owner, err := o.getOwner(ctx, key)
+	if o.ownerCache != nil && (err == nil || IsOwnerNotFound(err)) {
+		o.ownerCache.put(key, owner, err)
+	}
+	return owner, err
+}
+
+func (o *OwnerFetcher) getOwner(ctx context.Context, key ownerCacheKey) (*ObjectWithGVR, error) {
 	// Get the owner object; use namespace only for namespaced resources
-	resourceClient := o.dynamicClient.Resource(gvr)
+	resourceClient := o.dynamicClient.Resource(key.gvr)
 	var ownerObject *unstructured.Unstructured
-	nsdName := ownerReference.Name
-	if namespaced {
-		ownerObject, err = resourceClient.Namespace(obj.GetNamespace()).Get(ctx, ownerReference.Name, metav1.GetOptions{})
-		nsdName = fmt.Sprintf("%s/%s", obj.GetNamespace(), ownerReference.Name)
+	var err error
+	nsdName := key.name
+	if key.namespace != "" {
+		ownerObject, err = resourceClient.Namespace(key.namespace).Get(ctx, key.name, metav1.GetOptions{})
+		nsdName = fmt.Sprintf("%s/%s", key.namespace, key.name)
 	} else {
-		ownerObject, err = resourceClient.Get(ctx, ownerReference.Name, metav1.GetOptions{})
+		ownerObject, err = resourceClient.Get(ctx, key.name, metav1.GetOptions{})
+	}
+	if apierrors.IsNotFound(err) {
+		return nil, &OwnerNotFoundError{
+			GVR:       key.gvr,
+			Namespace: key.namespace,
+			Name:      key.name,
+			Err:       err,
+		}
 	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch owner object %s %s : %w", nsdName, gvr.String(), err)
+		return nil, fmt.Errorf("failed to fetch owner object %s %s : %w", nsdName, key.gvr.String(), err)
 	}
-	owner := &ObjectWithGVR{
+	gvr := key.gvr
+	return &ObjectWithGVR{
 		Object: ownerObject,
 		GVR:    &gvr,
+	}, nil
+}
+
+// OwnerNotFoundError is returned when the owner referenced by an object doesn't exist (eg. it has been deleted already)
+type OwnerNotFoundError struct {
+	GVR       schema.GroupVersionResource
+	Namespace string
+	Name      string
+	// Err is the original error returned by the API server
+	Err error
+}
+
+func (e *OwnerNotFoundError) Error() string {
+	nsdName := e.Name
+	if e.Namespace != "" {
+		nsdName = fmt.Sprintf("%s/%s", e.Namespace, e.Name)
 	}
-	// Recursively try to find the top owner
-	ownerOwners, err := o.GetOwners(ctx, ownerObject)
-	if err != nil || ownerOwners == nil {
-		return append(ownerOwners, owner), err
-	}
-	return append(ownerOwners, owner), nil
+	return fmt.Sprintf("owner object %s %s not found", nsdName, e.GVR.String())
+}
+
+func (e *OwnerNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// IsOwnerNotFound returns true if the error (or any error it wraps) is the OwnerNotFoundError
+func IsOwnerNotFound(err error) bool {
+	var notFound *OwnerNotFoundError
+	return errors.As(err, &notFound)
 }
@@ -0,0 +1,225 @@
+package owners
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// DefaultMaxOwnerDepth is the default maximum number of owner levels traversed by GetOwnerGraph
+const DefaultMaxOwnerDepth = 10
+
+// OwnerEdgeType says whether the owner is the controller of the owned object or not
+type OwnerEdgeType string
+
+const (
+	// ControllerOwnerEdge is the edge to the owner that is the controller of the owned object
+	ControllerOwnerEdge OwnerEdgeType = "controller"
+	// NonControllerOwnerEdge is the edge to an owner that is not the controller of the owned object
+	NonControllerOwnerEdge OwnerEdgeType = "non-controller"
+)
+
+// ObjectKey identifies an object in the owner graph
+type ObjectKey struct {
+	GroupKind schema.GroupKind
+	Namespace string
+	Name      string
+}
+
+func (k ObjectKey) String() string {
+	if k.Namespace == "" {
+		return fmt.Sprintf("%s %s", k.GroupKind, k.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", k.GroupKind, k.Namespace, k.Name)
+}
+
+// OwnerEdge is an edge of the owner graph pointing from the owned object to its owner
+type OwnerEdge struct {
+	Owned ObjectKey
+	Owner ObjectKey
+	Type  OwnerEdgeType
+}
+
+// OwnerGraph is a directed acyclic graph of all the owners of an object. The edges point from the owned objects to their owners.
+type OwnerGraph struct {
+	// Root is the key of the object the graph was built for
+	Root ObjectKey
+	// Owners contains all the owners found in the graph (the root object is not included)
+	Owners map[ObjectKey]*ObjectWithGVR
+	// Edges contains the edges in the order in which they were traversed (breadth-first)
+	Edges []OwnerEdge
+	// CycleEdges contains the edges that were not added to the graph because they would create a cycle
+	CycleEdges []OwnerEdge
+	// Truncated is true when some owners were not traversed because of the maximum depth
+	Truncated bool
+}
+
+// OwnersOf returns the edges pointing to the direct owners of the object with the given key
+func (g *OwnerGraph) OwnersOf(key ObjectKey) []OwnerEdge {
+	var edges []OwnerEdge
+	for _, edge := range g.Edges {
+		if edge.Owned == key {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+// TopLevelOwners returns the owners that don't have any owner (in the order of their discovery)
+func (g *OwnerGraph) TopLevelOwners() []*ObjectWithGVR {
+	var topLevel []*ObjectWithGVR
+	seen := map[ObjectKey]bool{}
+	for _, edge := range g.Edges {
+		if seen[edge.Owner] {
+			continue
+		}
+		seen[edge.Owner] = true
+		if len(g.OwnersOf(edge.Owner)) == 0 {
+			topLevel = append(topLevel, g.Owners[edge.Owner])
+		}
+	}
+	return topLevel
+}
+
+// reaches returns true if the "to" object is reachable from the "from" object by following the edges
+func (g *OwnerGraph) reaches(from, to ObjectKey) bool {
+	visited := map[ObjectKey]bool{}
+	queue := []ObjectKey{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == to {
+			return true
+		}
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+		for _, edge := range g.OwnersOf(current) {
+			queue = append(queue, edge.Owner)
+		}
+	}
+	return false
+}
+
+type graphConfiguration struct {
+	maxDepth int
+}
+
+// GraphOption configures the traversal of the owner graph
+type GraphOption func(config *graphConfiguration)
+
+// MaxDepth sets the maximum number of owner levels to traverse. The direct owners of the object are at the level 1.
+func MaxDepth(maxDepth int) GraphOption {
+	return func(config *graphConfiguration) {
+		config.maxDepth = maxDepth
+	}
+}
+
+// GetOwnerGraph retrieves all the owners of the given object by following all its owner references (not only the controller one)
+// up to the top-level owners. Each owner is fetched only once, even if it owns multiple objects in the graph.
+//
+// The traversal doesn't stop on the first failure - the owners that cannot be fetched are not added to the graph and the errors
+// are returned as a single joined error together with the rest of the graph.
+func (o *OwnerFetcher) GetOwnerGraph(ctx context.Context, obj metav1.Object, opts ...GraphOption) (*OwnerGraph, error) {
+	config := graphConfiguration{
+		maxDepth: DefaultMaxOwnerDepth,
+	}
+	for _, apply := range opts {
+		apply(&config)
+	}
+
+	graph := &OwnerGraph{
+		Root:   o.objectKeyOf(obj),
+		Owners: map[ObjectKey]*ObjectWithGVR{},
+	}
+	type item struct {
+		key   ObjectKey
+		obj   metav1.Object
+		depth int
+	}
+	queue := []item{{key: graph.Root, obj: obj}}
+	var errs []error
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if len(current.obj.GetOwnerReferences()) > 0 && current.depth >= config.maxDepth {
+			graph.Truncated = true
+			continue
+		}
+		for _, ownerRef := range current.obj.GetOwnerReferences() {
+			gv, err := schema.ParseGroupVersion(ownerRef.APIVersion)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid owner reference of %s: %w", current.key, err))
+				continue
+			}
+			edge := OwnerEdge{
+				Owned: current.key,
+				Owner: ObjectKey{
+					GroupKind: gv.WithKind(ownerRef.Kind).GroupKind(),
+					Namespace: current.obj.GetNamespace(),
+					Name:      ownerRef.Name,
+				},
+				Type: NonControllerOwnerEdge,
+			}
+			if ownerRef.Controller != nil && *ownerRef.Controller {
+				edge.Type = ControllerOwnerEdge
+			}
+
+			// the namespace in the key is resolved only when the owner is fetched, so look for the cluster-scoped variant too
+			if _, fetched := graph.Owners[edge.Owner]; !fetched {
+				clusterScoped := edge.Owner
+				clusterScoped.Namespace = ""
+				if _, fetched := graph.Owners[clusterScoped]; fetched {
+					edge.Owner = clusterScoped
+				}
+			}
+			if edge.Owner == edge.Owned || graph.reaches(edge.Owner, edge.Owned) {
+				graph.CycleEdges = append(graph.CycleEdges, edge)
+				continue
+			}
+			if _, fetched := graph.Owners[edge.Owner]; fetched {
+				graph.Edges = append(graph.Edges, edge)
+				continue
+			}
+
+			owner, err := o.fetchOwner(ctx, current.obj.GetNamespace(), ownerRef)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			// the owner can be cluster-scoped
+			edge.Owner.Namespace = owner.Object.GetNamespace()
+			if _, fetched := graph.Owners[edge.Owner]; !fetched {
+				graph.Owners[edge.Owner] = owner
+				queue = append(queue, item{key: edge.Owner, obj: owner.Object, depth: current.depth + 1})
+			}
+			graph.Edges = append(graph.Edges, edge)
+		}
+	}
+	// joined, so the callers can still check the type of the errors (eg. apierrors.IsNotFound)
+	return graph, errors.Join(errs...)
+}
+
+// objectKeyOf returns the key of the given object. The GroupKind of the typed objects without TypeMeta is resolved
+// through the scheme, so the key is the same as the key of the object when it's found as an owner.
+func (o *OwnerFetcher) objectKeyOf(obj metav1.Object) ObjectKey {
+	key := ObjectKey{
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+	}
+	if runtimeObj, ok := obj.(runtime.Object); ok {
+		key.GroupKind = runtimeObj.GetObjectKind().GroupVersionKind().GroupKind()
+		if key.GroupKind.Empty() && o.scheme != nil {
+			if gvk, err := apiutil.GVKForObject(runtimeObj, o.scheme); err == nil {
+				key.GroupKind = gvk.GroupKind()
+			}
+		}
+	}
+	return key
+}
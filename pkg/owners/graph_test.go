@@ -0,0 +1,203 @@
+package owners
+
+import (
+	"context"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestGetOwnerGraph(t *testing.T) {
+	newObjects := func() (*corev1.Pod, *appsv1.ReplicaSet, *appsv1.Deployment, *toolchainv1alpha1.Idler) {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-namespace"}}
+		replica := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "test-replica", Namespace: "test-namespace"}}
+		deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "test-namespace"}}
+		idler := &toolchainv1alpha1.Idler{ObjectMeta: metav1.ObjectMeta{Name: "test-idler", Namespace: "test-namespace"}}
+		return pod, replica, deployment, idler
+	}
+	podKey := ObjectKey{GroupKind: schema.GroupKind{Kind: "Pod"}, Namespace: "test-namespace", Name: "test-pod"}
+	replicaKey := ObjectKey{GroupKind: schema.GroupKind{Group: "apps", Kind: "ReplicaSet"}, Namespace: "test-namespace", Name: "test-replica"}
+	deploymentKey := ObjectKey{GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"}, Namespace: "test-namespace", Name: "test-deployment"}
+	idlerKey := ObjectKey{GroupKind: schema.GroupKind{Group: "toolchain.dev.openshift.com", Kind: "Idler"}, Namespace: "test-namespace", Name: "test-idler"}
+
+	newFetcher := func(t *testing.T, objects ...runtime.Object) (*OwnerFetcher, *fakedynamic.FakeDynamicClient) {
+		dynamicClient := fakedynamic.NewSimpleDynamicClient(scheme.Scheme, objects...)
+		return NewOwnerFetcher(newFakeDiscoveryClient(withVMResourcesList(t)...), dynamicClient), dynamicClient
+	}
+
+	t.Run("no owner", func(t *testing.T) {
+		// given
+		pod, _, _, _ := newObjects()
+		fetcher, _ := newFetcher(t, pod)
+
+		// when
+		graph, err := fetcher.GetOwnerGraph(context.TODO(), pod)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, podKey, graph.Root)
+		assert.Empty(t, graph.Owners)
+		assert.Empty(t, graph.Edges)
+		assert.Empty(t, graph.TopLevelOwners())
+	})
+
+	t.Run("all owners with shared owner fetched once", func(t *testing.T) {
+		// given
+		pod, replica, deployment, idler := newObjects()
+		require.NoError(t, controllerruntime.SetControllerReference(deployment, replica, scheme.Scheme))
+		require.NoError(t, controllerutil.SetOwnerReference(idler, deployment, scheme.Scheme))
+		require.NoError(t, controllerruntime.SetControllerReference(replica, pod, scheme.Scheme))
+		require.NoError(t, controllerutil.SetOwnerReference(idler, pod, scheme.Scheme))
+		fetcher, dynamicClient := newFetcher(t, pod, replica, deployment, idler)
+
+		// when
+		graph, err := fetcher.GetOwnerGraph(context.TODO(), pod)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []OwnerEdge{
+			{Owned: podKey, Owner: replicaKey, Type: ControllerOwnerEdge},
+			{Owned: podKey, Owner: idlerKey, Type: NonControllerOwnerEdge},
+			{Owned: replicaKey, Owner: deploymentKey, Type: ControllerOwnerEdge},
+			{Owned: deploymentKey, Owner: idlerKey, Type: NonControllerOwnerEdge},
+		}, graph.Edges)
+		require.Len(t, graph.Owners, 3)
+		assert.Equal(t, "test-replica", graph.Owners[replicaKey].Object.GetName())
+		assert.Equal(t, schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, *graph.Owners[deploymentKey].GVR)
+		assert.Equal(t, []OwnerEdge{
+			{Owned: podKey, Owner: replicaKey, Type: ControllerOwnerEdge},
+			{Owned: podKey, Owner: idlerKey, Type: NonControllerOwnerEdge},
+		}, graph.OwnersOf(podKey))
+		topLevel := graph.TopLevelOwners()
+		require.Len(t, topLevel, 1)
+		assert.Equal(t, "test-idler", topLevel[0].Object.GetName())
+		assert.Empty(t, graph.CycleEdges)
+		assert.False(t, graph.Truncated)
+		// every owner is fetched only once
+		assert.Len(t, dynamicClient.Actions(), 3)
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		// given
+		pod, replica, deployment, _ := newObjects()
+		require.NoError(t, controllerruntime.SetControllerReference(replica, pod, scheme.Scheme))
+		require.NoError(t, controllerruntime.SetControllerReference(deployment, replica, scheme.Scheme))
+		require.NoError(t, controllerutil.SetOwnerReference(replica, deployment, scheme.Scheme))
+		fetcher, _ := newFetcher(t, pod, replica, deployment)
+
+		// when
+		graph, err := fetcher.GetOwnerGraph(context.TODO(), pod)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []OwnerEdge{
+			{Owned: podKey, Owner: replicaKey, Type: ControllerOwnerEdge},
+			{Owned: replicaKey, Owner: deploymentKey, Type: ControllerOwnerEdge},
+		}, graph.Edges)
+		assert.Equal(t, []OwnerEdge{
+			{Owned: deploymentKey, Owner: replicaKey, Type: NonControllerOwnerEdge},
+		}, graph.CycleEdges)
+		topLevel := graph.TopLevelOwners()
+		require.Len(t, topLevel, 1)
+		assert.Equal(t, "test-deployment", topLevel[0].Object.GetName())
+	})
+
+	t.Run("cycle through the root object without TypeMeta", func(t *testing.T) {
+		// given
+		pod, replica, _, _ := newObjects()
+		require.NoError(t, controllerruntime.SetControllerReference(replica, pod, scheme.Scheme))
+		require.NoError(t, controllerutil.SetOwnerReference(pod, replica, scheme.Scheme))
+		fetcher, dynamicClient := newFetcher(t, pod, replica)
+		require.Empty(t, pod.GetObjectKind().GroupVersionKind())
+
+		// when
+		graph, err := fetcher.GetOwnerGraph(context.TODO(), pod)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, podKey, graph.Root)
+		assert.Equal(t, []OwnerEdge{
+			{Owned: podKey, Owner: replicaKey, Type: ControllerOwnerEdge},
+		}, graph.Edges)
+		assert.Equal(t, []OwnerEdge{
+			{Owned: replicaKey, Owner: podKey, Type: NonControllerOwnerEdge},
+		}, graph.CycleEdges)
+		assert.NotContains(t, graph.Owners, podKey)
+		// the root object is not fetched as an owner
+		assert.Len(t, dynamicClient.Actions(), 1)
+	})
+
+	t.Run("max depth", func(t *testing.T) {
+		// given
+		pod, replica, deployment, _ := newObjects()
+		require.NoError(t, controllerruntime.SetControllerReference(replica, pod, scheme.Scheme))
+		require.NoError(t, controllerruntime.SetControllerReference(deployment, replica, scheme.Scheme))
+		fetcher, _ := newFetcher(t, pod, replica, deployment)
+
+		// when
+		graph, err := fetcher.GetOwnerGraph(context.TODO(), pod, MaxDepth(1))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []OwnerEdge{
+			{Owned: podKey, Owner: replicaKey, Type: ControllerOwnerEdge},
+		}, graph.Edges)
+		assert.True(t, graph.Truncated)
+	})
+
+	t.Run("cluster-scoped owner", func(t *testing.T) {
+		// given
+		pod, replica, _, _ := newObjects()
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node"}}
+		require.NoError(t, controllerruntime.SetControllerReference(replica, pod, scheme.Scheme))
+		require.NoError(t, controllerutil.SetOwnerReference(node, pod, scheme.Scheme))
+		require.NoError(t, controllerutil.SetOwnerReference(node, replica, scheme.Scheme))
+		fetcher, dynamicClient := newFetcher(t, pod, replica, node)
+
+		// when
+		graph, err := fetcher.GetOwnerGraph(context.TODO(), pod)
+
+		// then
+		require.NoError(t, err)
+		nodeKey := ObjectKey{GroupKind: schema.GroupKind{Kind: "Node"}, Name: "test-node"}
+		assert.Equal(t, []OwnerEdge{
+			{Owned: podKey, Owner: replicaKey, Type: ControllerOwnerEdge},
+			{Owned: podKey, Owner: nodeKey, Type: NonControllerOwnerEdge},
+			{Owned: replicaKey, Owner: nodeKey, Type: NonControllerOwnerEdge},
+		}, graph.Edges)
+		assert.Len(t, dynamicClient.Actions(), 2)
+	})
+
+	t.Run("missing owner is reported with the rest of the graph", func(t *testing.T) {
+		// given
+		pod, replica, deployment, idler := newObjects()
+		require.NoError(t, controllerruntime.SetControllerReference(replica, pod, scheme.Scheme))
+		require.NoError(t, controllerutil.SetOwnerReference(idler, pod, scheme.Scheme))
+		require.NoError(t, controllerruntime.SetControllerReference(deployment, replica, scheme.Scheme))
+		fetcher, _ := newFetcher(t, pod, replica, idler)
+
+		// when
+		graph, err := fetcher.GetOwnerGraph(context.TODO(), pod)
+
+		// then
+		require.ErrorContains(t, err, "owner object test-namespace/test-deployment apps/v1, Resource=deployments not found")
+		assert.True(t, apierrors.IsNotFound(err))
+		assert.True(t, IsOwnerNotFound(err))
+		assert.Equal(t, []OwnerEdge{
+			{Owned: podKey, Owner: replicaKey, Type: ControllerOwnerEdge},
+			{Owned: podKey, Owner: idlerKey, Type: NonControllerOwnerEdge},
+		}, graph.Edges)
+	})
+}
@@ -0,0 +1,33 @@
+package status
+
+import (
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+)
+
+const (
+	// ErrMsgResourcesNotReady applied resources not ready
+	ErrMsgResourcesNotReady = "some of the applied resources are not ready"
+
+	// ErrMsgReadinessNotChecked the readiness of the applied resources was not checked
+	ErrMsgReadinessNotChecked = "the readiness of the resources was not checked"
+
+	// ResourcesReadyReason all the applied resources are ready
+	ResourcesReadyReason = "ResourcesReady"
+
+	// ResourcesNotReadyReason some of the applied resources are not ready
+	ResourcesNotReadyReason = "ResourcesNotReady"
+)
+
+// GetReadinessReportConditions converts the readiness report of the applied objects to a condition summarizing their status.
+// The returned condition is ready only when all the objects in the report are ready. A nil or empty report (eg. when the readiness
+// check failed) is reported as not ready.
+func GetReadinessReportConditions(report *client.ReadinessReport) []toolchainv1alpha1.Condition {
+	if report == nil || len(report.Objects) == 0 {
+		return []toolchainv1alpha1.Condition{*NewComponentErrorCondition(ResourcesNotReadyReason, ErrMsgResourcesNotReady+": "+ErrMsgReadinessNotChecked)}
+	}
+	if report.AllReady() {
+		return []toolchainv1alpha1.Condition{*NewComponentReadyCondition(ResourcesReadyReason)}
+	}
+	return []toolchainv1alpha1.Condition{*NewComponentErrorCondition(ResourcesNotReadyReason, ErrMsgResourcesNotReady+": "+report.Message())}
+}
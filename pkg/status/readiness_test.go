@@ -0,0 +1,79 @@
+package status
+
+import (
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+
+	"github.com/stretchr/testify/require"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestGetReadinessReportConditions(t *testing.T) {
+
+	deployment := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	namespace := schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}
+
+	t.Run("all objects ready", func(t *testing.T) {
+		report := &client.ReadinessReport{Objects: []client.ObjectReadiness{
+			{GVK: namespace, Name: "toolchain", Ready: true},
+			{GVK: deployment, Namespace: "toolchain", Name: "operator", Ready: true},
+		}}
+
+		conditions := GetReadinessReportConditions(report)
+		err := ValidateComponentConditionReady(conditions...)
+		require.NoError(t, err)
+
+		expected := toolchainv1alpha1.Condition{
+			Type:   toolchainv1alpha1.ConditionReady,
+			Status: corev1.ConditionTrue,
+			Reason: ResourcesReadyReason,
+		}
+		test.AssertConditionsMatchAndRecentTimestamps(t, conditions, expected)
+	})
+
+	t.Run("some objects not ready", func(t *testing.T) {
+		report := &client.ReadinessReport{Objects: []client.ObjectReadiness{
+			{GVK: namespace, Name: "toolchain", Ready: true},
+			{GVK: deployment, Namespace: "toolchain", Name: "operator", Message: "0 of 1 replicas available"},
+			{GVK: deployment, Namespace: "toolchain", Name: "webhook", Message: "not found"},
+		}}
+
+		conditions := GetReadinessReportConditions(report)
+		err := ValidateComponentConditionReady(conditions...)
+		require.Error(t, err)
+
+		expected := toolchainv1alpha1.Condition{
+			Type:    toolchainv1alpha1.ConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  ResourcesNotReadyReason,
+			Message: "some of the applied resources are not ready: Deployment toolchain/operator: 0 of 1 replicas available; Deployment toolchain/webhook: not found",
+		}
+		test.AssertConditionsMatchAndRecentTimestamps(t, conditions, expected)
+	})
+
+	t.Run("no report", func(t *testing.T) {
+		for name, report := range map[string]*client.ReadinessReport{
+			"nil":   nil,
+			"empty": {},
+		} {
+			t.Run(name, func(t *testing.T) {
+				conditions := GetReadinessReportConditions(report)
+				err := ValidateComponentConditionReady(conditions...)
+				require.Error(t, err)
+
+				expected := toolchainv1alpha1.Condition{
+					Type:    toolchainv1alpha1.ConditionReady,
+					Status:  corev1.ConditionFalse,
+					Reason:  ResourcesNotReadyReason,
+					Message: "some of the applied resources are not ready: the readiness of the resources was not checked",
+				}
+				test.AssertConditionsMatchAndRecentTimestamps(t, conditions, expected)
+			})
+		}
+	})
+}
@@ -19,6 +19,8 @@ const (
 
 // ToolchainClusterAttributes required attributes for obtaining ToolchainCluster status
 type ToolchainClusterAttributes struct {
+	// GetClusterFunc retrieves the cluster, eg. cluster.HostCluster for the default cache,
+	// or ClusterCache.GetHostCluster and ClusterCache.GetClusterFunc for a specific cache
 	GetClusterFunc func() (*cluster.CachedToolchainCluster, bool)
 	Period         time.Duration
 	Timeout        time.Duration
@@ -17,6 +17,8 @@ import (
 // Variables contains all the available variables that are supported by the templates
 type Variables struct {
 	Namespace string
+	// Values are the custom variables, available in the templates as `{{.Values.name}}`
+	Values map[string]string
 }
 
 // LoadObjectsFromEmbedFS loads all the kubernetes objects from an embedded filesystem and returns a list of Unstructured objects that can be applied in the cluster.
@@ -60,10 +62,11 @@ func LoadObjectsFromEmbedFS(efs *embed.FS, variables *Variables) ([]*unstructure
 	return objects, nil
 }
 
-// replaceTemplateVariables replaces all the variables in the given template and returns a buffer with the evaluated content
+// replaceTemplateVariables replaces all the variables in the given template and returns a buffer with the evaluated content.
+// A custom variable that is missing in the Values is an error.
 func replaceTemplateVariables(templateName string, templateContent []byte, variables *Variables) (bytes.Buffer, error) {
 	var buf bytes.Buffer
-	tmpl, err := template.New(templateName).Parse(string(templateContent))
+	tmpl, err := template.New(templateName).Option("missingkey=error").Parse(string(templateContent))
 	if err != nil {
 		return buf, err
 	}
@@ -263,6 +263,12 @@ func Patch(ctx context.Context, fakeClient *FakeClient, obj client.Object, patch
 	// if it doesn't exist.
 	if patch == client.Apply {
 		if !found {
+			patchOptions := &client.PatchOptions{}
+			patchOptions.ApplyOptions(opts)
+			if len(patchOptions.DryRun) > 0 {
+				// nothing is created in the dry-run mode, so there's nothing to patch either
+				return Create(ctx, fakeClient, obj, client.DryRunAll)
+			}
 			if err := Create(ctx, fakeClient, obj); err != nil {
 				return err
 			}
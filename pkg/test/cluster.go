@@ -95,3 +95,14 @@ func createKubeConfigContent(t *testing.T, kubeConfig *clientcmdapi.Config) []by
 	require.NoError(t, err)
 	return data
 }
+
+// SetKubeConfigToken replaces the token of all the users in the kubeconfig stored in the given secret
+func SetKubeConfigToken(t *testing.T, secret *corev1.Secret, token string) {
+	t.Helper()
+	kubeConfig, err := clientcmd.Load(secret.Data["kubeconfig"])
+	require.NoError(t, err)
+	for _, authInfo := range kubeConfig.AuthInfos {
+		authInfo.Token = token
+	}
+	secret.Data["kubeconfig"] = createKubeConfigContent(t, kubeConfig)
+}